@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertRule is a threshold rule evaluated on each series of the metric of the
+// configured targets, e.g. `klipper_klippy_up == 0` for Klippy down. The alert
+// fires once the condition has been true for the For duration.
+type AlertRule struct {
+	Name   string `yaml:"name"`
+	Metric string `yaml:"metric"`
+	// Labels the series must have, e.g. `sensor: chamber`
+	Labels map[string]string `yaml:"labels"`
+	// Op compares the value of the series with the Value, one of `>`, `>=`,
+	// `<`, `<=`, `==`, or `!=`
+	Op    string        `yaml:"op"`
+	Value float64       `yaml:"value"`
+	For   time.Duration `yaml:"for"`
+	// Message is added to the notifications of the alert
+	Message string `yaml:"message"`
+}
+
+// NotificationConfig is where the firing and resolved alerts are sent.
+type NotificationConfig struct {
+	// WebhookURL is sent a JSON alert notification in a POST request
+	WebhookURL string `yaml:"webhook_url"`
+	Telegram   struct {
+		BotToken string `yaml:"bot_token"`
+		ChatID   string `yaml:"chat_id"`
+	} `yaml:"telegram"`
+}
+
+// validateAlertRules checks the rule names are unique and the metrics and
+// comparisons are valid.
+func validateAlertRules(rules []AlertRule) error {
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Name == "" || seen[rule.Name] {
+			return fmt.Errorf("alert names must be set and unique, '%s'", rule.Name)
+		}
+		seen[rule.Name] = true
+		if !model.IsValidMetricName(model.LabelValue(rule.Metric)) {
+			return fmt.Errorf("alert '%s' has invalid metric '%s'", rule.Name, rule.Metric)
+		}
+		if _, ok := compare(rule.Op, 0, 0); !ok {
+			return fmt.Errorf("alert '%s' has unknown op '%s', must be one of >, >=, <, <=, ==, or !=", rule.Name, rule.Op)
+		}
+		if rule.For < 0 {
+			return fmt.Errorf("alert '%s' for duration must not be negative", rule.Name)
+		}
+	}
+	return nil
+}
+
+// compare returns the result of the comparison, and false if the op is not
+// known.
+func compare(op string, value float64, threshold float64) (result bool, ok bool) {
+	switch op {
+	case ">":
+		return value > threshold, true
+	case ">=":
+		return value >= threshold, true
+	case "<":
+		return value < threshold, true
+	case "<=":
+		return value <= threshold, true
+	case "==":
+		return value == threshold, true
+	case "!=":
+		return value != threshold, true
+	}
+	return false, false
+}
+
+var alertsFiring = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "klipper_exporter_alerts_firing",
+	Help: "Number of series the alert rule is firing for.",
+}, []string{"alert"})
+
+var alertNotificationsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "klipper_exporter_alert_notifications_failed_total",
+	Help: "Number of alert notifications that could not be sent.",
+}, []string{"notifier"})
+
+// alert is the state of a rule for a single series.
+type alert struct {
+	rule        string
+	labels      map[string]string
+	value       float64
+	activeSince time.Time
+	firing      bool
+}
+
+// alertNotification is the JSON body sent to the webhook.
+type alertNotification struct {
+	Status      string            `json:"status"`
+	Alert       string            `json:"alert"`
+	Labels      map[string]string `json:"labels"`
+	Value       float64           `json:"value"`
+	Message     string            `json:"message,omitempty"`
+	ActiveSince float64           `json:"active_since"`
+	Time        float64           `json:"time"`
+}
+
+// alertEvaluator evaluates the alert rules of the configuration file on the
+// metrics of the configured targets, for setups without Prometheus and
+// Alertmanager.
+type alertEvaluator struct {
+	gatherer *targetsGatherer
+	client   *http.Client
+
+	mu sync.Mutex
+	// active alerts keyed by rule name and series labels
+	active map[string]*alert
+}
+
+func newAlertEvaluator(gatherer *targetsGatherer) *alertEvaluator {
+	// the evaluations read their own sampled signals, not those of the scrapes
+	alerts := *gatherer
+	alerts.scraper = "alerts"
+	return &alertEvaluator{
+		gatherer: &alerts,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		active:   make(map[string]*alert),
+	}
+}
+
+// run evaluates the rules at each interval.
+func (e *alertEvaluator) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		config := currentConfig()
+		if len(config.Alerts) == 0 {
+			continue
+		}
+		mfs, err := e.gatherer.Gather()
+		if err != nil {
+			log.Errorf("Unable to collect the targets to evaluate the alerts: %v", err)
+			continue
+		}
+		e.evaluate(config.Alerts, config.Notifications, mfs, time.Now())
+	}
+}
+
+// evaluate updates the state of the alerts from the collected metrics, and
+// sends a notification for each alert that starts firing or is resolved. The
+// notifications are sent after the mutex is released, so a slow webhook or
+// Telegram request does not hold up the next evaluation.
+func (e *alertEvaluator) evaluate(rules []AlertRule, notifications NotificationConfig, mfs []*dto.MetricFamily, now time.Time) {
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+	for _, n := range e.update(rules, families, now) {
+		e.notify(notifications, n)
+	}
+}
+
+// update updates the state of the alerts from the metric families keyed by
+// name, and returns the notifications of the alerts that start firing or are
+// resolved.
+func (e *alertEvaluator) update(rules []AlertRule, families map[string]*dto.MetricFamily, now time.Time) []alertNotification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	pending := []alertNotification{}
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		firing := 0
+		if mf, ok := families[rule.Metric]; ok {
+			for _, m := range mf.Metric {
+				labels := make(map[string]string, len(m.Label))
+				for _, label := range m.Label {
+					labels[label.GetName()] = label.GetValue()
+				}
+				value, ok := sampleValue(m)
+				if !ok || !matchLabels(labels, rule.Labels) {
+					continue
+				}
+				if result, _ := compare(rule.Op, value, rule.Value); !result {
+					continue
+				}
+				key := rule.Name + "{" + formatLabels(labels) + "}"
+				seen[key] = true
+				a, ok := e.active[key]
+				if !ok {
+					a = &alert{rule: rule.Name, labels: labels, activeSince: now}
+					e.active[key] = a
+				}
+				a.value = value
+				if !a.firing && now.Sub(a.activeSince) >= rule.For {
+					a.firing = true
+					log.Warnf("Alert %s is firing for %s", rule.Name, formatLabels(labels))
+					pending = append(pending, newAlertNotification("firing", rule, a, now))
+				}
+				if a.firing {
+					firing++
+				}
+			}
+		}
+		alertsFiring.WithLabelValues(rule.Name).Set(float64(firing))
+	}
+
+	for key, a := range e.active {
+		if seen[key] {
+			continue
+		}
+		delete(e.active, key)
+		if !a.firing {
+			continue
+		}
+		// rules removed from the configuration are not notified as resolved
+		for _, rule := range rules {
+			if rule.Name == a.rule {
+				log.Infof("Alert %s is resolved for %s", a.rule, formatLabels(a.labels))
+				pending = append(pending, newAlertNotification("resolved", rule, a, now))
+			}
+		}
+	}
+	return pending
+}
+
+// sampleValue returns the value of a gauge, counter, or untyped metric.
+func sampleValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+// matchLabels returns true if the labels have all of the matched label values.
+func matchLabels(labels map[string]string, match map[string]string) bool {
+	for name, value := range match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabels formats the labels as name="value" pairs sorted by name.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// newAlertNotification returns the notification of the alert with the status.
+// The labels of an alert are not changed once it is active, so the
+// notification can be sent after the mutex is released.
+func newAlertNotification(status string, rule AlertRule, a *alert, now time.Time) alertNotification {
+	return alertNotification{
+		Status:      status,
+		Alert:       rule.Name,
+		Labels:      a.labels,
+		Value:       a.value,
+		Message:     rule.Message,
+		ActiveSince: float64(a.activeSince.UnixNano()) / 1e9,
+		Time:        float64(now.UnixNano()) / 1e9,
+	}
+}
+
+// notify sends the notification to the webhook and Telegram, if configured.
+func (e *alertEvaluator) notify(notifications NotificationConfig, n alertNotification) {
+	if notifications.WebhookURL != "" {
+		body, _ := json.Marshal(n)
+		if err := e.post(notifications.WebhookURL, "application/json", body); err != nil {
+			log.Errorf("Unable to send alert %s to the webhook: %v", n.Alert, err)
+			alertNotificationsFailed.WithLabelValues("webhook").Inc()
+		}
+	}
+	if notifications.Telegram.BotToken != "" && notifications.Telegram.ChatID != "" {
+		text := fmt.Sprintf("[%s] %s %s", strings.ToUpper(n.Status), n.Alert, formatLabels(n.Labels))
+		if n.Message != "" {
+			text += "\n" + n.Message
+		}
+		form := url.Values{"chat_id": {notifications.Telegram.ChatID}, "text": {text}}
+		endpoint := "https://api.telegram.org/bot" + notifications.Telegram.BotToken + "/sendMessage"
+		if err := e.post(endpoint, "application/x-www-form-urlencoded", []byte(form.Encode())); err != nil {
+			// the error includes the URL with the bot token
+			log.Errorf("Unable to send alert %s to Telegram", n.Alert)
+			alertNotificationsFailed.WithLabelValues("telegram").Inc()
+		}
+	}
+}
+
+func (e *alertEvaluator) post(endpoint string, contentType string, body []byte) error {
+	res, err := e.client.Post(endpoint, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s returned HTTP status %s", endpoint, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var (
+	collectTarget  string
+	collectModules []string
+)
+
+var collectCmd = &cobra.Command{
+	Use:   "collect",
+	Short: "Collect metrics from a target once and print them",
+	Long: `Collect metrics from a Klipper target once and print them to stdout in the
+Prometheus text exposition format.`,
+	Example: "  prometheus-klipper-exporter collect --target klipper.local:7125 --modules printer_objects",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mfs, err := gather(cmd.Context(), collectTarget, collectModules)
+		if err != nil {
+			return err
+		}
+		for _, mf := range mfs {
+			if _, err := expfmt.MetricFamilyToText(os.Stdout, mf); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that a target is reachable and report the series per module",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := collector.New(cmd.Context(), collectTarget, collectModules, apiKey(""), collectorOptions())
+		info, err := c.ServerInfo()
+		if err != nil {
+			return fmt.Errorf("unable to reach Moonraker on %s: %v", collectTarget, err)
+		}
+		fmt.Printf("Moonraker %s (API %s) on %s\n", info.Result.MoonrakerVersion, info.Result.APIVersionString, collectTarget)
+		fmt.Printf("Klippy connected: %t, state: %s\n", info.Result.KlippyConnected, info.Result.KlippyState)
+		fmt.Printf("Components: %s\n", strings.Join(info.Result.Components, ", "))
+		if len(info.Result.FailedComponents) > 0 {
+			fmt.Printf("Failed components: %s\n", strings.Join(info.Result.FailedComponents, ", "))
+		}
+
+		failed := 0
+		for _, module := range collectModules {
+			mfs, err := gather(cmd.Context(), collectTarget, []string{module})
+			series := 0
+			for _, mf := range mfs {
+				series += len(mf.Metric)
+			}
+			if err != nil {
+				failed++
+				fmt.Printf("  %-16s FAILED %v\n", module, err)
+				continue
+			}
+			if series == 0 {
+				failed++
+				fmt.Printf("  %-16s FAILED no series collected\n", module)
+				continue
+			}
+			fmt.Printf("  %-16s OK     %d series\n", module, series)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d modules failed", failed, len(collectModules))
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{collectCmd, checkCmd} {
+		cmd.Flags().StringVar(&collectTarget, "target", "", "Klipper host to collect from, e.g. klipper.local:7125")
+		cmd.Flags().StringSliceVar(&collectModules, "modules", collector.DefaultModules(), "Modules to collect.")
+		cmd.MarkFlagRequired("target")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+// statusMetricPrefixes are the prefixes of the metrics reported for every
+// scrape regardless of the modules collected.
+var statusMetricPrefixes = []string{"klipper_module_", "klipper_moonraker_rate_limit", "klipper_moonraker_up", "klipper_klippy_"}
+
+// gather collects the modules from the target and returns the metric families
+// excluding the status metrics.
+func gather(ctx context.Context, target string, modules []string) ([]*dto.MetricFamily, error) {
+	registry := prometheus.NewRegistry()
+	c := collector.New(ctx, target, modules, apiKey(""), collectorOptions())
+	if err := registry.Register(c); err != nil {
+		return nil, err
+	}
+	mfs, err := helpGatherer{registry}.Gather()
+	if err != nil {
+		return nil, err
+	}
+	filtered := []*dto.MetricFamily{}
+	for _, mf := range mfs {
+		if !isStatusMetric(mf.GetName()) {
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, nil
+}
+
+func isStatusMetric(name string) bool {
+	for _, prefix := range statusMetricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
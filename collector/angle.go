@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectAngles exports the calibration state of each angle sensor from the
+// printer.cfg settings and the sensor temperature if reported. An angle sensor
+// that is not calibrated with ANGLE_CALIBRATE reports the raw angle including
+// the stepper phase errors, which hides the position deviations when hunting
+// the cause of layer shifts. The measured position deviations themselves are
+// only available from the Klipper `angle/dump_angle` API used by
+// ANGLE_DEBUG_READ, which is not available through Moonraker.
+func (c Collector) collectAngles(ch chan<- prometheus.Metric, module string, angles map[string]moonraker.PrinterObjectAngle) {
+	if len(angles) == 0 {
+		return
+	}
+	config := c.printerConfig(module)
+	sensorLabels := []string{"sensor"}
+	for name, angle := range angles {
+		sensorName := getValidLabelName(name)
+		settings := config["angle "+strings.ToLower(name)]
+		points := calibrationPoints(settings["calibrate"])
+		sensorType, _ := settings["sensor_type"].(string)
+		stepper, _ := settings["stepper"].(string)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_angle_info", "The type of the angle sensor and the stepper it is calibrated for.", []string{"sensor", "sensor_type", "stepper"}, nil),
+			prometheus.GaugeValue,
+			1,
+			sensorName, sensorType, stepper)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_angle_calibrated", "Set to 1 if the angle sensor has been calibrated with ANGLE_CALIBRATE.", sensorLabels, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(points > 0),
+			sensorName)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_angle_calibration_points", "Number of full step positions in the angle sensor calibration.", sensorLabels, nil),
+			prometheus.GaugeValue,
+			float64(points),
+			sensorName)
+		if angle.Temperature != nil && !temperatureFault(*angle.Temperature) {
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_angle_temperature_celsius", "The temperature reported by the angle sensor.", sensorLabels, nil),
+				prometheus.GaugeValue,
+				*angle.Temperature,
+				sensorName)
+		}
+	}
+}
+
+// calibrationPoints returns the number of values in the `calibrate` setting
+// of an angle sensor, which Klipper reports either as a list or as the comma
+// separated string saved by SAVE_CONFIG.
+func calibrationPoints(value interface{}) int {
+	switch calibrate := value.(type) {
+	case []interface{}:
+		return len(calibrate)
+	case string:
+		points := 0
+		for _, v := range strings.FieldsFunc(calibrate, func(r rune) bool { return r == ',' || r == '\n' }) {
+			if strings.TrimSpace(v) != "" {
+				points++
+			}
+		}
+		return points
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// travelAxes are the toolhead position axes travel is estimated for.
+var travelAxes = []string{"x", "y", "z"}
+
+// collectAxisTravel estimates the cumulative travel of each axis from the
+// change in the toolhead position between scrapes. Moves between scrapes are
+// not observed, so the estimate is a lower bound of the actual travel that
+// becomes more accurate with shorter scrape intervals, but it is a useful wear
+// indicator for scheduling linear rail and belt maintenance by distance. The
+// travel of each axis since the previous scrape is returned.
+func (c Collector) collectAxisTravel(ch chan<- prometheus.Metric, position []float64) map[string]float64 {
+	travel := make(map[string]float64)
+	if len(position) < len(travelAxes) {
+		return travel
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if len(state.lastToolheadPosition) >= len(travelAxes) {
+		for i, axis := range travelAxes {
+			travel[axis] = math.Abs(position[i] - state.lastToolheadPosition[i])
+			state.axisTravel[axis] += travel[axis]
+		}
+	}
+	state.lastToolheadPosition = append([]float64{}, position...)
+
+	travelDesc := prometheus.NewDesc("klipper_axis_travel_millimeters_total", "Estimated total travel in millimeters of the axis, from the change in toolhead position between scrapes.", []string{"axis"}, nil)
+	for _, axis := range travelAxes {
+		sendConstMetric(ch,
+			travelDesc,
+			prometheus.CounterValue,
+			state.axisTravel[axis],
+			axis)
+	}
+	return travel
+}
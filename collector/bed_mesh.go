@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectBedMesh exports when the bed mesh was last calibrated. Klipper does
+// not report the calibration time, so when the mesh is first seen the time of
+// the most recent BED_MESH_CALIBRATE command in the gcode store is used, and
+// afterwards the mesh is considered calibrated whenever the probed matrix
+// changes. Loading a different saved profile also changes the probed matrix.
+func (c Collector) collectBedMesh(ch chan<- prometheus.Metric, module string, mesh *moonraker.PrinterObjectBedMesh) {
+	if mesh == nil || len(mesh.ProbedMatrix) == 0 {
+		return
+	}
+	matrix := fmt.Sprint(mesh.ProbedMatrix)
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	firstSeen := state.bedMeshMatrix == ""
+	state.mu.Unlock()
+
+	calibrated := 0.0
+	if firstSeen {
+		calibrated = c.lastBedMeshCalibrateCommand(module)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if firstSeen {
+		state.bedMeshCalibrated = calibrated
+	} else if matrix != state.bedMeshMatrix {
+		log.Infof("Bed mesh changed on %s", c.target)
+		state.bedMeshCalibrated = float64(time.Now().UnixNano()) / 1e9
+	}
+	state.bedMeshMatrix = matrix
+
+	if state.bedMeshCalibrated == 0 {
+		return
+	}
+	labels := []string{"profile"}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_bed_mesh_last_calibration_timestamp_seconds", "Unix timestamp the bed mesh was last calibrated.", labels, nil),
+		prometheus.GaugeValue,
+		state.bedMeshCalibrated,
+		mesh.ProfileName)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_bed_mesh_calibration_age_seconds", "Seconds since the bed mesh was last calibrated.", labels, nil),
+		prometheus.GaugeValue,
+		float64(time.Now().UnixNano())/1e9-state.bedMeshCalibrated,
+		mesh.ProfileName)
+}
+
+// lastBedMeshCalibrateCommand returns the time of the most recent
+// BED_MESH_CALIBRATE command in the gcode store, or 0 if there is none or the
+// gcode store is not available.
+func (c Collector) lastBedMeshCalibrateCommand(module string) float64 {
+	result, err := c.lookupAPI(module).GcodeStore(gcodeStoreCount)
+	if err != nil {
+		return 0
+	}
+	last := 0.0
+	for _, entry := range result.Result.GcodeStore {
+		fields := strings.Fields(entry.Message)
+		if entry.Type == "command" && len(fields) > 0 && strings.EqualFold(fields[0], "BED_MESH_CALIBRATE") && entry.Time > last {
+			last = entry.Time
+		}
+	}
+	return last
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultCacheTTL is how long the responses of the modules with data that
+// rarely changes are reused before they are requested again.
+var DefaultCacheTTL = map[string]time.Duration{
+	"system_info":    5 * time.Minute,
+	"directory_info": time.Minute,
+	"update_manager": time.Minute,
+}
+
+// cacheHitsTotal counts the responses reused from the cache instead of being
+// requested from Moonraker.
+var cacheHitsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_moonraker_cache_hits_total",
+		Help: "Number of Moonraker responses reused from the cache instead of being requested again.",
+	},
+	[]string{"target", "module"},
+)
+
+// cachedResponse is a successful response that is reused until it expires.
+type cachedResponse struct {
+	res     *moonrakerResponse
+	expires time.Time
+}
+
+// cachedResponse returns the cached response of the request if it has not
+// expired.
+func (c Collector) cachedResponse(key string) (*moonrakerResponse, bool) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	cached, ok := state.responses[key]
+	if !ok || time.Now().After(cached.expires) {
+		return nil, false
+	}
+	return cached.res, true
+}
+
+// cacheResponse caches a successful response for the cache TTL of the module.
+// Responses of modules without a cache TTL are not cached.
+func (c Collector) cacheResponse(module string, key string, res *moonrakerResponse) {
+	ttl := c.opts.CacheTTL[module]
+	if ttl <= 0 || res.statusCode < 200 || res.statusCode > 299 {
+		return
+	}
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.responses == nil {
+		state.responses = make(map[string]cachedResponse)
+	}
+	state.responses[key] = cachedResponse{res: res, expires: time.Now().Add(ttl)}
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectCanbus exports the health of the CAN bus of each mcu configured with
+// a `canbus_uuid`, e.g. a toolhead board, with the mcu name, CAN interface,
+// and uuid as labels. The retransmit and invalid bytes of the mcu connection
+// are exported for all Klipper versions, and the bus error counters and state
+// if Klipper reports the `canbus_stats` of the mcu.
+func (c Collector) collectCanbus(ch chan<- prometheus.Metric, module string, status moonraker.PrinterObjectStatus) {
+	config := c.printerConfig(module)
+	if config == nil {
+		return
+	}
+	mcus := map[string]moonraker.PrinterObjectMcu{"mcu": status.Mcu}
+	for name, mcu := range status.Mcus {
+		mcus[name] = mcu
+	}
+
+	labels := []string{"mcu", "interface", "uuid"}
+	retransmitDesc := prometheus.NewDesc("klipper_canbus_retransmit_bytes_total", "Bytes retransmitted to the CAN bus mcu.", labels, nil)
+	invalidDesc := prometheus.NewDesc("klipper_canbus_invalid_bytes_total", "Invalid bytes received from the CAN bus mcu.", labels, nil)
+	rxErrorDesc := prometheus.NewDesc("klipper_canbus_rx_errors_total", "Receive errors reported by the CAN bus controller of the mcu.", labels, nil)
+	txErrorDesc := prometheus.NewDesc("klipper_canbus_tx_errors_total", "Transmit errors reported by the CAN bus controller of the mcu.", labels, nil)
+	txRetriesDesc := prometheus.NewDesc("klipper_canbus_tx_retries_total", "Transmit retries reported by the CAN bus controller of the mcu.", labels, nil)
+	stateDesc := prometheus.NewDesc("klipper_canbus_bus_state", "The state of the CAN bus reported by the mcu, e.g. active, warn, passive, or off.", append(labels, "state"), nil)
+	for name, mcu := range mcus {
+		section := "mcu"
+		if name != "mcu" {
+			section = "mcu " + strings.ToLower(name)
+		}
+		uuid, ok := config[section]["canbus_uuid"].(string)
+		if !ok || uuid == "" {
+			continue
+		}
+		iface, ok := config[section]["canbus_interface"].(string)
+		if !ok || iface == "" {
+			iface = "can0"
+		}
+		sendConstMetric(ch, retransmitDesc, prometheus.CounterValue, mcu.LastStats.BytesRetransmit, name, iface, uuid)
+		sendConstMetric(ch, invalidDesc, prometheus.CounterValue, mcu.LastStats.BytesInvalid, name, iface, uuid)
+
+		stats, ok := status.CanbusStats[name]
+		if !ok {
+			continue
+		}
+		if stats.RxError != nil {
+			sendConstMetric(ch, rxErrorDesc, prometheus.CounterValue, *stats.RxError, name, iface, uuid)
+		}
+		if stats.TxError != nil {
+			sendConstMetric(ch, txErrorDesc, prometheus.CounterValue, *stats.TxError, name, iface, uuid)
+		}
+		if stats.TxRetries != nil {
+			sendConstMetric(ch, txRetriesDesc, prometheus.CounterValue, *stats.TxRetries, name, iface, uuid)
+		}
+		if stats.BusState != "" {
+			sendConstMetric(ch, stateDesc, prometheus.GaugeValue, 1, name, iface, uuid, stats.BusState)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxIdleConnsPerHost is the number of idle connections kept open to each
+// target, enough for the modules collected in parallel.
+const maxIdleConnsPerHost = 8
+
+// httpClientConfig is the settings the HTTP clients are shared by.
+type httpClientConfig struct {
+	proxy                 string
+	dialTimeout           time.Duration
+	responseHeaderTimeout time.Duration
+}
+
+var httpClients = struct {
+	sync.Mutex
+	clients map[httpClientConfig]*http.Client
+}{clients: make(map[httpClientConfig]*http.Client)}
+
+// sharedHTTPClient returns the HTTP client for the Moonraker requests with the
+// options. Collectors with the same options share a client so the connections
+// to each target are kept alive and reused across modules and scrapes instead
+// of being opened for every request.
+func sharedHTTPClient(opts Options) *http.Client {
+	config := httpClientConfig{dialTimeout: opts.DialTimeout, responseHeaderTimeout: opts.ResponseHeaderTimeout}
+	if opts.ProxyURL != nil {
+		config.proxy = opts.ProxyURL.String()
+	}
+	httpClients.Lock()
+	defer httpClients.Unlock()
+	if client, ok := httpClients.clients[config]; ok {
+		return client
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.ResponseHeaderTimeout = config.responseHeaderTimeout
+	transport.DialContext = (&net.Dialer{Timeout: config.dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+	client := &http.Client{Transport: transport}
+	httpClients.clients[config] = client
+	return client
+}
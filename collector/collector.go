@@ -2,12 +2,19 @@ package collector
 
 import (
 	"context"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/exp/slices"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector/modules"
 )
 
 type Collector struct {
@@ -15,15 +22,163 @@ type Collector struct {
 	target  string
 	modules []string
 	apiKey  string
+	opts    Options
+	// outcome of the modules in the current collection
+	results *moduleResults
+	// client the Moonraker requests are sent with
+	client *http.Client
+	// batch the requests are sent in if JSON-RPC batching is enabled
+	batch *jsonRPCBatch
 }
 
-func New(ctx context.Context, target string, modules []string, apiKey string) *Collector {
-	return &Collector{ctx: ctx, target: target, modules: modules, apiKey: apiKey}
+// Options holds the exporter wide settings applied to every collection.
+type Options struct {
+	// DualEmit also exposes the pre v0.7.0 per entity metric names alongside
+	// the labeled metrics so dashboards can be migrated gradually.
+	DualEmit bool
+	// ProxyURL is the HTTP proxy the Moonraker requests of the target are sent
+	// through. Nil uses the HTTP_PROXY and HTTPS_PROXY environment variables.
+	ProxyURL *url.URL
+	// HeaterWattage is the rated power in watts of each heater, keyed by the
+	// heater name, e.g. `heater_bed`, used to estimate the power drawn.
+	HeaterWattage map[string]float64
+	// PSUCapacity is the power in watts the PSU can supply. 0 does not
+	// report the PSU load.
+	PSUCapacity float64
+	// EmptySpoolWeight is the weight in grams of the empty spool on each
+	// `load_cell` spool scale, keyed by sensor name, to report the weight of
+	// the filament remaining.
+	EmptySpoolWeight map[string]float64
+	// HistoryJobs is the number of the most recent jobs in the print history
+	// the print duration and filament used histograms are built from. 0
+	// does not report the histograms.
+	HistoryJobs int
+	// DurationBuckets and FilamentBuckets are the upper bounds of the print
+	// duration and filament used histogram buckets.
+	DurationBuckets []float64
+	FilamentBuckets []float64
+	// CacheTTL is how long the responses of each module are reused before
+	// they are requested again, keyed by module name. The responses of other
+	// modules are requested on every scrape.
+	CacheTTL map[string]time.Duration
+	// DialTimeout is the maximum time to connect to the target. 0 only
+	// limits the connection by the request timeout.
+	DialTimeout time.Duration
+	// ResponseHeaderTimeout is the maximum time to wait for the response
+	// headers after sending a request. 0 only limits the wait by the request
+	// timeout.
+	ResponseHeaderTimeout time.Duration
+	// JSONRPCBatch sends the requests of the modules collected in parallel to
+	// Moonraker in JSON-RPC batches, to reduce the number of round trips.
+	JSONRPCBatch bool
+	// RequestTimeout is the maximum time of each request to Moonraker. 0
+	// only limits the requests by the scrape context.
+	RequestTimeout time.Duration
+	// ModuleConcurrency is the maximum number of modules of a target that
+	// are collected in parallel. 0 or 1 collects the modules one at a time.
+	ModuleConcurrency int
+	// AutoDisableAfter is the number of consecutive HTTP 404 responses after
+	// which a module is no longer queried for a target. 0 never disables.
+	AutoDisableAfter int
+	// AutoDisableRetry is how long a module stays disabled before it is
+	// queried again. 0 keeps it disabled until the exporter is restarted.
+	AutoDisableRetry time.Duration
+	// MetricsPrefix replaces the `klipper` namespace of the metric names
+	// when set, e.g. to namespace the metrics of several exporters.
+	MetricsPrefix string
+	// Printer is added as a `printer` label to every metric of the target
+	// when set, so metrics can be joined across exporters.
+	Printer string
+	// PrinterNameLabel adds the printer name set in the Mainsail or Fluidd
+	// settings as a `printer_name` label to every metric of the target.
+	PrinterNameLabel bool
+	// DeniedLabels drops the series with any of the label values from all
+	// modules, e.g. to remove a flapping discovered object.
+	DeniedLabels []DeniedLabel
+	// ValueRanges are the plausible ranges of the metric values, keyed by
+	// metric name. Values outside of the range are clamped or dropped.
+	ValueRanges map[string]ValueRange
+	// MaxSeries is the maximum number of series exposed for a single target,
+	// protecting against dynamically discovered objects exploding the series
+	// count. 0 is unlimited.
+	MaxSeries int
+	// HeatSoakTolerance is the maximum difference in degrees between the bed
+	// temperature and target for the bed to be considered heat soaking.
+	HeatSoakTolerance float64
+	// HeatSoakDuration is how long the bed must stay within the tolerance of
+	// the target to be reported as heat soaked.
+	HeatSoakDuration time.Duration
+	// FilamentMotionWindow is the rolling window over which the filament
+	// motion sensor extrusion ratio is calculated.
+	FilamentMotionWindow time.Duration
+	// GcodeStoreMacros are the macros to count executions of in the gcode store.
+	GcodeStoreMacros []string
+	// UserAgent is sent with all Moonraker requests so exporter traffic can be
+	// identified in proxy and Moonraker logs.
+	UserAgent string
+	// RequestTag is sent in the X-Exporter-Tag header of all Moonraker
+	// requests when set, e.g. to identify the scrape job or farm.
+	RequestTag string
+	// DoorButtons are the `gcode_button` names of door or enclosure switches.
+	DoorButtons []string
+	// DoorOpenState is the gcode_button state, PRESSED or RELEASED, reported
+	// while a door is open.
+	DoorOpenState string
+	// SmoothedSensors are the sensor names, e.g. `extruder` or a
+	// temperature_sensor name, to export an exponentially smoothed
+	// temperature for.
+	SmoothedSensors []string
+	// SmoothingTimeConstant is the time constant of the exponential smoothing,
+	// the time for the smoothed value to cover 63% of a step change.
+	SmoothingTimeConstant time.Duration
+	// FanRpmLearningTime is the time constant over which the expected RPM
+	// per PWM ratio of fans with a tachometer is learned. 0 disables the RPM
+	// residual metrics.
+	FanRpmLearningTime time.Duration
+	// HeaterSaturationWindow is the time span of the most recent temperature
+	// store samples the heater saturation is calculated over. 0 disables the
+	// heater saturation metrics.
+	HeaterSaturationWindow time.Duration
+	// SamplingInterval is the interval the SampledSignals are sampled at in
+	// the background between scrapes, to report the minimum, maximum, and
+	// average of each scrape interval. 0 disables the sampling.
+	SamplingInterval time.Duration
+	SampledSignals   []SampledSignal
+	// Scraper identifies the scraper of the collection, e.g. the host of the
+	// Prometheus server, so each scraper of a highly available pair reads
+	// the sampled signals of its own scrape interval.
+	Scraper string
+	// TemperatureLabels exposes the `temperature` module metrics as a single
+	// family per attribute with a `sensor` label, e.g.
+	// `klipper_temperature_celsius{sensor="extruder"}`, instead of including
+	// the sensor name in the metric name.
+	TemperatureLabels bool
+	// McuLabels exposes the mcu statistics of every mcu with an `mcu` label,
+	// e.g. `klipper_mcu_awake{mcu="EBBCan"}`, instead of only the primary mcu.
+	McuLabels bool
+	// Events publishes print state changes when set.
+	Events EventPublisher
+	// Maintenance tracks the usage of the maintenance tasks when set.
+	Maintenance *Maintenance
+	// FilamentTotals accumulates the filament used per material when set.
+	FilamentTotals *FilamentTotals
 }
 
-// Describe implements Prometheus.Collector.
+func New(ctx context.Context, target string, modules []string, apiKey string, opts Options) *Collector {
+	c := &Collector{ctx: ctx, target: target, modules: modules, apiKey: apiKey, opts: opts, results: &moduleResults{}, client: sharedHTTPClient(opts)}
+	if opts.JSONRPCBatch {
+		c.batch = newJSONRPCBatch(*c)
+	}
+	return c
+}
+
+// Describe implements Prometheus.Collector. The Collector is an unchecked
+// collector and sends no descriptors, as the metrics depend on the modules,
+// the printer configuration, and the options, e.g. a metric per configured
+// temperature sensor, which are not known until the target is collected.
+// Registering a Collector always succeeds, and the registry still checks the
+// collected metrics are consistent when they are gathered.
 func (c Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
 }
 
 // Regex to match all invalid characters
@@ -34,509 +189,819 @@ func getValidLabelName(str string) string {
 	return prometheusMetricNameInvalidCharactersRegex.ReplaceAllString(strings.Replace(str, "-", "_", -1), "")
 }
 
+// sendConstMetric sends a constant metric to ch, skipping invalid samples, see
+// modules.SendConstMetric.
+func sendConstMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	modules.SendConstMetric(ch, desc, valueType, value, labelValues...)
+}
+
 // Collect implements Prometheus.Collector.
 func (c Collector) Collect(ch chan<- prometheus.Metric) {
-
-	// Process Stats (and Network Stats)
-	if slices.Contains(c.modules, "process_stats") || slices.Contains(c.modules, "network_stats") {
-
-		log.Infof("Collecting process_stats for %s", c.target)
-
-		result, err := c.fetchMoonrakerProcessStats(c.target, c.apiKey)
-		if err != nil {
-			log.Error(err)
-			return
+	if c.opts.MaxSeries > 0 {
+		limited, done := c.limitSeries(ch, c.opts.MaxSeries)
+		defer func() {
+			close(limited)
+			<-done
+		}()
+		ch = limited
+	}
+	if c.opts.Printer != "" {
+		labeled, done := c.addLabels(ch, map[string]string{"printer": c.opts.Printer})
+		defer func() {
+			close(labeled)
+			<-done
+		}()
+		ch = labeled
+	}
+	if c.opts.PrinterNameLabel {
+		if name := c.printerName(); name != "" {
+			labeled, done := c.addLabels(ch, map[string]string{"printer_name": name})
+			defer func() {
+				close(labeled)
+				<-done
+			}()
+			ch = labeled
 		}
+	}
+	// denied series are dropped before the series limit is applied
+	if len(c.opts.DeniedLabels) > 0 {
+		filtered, done := c.denySeries(ch, c.opts.DeniedLabels)
+		defer func() {
+			close(filtered)
+			<-done
+		}()
+		ch = filtered
+	}
+	if c.opts.MetricsPrefix != "" && c.opts.MetricsPrefix != DefaultMetricsPrefix {
+		c.collectWithPrefix(ch, c.opts.MetricsPrefix)
+		return
+	}
+	c.collect(ch)
+}
 
-		// Process Stats
-		if slices.Contains(c.modules, "process_stats") {
-			memUnits := result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].MemUnits
-			if memUnits != "kB" {
-				log.Errorf("Unexpected units %s for Moonraker memory usage", memUnits)
-			} else {
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("klipper_moonraker_memory_kb", "Moonraker memory usage in Kb.", nil, nil),
-					prometheus.GaugeValue,
-					float64(result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].Memory))
-			}
+func (c Collector) collect(ch chan<- prometheus.Metric) {
+	// values are sanitized before the metric names are prefixed
+	if len(c.opts.ValueRanges) > 0 {
+		sanitized, done := c.sanitizeValues(ch, c.opts.ValueRanges)
+		defer func() {
+			close(sanitized)
+			<-done
+		}()
+		ch = sanitized
+	}
+	c.recordScrape()
+	c.results.reset()
 
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_moonraker_cpu_usage", "Moonraker CPU usage.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].CpuUsage)
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_moonraker_websocket_connections", "Moonraker Websocket connection count.", nil, nil),
-				prometheus.GaugeValue,
-				float64(result.Result.WebsocketConnections))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_cpu_temp", "Klipper system CPU temperature in celsius.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.CpuTemp)
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_cpu", "Klipper system CPU usage.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.SystemCpuUsage.Cpu)
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_memory_total", "Klipper system total memory.", nil, nil),
-				prometheus.GaugeValue,
-				float64(result.Result.SystemMemory.Total))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_memory_available", "Klipper system available memory.", nil, nil),
-				prometheus.GaugeValue,
-				float64(result.Result.SystemMemory.Available))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_memory_used", "Klipper system used memory.", nil, nil),
-				prometheus.GaugeValue,
-				float64(result.Result.SystemMemory.Used))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_system_uptime", "Klipper system uptime.", nil, nil),
-				prometheus.CounterValue,
-				result.Result.SystemUptime)
-		}
+	// Moonraker and Klippy health
+	c.collectUp(ch)
 
-		// Network Stats
-		if slices.Contains(c.modules, "network_stats") {
-			networkLabels := []string{"interface"}
-			rxBytes := prometheus.NewDesc("klipper_network_rx_bytes", "Klipper network received bytes.", networkLabels, nil)
-			txBytes := prometheus.NewDesc("klipper_network_tx_bytes", "Klipper network transmitted bytes.", networkLabels, nil)
-			rxPackets := prometheus.NewDesc("klipper_network_rx_packets", "Klipper network received packets.", networkLabels, nil)
-			txPackets := prometheus.NewDesc("klipper_network_tx_packets", "Klipper network transmitted packets.", networkLabels, nil)
-			rxErrs := prometheus.NewDesc("klipper_network_rx_errs", "Klipper network received errored packets.", networkLabels, nil)
-			txErrs := prometheus.NewDesc("klipper_network_tx_errs", "Klipper network transmitted errored packets.", networkLabels, nil)
-			rxDrop := prometheus.NewDesc("klipper_network_rx_drop", "Klipper network received dropped packets.", networkLabels, nil)
-			txDrop := prometheus.NewDesc("klipper_network_tx_drop", "Klipper network transmitted dropped packets.", networkLabels, nil)
-			bandwidth := prometheus.NewDesc("klipper_network_bandwidth", "Klipper network bandwidth.", networkLabels, nil)
-			for key, element := range result.Result.Network {
-				interfaceName := getValidLabelName(key)
-				ch <- prometheus.MustNewConstMetric(
-					rxBytes,
-					prometheus.CounterValue,
-					float64(element.RxBytes),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					txBytes,
-					prometheus.CounterValue,
-					float64(element.TxBytes),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					rxPackets,
-					prometheus.CounterValue,
-					float64(element.RxPackets),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					txPackets,
-					prometheus.CounterValue,
-					float64(element.TxPackets),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					rxErrs,
-					prometheus.CounterValue,
-					float64(element.RxErrs),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					txErrs,
-					prometheus.CounterValue,
-					float64(element.TxErrs),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					rxDrop,
-					prometheus.CounterValue,
-					float64(element.RxDrop),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					txDrop,
-					prometheus.CounterValue,
-					float64(element.TxDrop),
-					interfaceName)
-				ch <- prometheus.MustNewConstMetric(
-					bandwidth,
-					prometheus.GaugeValue,
-					element.Bandwidth,
-					interfaceName)
-			}
-		}
+	var tasks []moduleTask
+
+	// Process Stats (and Network Stats)
+	if c.enabled("process_stats") || c.enabled("network_stats") {
+		tasks = append(tasks, moduleTask{"process_stats", c.collectProcessStats})
 	}
 
 	// Directory Information
-	if slices.Contains(c.modules, "directory_info") {
-		log.Infof("Collecting directory_info for %s", c.target)
-		result, _ := c.fetchMoonrakerDirectoryInfo(c.target, c.apiKey)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_disk_usage_total", "Klipper total disk space.", nil, nil),
-			prometheus.GaugeValue,
-			float64(result.Result.DiskUsage.Total))
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_disk_usage_used", "Klipper used disk space.", nil, nil),
-			prometheus.GaugeValue,
-			float64(result.Result.DiskUsage.Used))
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_disk_usage_available", "Klipper available disk space.", nil, nil),
-			prometheus.GaugeValue,
-			float64(result.Result.DiskUsage.Free))
+	if c.enabled("directory_info") {
+		tasks = append(tasks, moduleTask{"directory_info", c.emit("directory_info", modules.DirectoryInfo)})
 	}
 
 	// Job Queue
-	if slices.Contains(c.modules, "job_queue") {
-		log.Infof("Collecting job_queue for %s", c.target)
-		result, _ := c.fetchMoonrakerJobQueue(c.target, c.apiKey)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_job_queue_length", "Klipper job queue length.", nil, nil),
-			prometheus.GaugeValue,
-			float64(len(result.Result.QueuedJobs)))
+	if c.enabled("job_queue") {
+		tasks = append(tasks, moduleTask{"job_queue", c.collectJobQueue})
 	}
 
 	// Job History
-	if slices.Contains(c.modules, "history") {
-		log.Infof("Collecting history for %s", c.target)
-		result, _ := c.fetchMoonrakerHistory(c.target, c.apiKey)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_total_jobs", "Klipper number of total jobs.", nil, nil),
-			prometheus.GaugeValue,
-			float64(result.Result.JobTotals.Jobs))
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_total_time", "Klipper total time.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.JobTotals.TotalTime)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_total_print_time", "Klipper total print time.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.JobTotals.PrintTime)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_total_filament_used", "Klipper total meters of filament used.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.JobTotals.FilamentUsed)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_longest_job", "Klipper total longest job.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.JobTotals.LongestJob)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_longest_print", "Klipper total longest print.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.JobTotals.LongestPrint)
+	if c.enabled("history") {
+		tasks = append(tasks, moduleTask{"history", c.collectHistory})
 	}
 
 	// Current Print from Job History
-	if slices.Contains(c.modules, "history") {
-		log.Infof("Collecting active print for %s", c.target)
-		result, _ := c.fetchMoonrakerHistoryCurrent(c.target, c.apiKey)
-		if len(result.Result.Jobs) >= 1 {
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_current_print_object_height", "Klipper current print object height", nil, nil),
-				prometheus.GaugeValue,
-				c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.ObjectHeight))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_current_print_first_layer_height", "Klipper current print first layer height", nil, nil),
-				prometheus.GaugeValue,
-				c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.FirstLayerHeight))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_current_print_layer_height", "Klipper current print layer height", nil, nil),
-				prometheus.GaugeValue,
-				c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.LayerHeight))
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_current_print_total_duration", "Klipper current print total duration", nil, nil),
-				prometheus.GaugeValue,
-				c.checkConditionStatusPrint(result, result.Result.Jobs[0].TotalDuration))
-		}
+	if c.enabled("history") {
+		tasks = append(tasks, moduleTask{"history", c.collectHistoryCurrent})
+	}
+
+	// Last finished job from Job History
+	if c.enabled("history") {
+		tasks = append(tasks, moduleTask{"history", c.collectLastJob})
+	}
+
+	// Print duration and filament used distribution from Job History
+	if c.enabled("history") && c.opts.HistoryJobs > 0 {
+		tasks = append(tasks, moduleTask{"history", c.collectHistoryHistograms})
+	}
+
+	// Last completed job from Job History
+	if c.enabled("history") {
+		tasks = append(tasks, moduleTask{"history", c.collectLastSuccess})
 	}
 
 	// System Info
-	if slices.Contains(c.modules, "system_info") {
-		log.Infof("Collecting system_info for %s", c.target)
-		result, _ := c.fetchMoonrakerSystemInfo(c.target, c.apiKey)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_system_cpu_count", "Klipper system CPU count.", nil, nil),
-			prometheus.GaugeValue,
-			float64(result.Result.SystemInfo.CpuInfo.CpuCount))
+	if c.enabled("system_info") {
+		tasks = append(tasks, moduleTask{"system_info", c.collectSystemInfo})
 	}
 
 	// Temperature Store
 	// (deprecated since v0.8.0, use `printer_objects` instead)
-	if slices.Contains(c.modules, "temperature") {
-		log.Infof("Collecting system_info for %s", c.target)
-		result, _ := c.fetchTemperatureData(c.target, c.apiKey)
-
-		for k, v := range result.Result {
-			item := strings.ReplaceAll(k, " ", "_")
-			attributes := v.(map[string]interface{})
-			for k1, v1 := range attributes {
-				values := v1.([]interface{})
-				label := strings.ReplaceAll(k1[0:len(k1)-1], " ", "_")
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("klipper_"+item+"_"+label, "Klipper "+k+" "+label, nil, nil),
-					prometheus.GaugeValue,
-					values[len(values)-1].(float64))
-			}
-		}
+	if c.enabled("temperature") {
+		tasks = append(tasks, moduleTask{"temperature", c.collectTemperature})
 	}
 
 	// Printer Objects
-	if slices.Contains(c.modules, "printer_objects") {
-		log.Infof("Collecting printer_objects for %s", c.target)
-		result, _ := c.fetchMoonrakerPrinterObjects(c.target, c.apiKey)
+	if c.enabled("printer_objects") {
+		tasks = append(tasks, moduleTask{"printer_objects", c.collectPrinterObjects})
+	}
 
-		// gcode_move
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_gcode_speed_factor", "Klipper gcode speed factor.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.GcodeMove.SpeedFactor)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_gcode_speed", "Klipper gcode speed.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.GcodeMove.Speed)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_gcode_extrude_factor", "Klipper gcode extrude factor.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.GcodeMove.ExtrudeFactor)
+	// Gcode Store
+	if c.enabled("gcode_store") {
+		tasks = append(tasks, moduleTask{"gcode_store", c.collectGcodeStore})
+	}
 
-		// gcode position
-		if len(result.Result.Status.GcodeMove.GcodePosition) >= 4 {
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_gcode_position_x", "Klipper gcode position X axis.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.Status.GcodeMove.GcodePosition[0])
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_gcode_position_y", "Klipper gcode position Y axis.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.Status.GcodeMove.GcodePosition[1])
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_gcode_position_z", "Klipper gcode position Z axis.", nil, nil),
-				prometheus.GaugeValue,
-				result.Result.Status.GcodeMove.GcodePosition[2])
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc("klipper_gcode_position_e", "Klipper gcode position for extruder.", nil, nil),
+	// Server Info
+	if c.enabled("server_info") {
+		tasks = append(tasks, moduleTask{"server_info", c.emit("server_info", modules.ServerInfo)})
+	}
+
+	// Log Files
+	if c.enabled("logs") {
+		tasks = append(tasks, moduleTask{"logs", c.emit("logs", modules.Logs)})
+	}
+
+	// Power Devices
+	if c.enabled("power") {
+		tasks = append(tasks, moduleTask{"power", c.emit("power", modules.Power)})
+	}
+
+	// Update Manager
+	if c.enabled("update_manager") {
+		tasks = append(tasks, moduleTask{"update_manager", c.emit("update_manager", modules.UpdateManager)})
+	}
+
+	// Webcams
+	if c.enabled("webcams") {
+		tasks = append(tasks, moduleTask{"webcams", c.emit("webcams", modules.Webcams)})
+	}
+
+	// Announcements
+	if c.enabled("announcements") {
+		tasks = append(tasks, moduleTask{"announcements", c.emit("announcements", modules.Announcements)})
+	}
+
+	// Agents
+	if c.enabled("agents") {
+		tasks = append(tasks, moduleTask{"agents", c.emit("agents", modules.Agents)})
+	}
+
+	c.collectModules(ch, tasks)
+
+	// Module status
+	c.collectModuleStatus(ch)
+	c.collectRateLimit(ch)
+}
+
+// moduleTask is the collect function of an enabled module.
+type moduleTask struct {
+	module  string
+	collect func(ch chan<- prometheus.Metric)
+}
+
+// emit returns the collect function of a module that is only collected from
+// the Moonraker API responses, see the modules package.
+func (c Collector) emit(module string, emitter modules.Emitter) func(ch chan<- prometheus.Metric) {
+	return func(ch chan<- prometheus.Metric) {
+		log.Infof("Collecting %s for %s", module, c.target)
+		// the failed requests are logged and recorded by fetch
+		_ = emitter(c.api(module), ch)
+	}
+}
+
+// collectModules runs the collect functions of the modules concurrently, at
+// most ModuleConcurrency at a time, and waits for all of them to complete.
+func (c Collector) collectModules(ch chan<- prometheus.Metric, tasks []moduleTask) {
+	concurrency := c.opts.ModuleConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	workers := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(task moduleTask) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			c.collectModule(ch, task.module, task.collect)
+		}(task)
+	}
+	wg.Wait()
+}
+
+// collectModule runs the collect function of a module. A panic in the module,
+// e.g. from an unexpected response, is logged and only the remaining series of
+// that module are left out, the other modules are still collected.
+func (c Collector) collectModule(ch chan<- prometheus.Metric, module string, collect func(ch chan<- prometheus.Metric)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Collection of %s for %s failed: %v", module, c.target, r)
+			c.results.fail(module)
+		}
+	}()
+	collect(ch)
+}
+
+// collectProcessStats collects the `process_stats` and `network_stats` metrics
+// which are both served from the Moonraker process stats endpoint.
+func (c Collector) collectProcessStats(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting process_stats for %s", c.target)
+
+	result, err := c.api("process_stats").ProcessStats()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// Process Stats
+	if c.enabled("process_stats") {
+		memUnits := result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].MemUnits
+		if memUnits != "kB" {
+			log.Errorf("Unexpected units %s for Moonraker memory usage", memUnits)
+		} else {
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_moonraker_memory_kb", "Moonraker memory usage in Kb.", nil, nil),
 				prometheus.GaugeValue,
-				result.Result.Status.GcodeMove.GcodePosition[3])
+				float64(result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].Memory))
 		}
-		// mcu
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_awake", "Klipper mcu awake.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.McuAwake)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_write_bytes", "Klipper mcu write bytes.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.BytesWrite)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_read_bytes", "Klipper mcu read bytes.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.BytesRead)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_retransmit_bytes", "Klipper mcu retransmit bytes.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.BytesRetransmit)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_invalid_bytes", "Klipper mcu invalid bytes.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.BytesInvalid)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_send_seq", "Klipper mcu send sequence.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.SendSeq)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_receive_seq", "Klipper mcu receive sequence.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.ReceiveSeq)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_retransmit_seq", "Klipper mcu retransmit sequence.", nil, nil),
+
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_moonraker_cpu_usage", "Moonraker CPU usage.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.RetransmitSeq)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_srtt", "Klipper mcu smoothed round trip time.", nil, nil),
+			result.Result.MoonrakerStats[len(result.Result.MoonrakerStats)-1].CpuUsage)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_moonraker_websocket_connections", "Moonraker Websocket connection count.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.Srtt)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_rttvar", "Klipper mcu round trip time variance.", nil, nil),
+			float64(result.Result.WebsocketConnections))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_cpu_temp", "Klipper system CPU temperature in celsius.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.Rttvar)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_rto", "Klipper mcu retransmission timeouts.", nil, nil),
+			result.Result.CpuTemp)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_cpu", "Klipper system CPU usage.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.Rto)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_ready_bytes", "Klipper mcu ready bytes.", nil, nil),
+			result.Result.SystemCpuUsage.Cpu)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_memory_total", "Klipper system total memory.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.ReadyBytes)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_stalled_bytes", "Klipper mcu stalled bytes.", nil, nil),
+			float64(result.Result.SystemMemory.Total))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_memory_available", "Klipper system available memory.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.StalledBytes)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_mcu_clock_frequency", "Klipper mcu clock frequency.", nil, nil),
+			float64(result.Result.SystemMemory.Available))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_memory_used", "Klipper system used memory.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Mcu.LastStats.Freq)
+			float64(result.Result.SystemMemory.Used))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_uptime", "Klipper system uptime.", nil, nil),
+			prometheus.CounterValue,
+			result.Result.SystemUptime)
+	}
 
-		// toolhead
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_print_time", "Klipper toolhead print time.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.PrintTime)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_estimated_print_time", "Klipper estimated print time.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.EstimatedPrintTime)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_max_velocity", "Klipper toolhead max velocity.", nil, nil),
+	// Network Stats
+	if c.enabled("network_stats") {
+		networkLabels := []string{"interface"}
+		rxBytes := prometheus.NewDesc("klipper_network_rx_bytes", "Klipper network received bytes.", networkLabels, nil)
+		txBytes := prometheus.NewDesc("klipper_network_tx_bytes", "Klipper network transmitted bytes.", networkLabels, nil)
+		rxPackets := prometheus.NewDesc("klipper_network_rx_packets", "Klipper network received packets.", networkLabels, nil)
+		txPackets := prometheus.NewDesc("klipper_network_tx_packets", "Klipper network transmitted packets.", networkLabels, nil)
+		rxErrs := prometheus.NewDesc("klipper_network_rx_errs", "Klipper network received errored packets.", networkLabels, nil)
+		txErrs := prometheus.NewDesc("klipper_network_tx_errs", "Klipper network transmitted errored packets.", networkLabels, nil)
+		rxDrop := prometheus.NewDesc("klipper_network_rx_drop", "Klipper network received dropped packets.", networkLabels, nil)
+		txDrop := prometheus.NewDesc("klipper_network_tx_drop", "Klipper network transmitted dropped packets.", networkLabels, nil)
+		bandwidth := prometheus.NewDesc("klipper_network_bandwidth", "Klipper network bandwidth.", networkLabels, nil)
+		for key, element := range result.Result.Network {
+			interfaceName := getValidLabelName(key)
+			sendConstMetric(ch,
+				rxBytes,
+				prometheus.CounterValue,
+				float64(element.RxBytes),
+				interfaceName)
+			sendConstMetric(ch,
+				txBytes,
+				prometheus.CounterValue,
+				float64(element.TxBytes),
+				interfaceName)
+			sendConstMetric(ch,
+				rxPackets,
+				prometheus.CounterValue,
+				float64(element.RxPackets),
+				interfaceName)
+			sendConstMetric(ch,
+				txPackets,
+				prometheus.CounterValue,
+				float64(element.TxPackets),
+				interfaceName)
+			sendConstMetric(ch,
+				rxErrs,
+				prometheus.CounterValue,
+				float64(element.RxErrs),
+				interfaceName)
+			sendConstMetric(ch,
+				txErrs,
+				prometheus.CounterValue,
+				float64(element.TxErrs),
+				interfaceName)
+			sendConstMetric(ch,
+				rxDrop,
+				prometheus.CounterValue,
+				float64(element.RxDrop),
+				interfaceName)
+			sendConstMetric(ch,
+				txDrop,
+				prometheus.CounterValue,
+				float64(element.TxDrop),
+				interfaceName)
+			sendConstMetric(ch,
+				bandwidth,
+				prometheus.GaugeValue,
+				element.Bandwidth,
+				interfaceName)
+
+			if c.opts.DualEmit {
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_rx_bytes", "Klipper network received bytes.", prometheus.CounterValue, float64(element.RxBytes))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_tx_bytes", "Klipper network transmitted bytes.", prometheus.CounterValue, float64(element.TxBytes))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_rx_packets", "Klipper network received packets.", prometheus.CounterValue, float64(element.RxPackets))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_tx_packets", "Klipper network transmitted packets.", prometheus.CounterValue, float64(element.TxPackets))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_rx_errs", "Klipper network received errored packets.", prometheus.CounterValue, float64(element.RxErrs))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_tx_errs", "Klipper network transmitted errored packets.", prometheus.CounterValue, float64(element.TxErrs))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_rx_drop", "Klipper network received dropped packets.", prometheus.CounterValue, float64(element.RxDrop))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_tx_drop", "Klipper network transmitted dropped packets.", prometheus.CounterValue, float64(element.TxDrop))
+				c.emitLegacy(ch, "klipper_network_", interfaceName, "_bandwidth", "Klipper network bandwidth.", prometheus.GaugeValue, element.Bandwidth)
+			}
+		}
+	}
+}
+
+func (c Collector) collectJobQueue(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting job_queue for %s", c.target)
+	result, err := c.api("job_queue").JobQueue()
+	if err != nil {
+		return
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_job_queue_length", "Klipper job queue length.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.QueuedJobs)))
+	c.collectQueuedJobs(ch, result.Result.QueuedJobs)
+}
+
+func (c Collector) collectHistory(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting history for %s", c.target)
+	result, err := c.api("history").HistoryTotals()
+	if err != nil {
+		return
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_total_jobs", "Klipper number of total jobs.", nil, nil),
+		prometheus.GaugeValue,
+		float64(result.Result.JobTotals.Jobs))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_total_time", "Klipper total time.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.JobTotals.TotalTime)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_total_print_time", "Klipper total print time.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.JobTotals.PrintTime)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_total_filament_used", "Klipper total meters of filament used.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.JobTotals.FilamentUsed)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_longest_job", "Klipper total longest job.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.JobTotals.LongestJob)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_longest_print", "Klipper total longest print.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.JobTotals.LongestPrint)
+
+	// The totals with base units in the name. The names above are kept for
+	// existing dashboards.
+	totals := result.Result.JobTotals
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_total_jobs", "Total number of jobs in the print history.", nil, nil),
+		prometheus.GaugeValue,
+		totals.Jobs)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_total_print_time_seconds", "Total print time of the jobs in the print history.", nil, nil),
+		prometheus.GaugeValue,
+		totals.PrintTime)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_total_filament_used_mm", "Total millimeters of filament used by the jobs in the print history.", nil, nil),
+		prometheus.GaugeValue,
+		totals.FilamentUsed)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_longest_print_seconds", "Print time of the longest print in the print history.", nil, nil),
+		prometheus.GaugeValue,
+		totals.LongestPrint)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_longest_job_seconds", "Total duration of the longest job in the print history.", nil, nil),
+		prometheus.GaugeValue,
+		totals.LongestJob)
+}
+
+func (c Collector) collectHistoryCurrent(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting active print for %s", c.target)
+	result, err := c.api("history").HistoryCurrent()
+	if err != nil {
+		return
+	}
+	if len(result.Result.Jobs) >= 1 {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_current_print_object_height", "Klipper current print object height", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.MaxVelocity)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_max_accel", "Klipper toolhead max acceleration.", nil, nil),
+			c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.ObjectHeight))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_current_print_first_layer_height", "Klipper current print first layer height", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.MaxAccel)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_max_accel_to_decel", "Klipper toolhead max acceleration to deceleration.", nil, nil),
+			c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.FirstLayerHeight))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_current_print_layer_height", "Klipper current print layer height", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.MaxAccelToDecel)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_toolhead_square_corner_velocity", "Klipper toolhead square corner velocity.", nil, nil),
+			c.checkConditionStatusPrint(result, result.Result.Jobs[0].Metadata.LayerHeight))
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_current_print_total_duration", "Klipper current print total duration", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Toolhead.SquareCornerVelocity)
+			c.checkConditionStatusPrint(result, result.Result.Jobs[0].TotalDuration))
+	}
+}
+
+func (c Collector) collectSystemInfo(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting system_info for %s", c.target)
+	result, err := c.api("system_info").SystemInfo()
+	if err != nil {
+		return
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_system_cpu_count", "Klipper system CPU count.", nil, nil),
+		prometheus.GaugeValue,
+		float64(result.Result.SystemInfo.CpuInfo.CpuCount))
+	c.collectServices(ch, result.Result.SystemInfo.ServiceState)
+	c.collectSystemDetails(ch, result)
+}
+
+func (c Collector) collectTemperature(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting system_info for %s", c.target)
+	result, err := c.api("temperature").TemperatureStore()
+	if err != nil {
+		return
+	}
+
+	c.collectTemperatureStoreWindow(ch, result)
+	c.collectHeaterSaturation(ch, "temperature", result)
+
+	if c.opts.TemperatureLabels {
+		c.collectLabeledTemperature(ch, result)
+		if !c.opts.DualEmit {
+			return
+		}
+	}
+
+	for k, v := range result.Result {
+		item := strings.ReplaceAll(k, " ", "_")
+		attributes := v.(map[string]interface{})
+		for k1, v1 := range attributes {
+			values := v1.([]interface{})
+			label := strings.ReplaceAll(k1[0:len(k1)-1], " ", "_")
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_"+item+"_"+label, "Klipper "+k+" "+label, nil, nil),
+				prometheus.GaugeValue,
+				values[len(values)-1].(float64))
+		}
+	}
+}
 
-		// extruder
-		ch <- prometheus.MustNewConstMetric(
+func (c Collector) collectPrinterObjects(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting printer_objects for %s", c.target)
+	result, err := c.fetchMoonrakerPrinterObjects()
+	if err != nil {
+		return
+	}
+
+	// gcode_move
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_gcode_speed_factor", "Klipper gcode speed factor.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.GcodeMove.SpeedFactor)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_gcode_speed", "Klipper gcode speed.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.GcodeMove.Speed)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_gcode_extrude_factor", "Klipper gcode extrude factor.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.GcodeMove.ExtrudeFactor)
+
+	// gcode position
+	if len(result.Result.Status.GcodeMove.GcodePosition) >= 4 {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_position_x", "Klipper gcode position X axis.", nil, nil),
+			prometheus.GaugeValue,
+			result.Result.Status.GcodeMove.GcodePosition[0])
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_position_y", "Klipper gcode position Y axis.", nil, nil),
+			prometheus.GaugeValue,
+			result.Result.Status.GcodeMove.GcodePosition[1])
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_position_z", "Klipper gcode position Z axis.", nil, nil),
+			prometheus.GaugeValue,
+			result.Result.Status.GcodeMove.GcodePosition[2])
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_position_e", "Klipper gcode position for extruder.", nil, nil),
+			prometheus.GaugeValue,
+			result.Result.Status.GcodeMove.GcodePosition[3])
+	}
+	// mcu
+	c.collectMcuStats(ch, result.Result.Status)
+
+	// toolhead
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_print_time", "Klipper toolhead print time.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.PrintTime)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_estimated_print_time", "Klipper estimated print time.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.EstimatedPrintTime)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_max_velocity", "Klipper toolhead max velocity.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.MaxVelocity)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_max_accel", "Klipper toolhead max acceleration.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.MaxAccel)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_max_accel_to_decel", "Klipper toolhead max acceleration to deceleration.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.MaxAccelToDecel)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_toolhead_square_corner_velocity", "Klipper toolhead square corner velocity.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Toolhead.SquareCornerVelocity)
+
+	// extruder
+	if !temperatureFault(result.Result.Status.Extruder.Temperature) {
+		sendConstMetric(ch,
 			prometheus.NewDesc("klipper_extruder_temperature", "Klipper extruder temperature.", nil, nil),
 			prometheus.GaugeValue,
 			result.Result.Status.Extruder.Temperature)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_extruder_target", "Klipper extruder target.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Extruder.Target)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_extruder_power", "Klipper extruder power.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Extruder.Power)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_extruder_pressure_advance", "Klipper extruder pressure advance.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Extruder.PressureAdvance)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_extruder_smooth_time", "Klipper extruder smooth time.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Extruder.SmoothTime)
-
-		// heater_bed
-		ch <- prometheus.MustNewConstMetric(
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_extruder_target", "Klipper extruder target.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Extruder.Target)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_extruder_power", "Klipper extruder power.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Extruder.Power)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_extruder_pressure_advance", "Klipper extruder pressure advance.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Extruder.PressureAdvance)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_extruder_smooth_time", "Klipper extruder smooth time.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Extruder.SmoothTime)
+
+	// heater_bed
+	if !temperatureFault(result.Result.Status.HeaterBed.Temperature) {
+		sendConstMetric(ch,
 			prometheus.NewDesc("klipper_heater_bed_temperature", "Klipper heater bed temperature.", nil, nil),
 			prometheus.GaugeValue,
 			result.Result.Status.HeaterBed.Temperature)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_heater_bed_target", "Klipper heater bed target.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.HeaterBed.Target)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_heater_bed_power", "Klipper heater bed power.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.HeaterBed.Power)
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heater_bed_target", "Klipper heater bed target.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.HeaterBed.Target)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heater_bed_power", "Klipper heater bed power.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.HeaterBed.Power)
+	c.collectHeatSoak(ch, result.Result.Status.HeaterBed)
+
+	// fan
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_fan_speed", "Klipper fan speed.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Fan.Speed)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_fan_rpm", "Klipper fan rpm.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.Status.Fan.Rpm)
+
+	// idle_timeout
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_printing_time", "The amount of time the printer has been in the Printing state.", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.IdleTimeout.PrintingTime)
+
+	// virtual_sdcard
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_file_progress", "The print progress reported as a percentage of the file read.", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.VirtualSdCard.Progress)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_file_position", "The current file position in bytes.", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.VirtualSdCard.FilePosition)
+	c.collectFileReadRate(ch, result.Result.Status.VirtualSdCard.FilePosition)
+
+	// print_stats
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_total_duration", "The total time (in seconds) elapsed since a print has started.", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.PrintStats.TotalDuration)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_print_duration", "The total time spent printing (in seconds).", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.PrintStats.PrintDuration)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_filament_used", "The amount of filament used during the current print (in mm)..", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.PrintStats.FilamentUsed)
+	c.collectPrintMessage(ch, result.Result.Status.PrintStats)
+
+	// display_status
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_gcode_progress", "The percentage of print progress, as reported by M73.", nil, nil),
+		prometheus.CounterValue,
+		result.Result.Status.DisplayStatus.Progress)
+
+	// exclude_object
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_objects_total", "The number of objects defined in the current print.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Status.ExcludeObject.Objects)))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_objects_excluded", "The number of objects excluded from the current print.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Status.ExcludeObject.ExcludedObjects)))
+	if result.Result.Status.ExcludeObject.CurrentObject != "" {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_print_current_object_info", "The name of the object currently being printed.", []string{"object"}, nil),
+			prometheus.GaugeValue,
+			1,
+			result.Result.Status.ExcludeObject.CurrentObject)
+	}
 
-		// fan
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_fan_speed", "Klipper fan speed.", nil, nil),
-			prometheus.GaugeValue,
-			result.Result.Status.Fan.Speed)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_fan_rpm", "Klipper fan rpm.", nil, nil),
+	// filament_motion_sensor
+	extruded := c.collectFilamentRate(ch, result.Result.Status.PrintStats.FilamentUsed)
+	c.collectFilamentMotion(ch, result.Result.Status.FilamentMotion, extruded)
+	c.collectSensorFaults(ch, result.Result.Status)
+	c.collectSmoothedTemperatures(ch, result.Result.Status)
+	fans := map[string]fanRpmSample{"fan": {speed: result.Result.Status.Fan.Speed, rpm: result.Result.Status.Fan.Rpm}}
+	for name, fan := range result.Result.Status.TemperatureFans {
+		fans[getValidLabelName(name)] = fanRpmSample{speed: fan.Speed, rpm: fan.Rpm}
+	}
+	c.collectFanRpmResidual(ch, fans)
+	travel := c.collectAxisTravel(ch, result.Result.Status.Toolhead.Position)
+	c.collectMaintenance(ch, result.Result.Status, travel)
+	c.recordMcuSendSeq(result.Result.Status.Mcu.LastStats.SendSeq)
+	c.collectMcuVersions(ch, "printer_objects", result.Result.Status)
+	c.collectProbes(ch, result.Result.Status)
+	c.collectServos(ch, "printer_objects", result.Result.Status.Servos)
+	c.collectAngles(ch, "printer_objects", result.Result.Status.Angles)
+	c.collectLoadCells(ch, result.Result.Status)
+	c.collectCanbus(ch, "printer_objects", result.Result.Status)
+	c.collectSampledSignals(ch)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_printer_objects_failed", "The number of printer objects that could not be decoded and are left out of the printer_objects metrics.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Status.FailedObjects)))
+	c.collectBedMesh(ch, "printer_objects", result.Result.Status.BedMesh)
+	metadata := c.collectFileMetadata(ch, "printer_objects", result.Result.Status.PrintStats.Filename)
+	c.collectFilamentByMaterial(ch, result.Result.Status.PrintStats, metadata)
+
+	// print state changes
+	previousPrintState := c.updatePrintState(result.Result.Status.PrintStats.State)
+	event := printEvent(previousPrintState, result.Result.Status.PrintStats.State)
+	c.collectPrintEvents(event, previousPrintState, result.Result.Status.PrintStats)
+	c.collectPauses(ch, event, result.Result.Status)
+	c.collectPrintPeaks(ch, event, result.Result.Status)
+	c.collectFactorChanges(ch, event, result.Result.Status.PrintStats.State, result.Result.Status.GcodeMove)
+	c.collectDoors(ch, result.Result.Status.GcodeButtons, result.Result.Status.PrintStats.State, event)
+	c.collectHeating(ch, event, result.Result.Status)
+	c.collectPSULoad(ch, result.Result.Status)
+
+	// z_thermal_adjust
+	if zThermalAdjust := result.Result.Status.ZThermalAdjust; zThermalAdjust != nil {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_z_thermal_adjust_current_z_adjust", "The current Z adjustment in mm applied by z_thermal_adjust.", nil, nil),
+			prometheus.GaugeValue,
+			zThermalAdjust.CurrentZAdjust)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_z_thermal_adjust_reference_temperature", "The reference temperature used by z_thermal_adjust.", nil, nil),
+			prometheus.GaugeValue,
+			zThermalAdjust.ZAdjustRefTemperature)
+		if !temperatureFault(zThermalAdjust.Temperature) {
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_z_thermal_adjust_temperature", "The temperature of the z_thermal_adjust sensor.", nil, nil),
+				prometheus.GaugeValue,
+				zThermalAdjust.Temperature)
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_z_thermal_adjust_enabled", "Set to 1 if z_thermal_adjust is enabled.", nil, nil),
 			prometheus.GaugeValue,
-			result.Result.Status.Fan.Rpm)
-
-		// idle_timeout
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_printing_time", "The amount of time the printer has been in the Printing state.", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.IdleTimeout.PrintingTime)
-
-		// virtual_sdcard
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_file_progress", "The print progress reported as a percentage of the file read.", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.VirtualSdCard.Progress)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_file_position", "The current file position in bytes.", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.VirtualSdCard.FilePosition)
-
-		// print_stats
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_total_duration", "The total time (in seconds) elapsed since a print has started.", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.PrintStats.TotalDuration)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_print_duration", "The total time spent printing (in seconds).", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.PrintStats.PrintDuration)
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_filament_used", "The amount of filament used during the current print (in mm)..", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.PrintStats.FilamentUsed)
+			boolToFloat64(zThermalAdjust.Enabled))
+	}
 
-		// display_status
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc("klipper_print_gcode_progress", "The percentage of print progress, as reported by M73.", nil, nil),
-			prometheus.CounterValue,
-			result.Result.Status.DisplayStatus.Progress)
-
-		// temperature_sensor
-		temperatureSensorLabels := []string{"sensor"}
-		temperatureSensor := prometheus.NewDesc("klipper_temperature_sensor_temperature", "The temperature of the temperature sensor", temperatureSensorLabels, nil)
-		temperatureSensorMinTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_min_temp", "The measured minimum temperature of the temperature sensor", temperatureSensorLabels, nil)
-		temperatureSensorMaxTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_max_temp", "The measured maximum temperature of the temperature sensor", temperatureSensorLabels, nil)
-		for sk, sv := range result.Result.Status.TemperatureSensors {
-			sensorName := getValidLabelName(sk)
-			ch <- prometheus.MustNewConstMetric(
+	// temperature_sensor
+	temperatureSensorLabels := []string{"sensor"}
+	temperatureSensor := prometheus.NewDesc("klipper_temperature_sensor_temperature", "The temperature of the temperature sensor", temperatureSensorLabels, nil)
+	temperatureSensorMinTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_min_temp", "The measured minimum temperature of the temperature sensor", temperatureSensorLabels, nil)
+	temperatureSensorMaxTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_max_temp", "The measured maximum temperature of the temperature sensor", temperatureSensorLabels, nil)
+	for sk, sv := range result.Result.Status.TemperatureSensors {
+		sensorName := getValidLabelName(sk)
+		if !temperatureFault(sv.Temperature) {
+			sendConstMetric(ch,
 				temperatureSensor,
 				prometheus.GaugeValue,
 				sv.Temperature,
 				sensorName)
-			ch <- prometheus.MustNewConstMetric(
-				temperatureSensorMinTemp,
-				prometheus.GaugeValue,
-				sv.MeasuredMinTemp,
-				sensorName)
-			ch <- prometheus.MustNewConstMetric(
-				temperatureSensorMaxTemp,
-				prometheus.GaugeValue,
-				sv.MeasuredMaxTemp,
-				sensorName)
 		}
+		sendConstMetric(ch,
+			temperatureSensorMinTemp,
+			prometheus.GaugeValue,
+			sv.MeasuredMinTemp,
+			sensorName)
+		sendConstMetric(ch,
+			temperatureSensorMaxTemp,
+			prometheus.GaugeValue,
+			sv.MeasuredMaxTemp,
+			sensorName)
 
-		// temperature_fan
-		fanLabels := []string{"fan"}
-		fanSpeed := prometheus.NewDesc("klipper_temperature_fan_speed", "The speed of the temperature fan", fanLabels, nil)
-		fanTemperature := prometheus.NewDesc("klipper_temperature_fan_temperature", "The temperature of the temperature fan", fanLabels, nil)
-		fanTarget := prometheus.NewDesc("klipper_temperature_fan_target", "The target temperature for the temperature fan", fanLabels, nil)
-		for fk, fv := range result.Result.Status.TemperatureFans {
-			fanName := getValidLabelName(fk)
-			ch <- prometheus.MustNewConstMetric(
-				fanSpeed,
-				prometheus.GaugeValue,
-				fv.Speed,
-				fanName)
-			ch <- prometheus.MustNewConstMetric(
+		if c.opts.DualEmit {
+			c.emitLegacy(ch, "klipper_temperature_sensor_", sensorName, "_temperature", "The temperature of the "+sk+" temperature sensor", prometheus.GaugeValue, sv.Temperature)
+			c.emitLegacy(ch, "klipper_temperature_sensor_", sensorName, "_measured_min_temp", "The measured minimum temperature of the "+sk+" temperature sensor", prometheus.GaugeValue, sv.MeasuredMinTemp)
+			c.emitLegacy(ch, "klipper_temperature_sensor_", sensorName, "_measured_max_temp", "The measured maximum temperature of the "+sk+" temperature sensor", prometheus.GaugeValue, sv.MeasuredMaxTemp)
+		}
+	}
+
+	// temperature_fan
+	fanLabels := []string{"fan"}
+	fanSpeed := prometheus.NewDesc("klipper_temperature_fan_speed", "The speed of the temperature fan", fanLabels, nil)
+	fanTemperature := prometheus.NewDesc("klipper_temperature_fan_temperature", "The temperature of the temperature fan", fanLabels, nil)
+	fanTarget := prometheus.NewDesc("klipper_temperature_fan_target", "The target temperature for the temperature fan", fanLabels, nil)
+	for fk, fv := range result.Result.Status.TemperatureFans {
+		fanName := getValidLabelName(fk)
+		sendConstMetric(ch,
+			fanSpeed,
+			prometheus.GaugeValue,
+			fv.Speed,
+			fanName)
+		if !temperatureFault(fv.Temperature) {
+			sendConstMetric(ch,
 				fanTemperature,
 				prometheus.GaugeValue,
 				fv.Temperature,
 				fanName)
-			ch <- prometheus.MustNewConstMetric(
-				fanTarget,
-				prometheus.GaugeValue,
-				fv.Target,
-				fanName)
 		}
+		sendConstMetric(ch,
+			fanTarget,
+			prometheus.GaugeValue,
+			fv.Target,
+			fanName)
+
+		if c.opts.DualEmit {
+			c.emitLegacy(ch, "klipper_temperature_fan_", fanName, "_speed", "The speed of the "+fk+" temperature fan", prometheus.GaugeValue, fv.Speed)
+			c.emitLegacy(ch, "klipper_temperature_fan_", fanName, "_temperature", "The temperature of the "+fk+" temperature fan", prometheus.GaugeValue, fv.Temperature)
+			c.emitLegacy(ch, "klipper_temperature_fan_", fanName, "_target", "The target temperature for the "+fk+" temperature fan", prometheus.GaugeValue, fv.Target)
+		}
+	}
 
-		// output_pin
-		pinLabels := []string{"pin"}
-		pinValue := prometheus.NewDesc("klipper_output_pin_value", "The value of the output pin", pinLabels, nil)
-		for k, v := range result.Result.Status.OutputPins {
-			pinName := getValidLabelName(k)
-			ch <- prometheus.MustNewConstMetric(
-				pinValue,
-				prometheus.GaugeValue,
-				v.Value,
-				pinName)
+	// output_pin
+	pinLabels := []string{"pin"}
+	pinValue := prometheus.NewDesc("klipper_output_pin_value", "The value of the output pin", pinLabels, nil)
+	for k, v := range result.Result.Status.OutputPins {
+		pinName := getValidLabelName(k)
+		sendConstMetric(ch,
+			pinValue,
+			prometheus.GaugeValue,
+			v.Value,
+			pinName)
+
+		if c.opts.DualEmit {
+			c.emitLegacy(ch, "klipper_output_pin_", pinName, "_value", "The value of the "+k+" output pin", prometheus.GaugeValue, v.Value)
 		}
 	}
+	c.collectOutputPinConfig(ch, "printer_objects", result.Result.Status.OutputPins)
 }
 
 // only return metric if current job status is in progress
-func (c Collector) checkConditionStatusPrint(result *MoonrakerHistoryCurrentPrintResponse, value float64) float64 {
+func (c Collector) checkConditionStatusPrint(result *moonraker.HistoryCurrentPrintResponse, value float64) float64 {
 	var valueToReturn float64 = 0
 	if len(result.Result.Jobs) >= 1 && result.Result.Jobs[0].Status == "in_progress" {
 		valueToReturn = value
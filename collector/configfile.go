@@ -0,0 +1,69 @@
+package collector
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// printerConfig returns the printer.cfg settings keyed by lower case section
+// name, including the default values of the options that are not set. The
+// configuration only changes when Klippy is restarted, e.g. by
+// FIRMWARE_RESTART or SAVE_CONFIG, so it is cached until a restart is seen and
+// then fetched by the first module that uses it.
+func (c Collector) printerConfig(module string) map[string]map[string]interface{} {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	settings := state.printerConfig
+	state.mu.Unlock()
+	if settings != nil {
+		return settings
+	}
+
+	result, err := c.api(module).ConfigFile()
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	settings = result.Result.Status.ConfigFile.Settings
+	if settings == nil {
+		settings = make(map[string]map[string]interface{})
+	}
+
+	state.mu.Lock()
+	state.printerConfig = settings
+	state.mu.Unlock()
+	return settings
+}
+
+// recordMcuSendSeq drops the cached printer configuration and host
+// information when the send sequence of the mcu goes backwards. Klippy reconnects to the mcu each time
+// it starts, so this catches a restart that completed between two scrapes
+// without the Klippy state being seen to change.
+func (c Collector) recordMcuSendSeq(sendSeq float64) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if sendSeq < state.mcuSendSeq {
+		state.invalidatePrinterCache()
+	}
+	state.mcuSendSeq = sendSeq
+}
+
+// invalidatePrinterCache drops the cached printer configuration and host
+// information so that they are fetched again. Must be called with the target
+// state mutex held.
+func (s *targetState) invalidatePrinterCache() {
+	if s.printerConfig != nil || s.printerInfo != nil {
+		log.Debug("Klippy restarted, fetching the printer configuration and host information again")
+	}
+	s.printerConfig = nil
+	s.printerInfo = nil
+}
+
+// settingFloat64 returns the numeric value of a configfile setting, or the
+// default if it is not set.
+func settingFloat64(value interface{}, defaultValue float64) float64 {
+	if f, ok := value.(float64); ok {
+		return f
+	}
+	return defaultValue
+}
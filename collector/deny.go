@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeniedLabel drops every series with the label value, e.g. the series of a
+// flapping `temperature_sensor ambient_outdoor` with `sensor=ambient_outdoor`.
+type DeniedLabel struct {
+	Name  string
+	Value string
+}
+
+// ParseDeniedLabels parses `label=value` deny rules.
+func ParseDeniedLabels(rules []string) ([]DeniedLabel, error) {
+	denied := []DeniedLabel{}
+	for _, rule := range rules {
+		name, value, ok := strings.Cut(rule, "=")
+		if !ok || !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("invalid deny rule '%s', must be label=value", rule)
+		}
+		denied = append(denied, DeniedLabel{Name: name, Value: value})
+	}
+	return denied, nil
+}
+
+// denySeries forwards the metrics to ch, discarding the metrics that have one
+// of the denied label values. The returned channel must be closed once
+// collection is complete, and done is closed after the last metric has been
+// forwarded.
+func (c Collector) denySeries(ch chan<- prometheus.Metric, denied []DeniedLabel) (filtered chan prometheus.Metric, done chan struct{}) {
+	filtered = make(chan prometheus.Metric)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		dropped := 0
+		for m := range filtered {
+			if isDenied(m, denied) {
+				dropped++
+				continue
+			}
+			ch <- m
+		}
+		if dropped > 0 {
+			log.Debugf("Dropped %d denied series for %s", dropped, c.target)
+		}
+	}()
+	return filtered, done
+}
+
+func isDenied(m prometheus.Metric, denied []DeniedLabel) bool {
+	metric := &dto.Metric{}
+	if err := m.Write(metric); err != nil {
+		return false
+	}
+	for _, label := range metric.Label {
+		for _, d := range denied {
+			if label.GetName() == d.Name && label.GetValue() == d.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
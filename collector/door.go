@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectDoors exports the state of each `gcode_button` and, for the buttons
+// configured as DoorButtons, the total time the door has been open during the
+// current print. The open time is accumulated between scrapes while a print is
+// in progress or paused, and reset when the next print starts.
+func (c Collector) collectDoors(ch chan<- prometheus.Metric, buttons map[string]moonraker.PrinterObjectGcodeButton, printState string, event string) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	printing := printState == "printing" || printState == "paused"
+	if event == "start" {
+		state.doorOpenSeconds = make(map[string]float64)
+	}
+	elapsed := 0.0
+	if printing && !state.doorLastTime.IsZero() {
+		elapsed = now.Sub(state.doorLastTime).Seconds()
+	}
+	state.doorLastTime = now
+
+	for name, button := range buttons {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_button_pressed", "Set to 1 if the gcode button is pressed.", []string{"button"}, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(button.State == "PRESSED"),
+			name)
+
+		if !slices.Contains(c.opts.DoorButtons, name) {
+			continue
+		}
+		// the door is counted as open for the whole interval if it was open
+		// at the previous scrape
+		if state.doorOpen[name] {
+			state.doorOpenSeconds[name] += elapsed
+		}
+		open := strings.EqualFold(button.State, c.opts.DoorOpenState)
+		state.doorOpen[name] = open
+
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_door_open", "Set to 1 if the door switch is open.", []string{"button"}, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(open),
+			name)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_door_open_print_seconds", "Total time in seconds the door has been open during the current print.", []string{"button"}, nil),
+			prometheus.GaugeValue,
+			state.doorOpenSeconds[name],
+			name)
+	}
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"strings"
+	"time"
+)
+
+// emergencyStopMessages are parts of the Klippy shutdown message of an
+// emergency stop, from the M112 command or the Moonraker emergency stop
+// request used by the web interfaces.
+var emergencyStopMessages = []string{"M112", "webhooks request"}
+
+func isEmergencyStop(message string) bool {
+	for _, m := range emergencyStopMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordKlippyState counts an emergency stop when Klippy changes to the
+// shutdown state with an emergency stop message, and returns the number of
+// emergency stops. A shutdown that is already in progress when the exporter
+// starts is not counted. Any change of the Klippy state drops the cached
+// printer configuration and host information.
+func (c Collector) recordKlippyState(klippyState string, message string) int {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	previous := state.lastKlippyState
+	state.lastKlippyState = klippyState
+	if previous != klippyState {
+		state.invalidatePrinterCache()
+	}
+	if klippyState == "shutdown" && previous != "" && previous != "shutdown" && isEmergencyStop(message) {
+		state.emergencyStops++
+		state.lastEmergencyStop = float64(time.Now().UnixNano()) / 1e9
+	}
+	return state.emergencyStops
+}
+
+// recordEmergencyStopCommand counts an M112 command from the gcode store, for
+// an emergency stop that was not observed as a shutdown because Klippy was
+// restarted between scrapes. Must be called with the target state mutex held.
+func (s *targetState) recordEmergencyStopCommand(commandTime float64) {
+	// the shutdown of the command was already counted
+	if commandTime <= s.lastEmergencyStop {
+		return
+	}
+	s.emergencyStops++
+	s.lastEmergencyStop = commandTime
+}
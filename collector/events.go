@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// PrintEvent is published when the print state of a target changes.
+type PrintEvent struct {
+	Target        string  `json:"target"`
+	Event         string  `json:"event"`
+	State         string  `json:"state"`
+	PreviousState string  `json:"previous_state"`
+	Filename      string  `json:"filename,omitempty"`
+	Time          float64 `json:"time"`
+}
+
+// EventPublisher publishes print events to a message broker.
+type EventPublisher interface {
+	Publish(event PrintEvent)
+}
+
+var eventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "klipper_exporter_events_published_total",
+	Help: "Number of print events published.",
+}, []string{"event"})
+
+var eventsFailed = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "klipper_exporter_events_failed_total",
+	Help: "Number of print events that could not be published.",
+})
+
+// eventQueueSize is the number of events buffered while the broker is slow or
+// unavailable. Further events are dropped so scrapes are never blocked.
+const eventQueueSize = 100
+
+// eventSender sends a single encoded event to the broker.
+type eventSender interface {
+	send(event PrintEvent, payload []byte) error
+}
+
+// queuedPublisher publishes events in the background. The broker clients
+// reconnect after a failure.
+type queuedPublisher struct {
+	queue  chan PrintEvent
+	sender eventSender
+}
+
+// NewEventPublisher returns a publisher for the broker URL, either
+// `nats://host:4222` or `tls://host:4222` to publish to the NATS subject
+// `topic`, or `redis://host:6379` or `rediss://host:6379` to add the events to
+// the Redis stream `topic`.
+func NewEventPublisher(brokerURL string, topic string) (EventPublisher, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, err
+	}
+	var sender eventSender
+	switch u.Scheme {
+	case "nats", "tls":
+		sender = &natsSender{url: brokerURL, subject: topic}
+	case "redis", "rediss":
+		sender, err = newRedisSender(brokerURL, topic)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported events URL scheme '%s', must be nats, tls, redis, or rediss", u.Scheme)
+	}
+	p := &queuedPublisher{queue: make(chan PrintEvent, eventQueueSize), sender: sender}
+	go p.run()
+	return p, nil
+}
+
+func (p *queuedPublisher) Publish(event PrintEvent) {
+	select {
+	case p.queue <- event:
+	default:
+		log.Warnf("Event queue full, dropping %s event for %s", event.Event, event.Target)
+		eventsFailed.Inc()
+	}
+}
+
+func (p *queuedPublisher) run() {
+	for event := range p.queue {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			log.Error(err)
+			eventsFailed.Inc()
+			continue
+		}
+		if err := p.sender.send(event, payload); err != nil {
+			log.Errorf("Unable to publish %s event for %s: %v", event.Event, event.Target, err)
+			eventsFailed.Inc()
+			continue
+		}
+		eventsPublished.WithLabelValues(event.Event).Inc()
+	}
+}
+
+// collectPrintEvents publishes the event for the change of the print state
+// since the previous scrape.
+func (c Collector) collectPrintEvents(event string, previous string, printStats moonraker.PrinterObjectPrintStats) {
+	if c.opts.Events == nil || event == "" {
+		return
+	}
+	log.Infof("Print %s on %s", event, c.target)
+	c.opts.Events.Publish(PrintEvent{
+		Target:        c.target,
+		Event:         event,
+		State:         printStats.State,
+		PreviousState: previous,
+		Filename:      printStats.Filename,
+		Time:          float64(time.Now().UnixNano()) / 1e9,
+	})
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+)
+
+// eventTimeout is the maximum time to connect to the broker and publish an event.
+const eventTimeout = 5 * time.Second
+
+// natsSender publishes events to a NATS subject. The `tls://` scheme, or a
+// server that requires TLS, connects with TLS, and the user and password or
+// token of the URL are used to authenticate. The client reconnects to the
+// server in the background after the connection is lost.
+type natsSender struct {
+	url     string
+	subject string
+	conn    *nats.Conn
+}
+
+func (s *natsSender) connect() error {
+	conn, err := nats.Connect(s.url,
+		nats.Name("prometheus-klipper-exporter"),
+		nats.Timeout(eventTimeout),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.ReconnectJitter(500*time.Millisecond, time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Warnf("Disconnected from the NATS server: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Infof("Reconnected to the NATS server %s", conn.ConnectedUrlRedacted())
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *natsSender) send(event PrintEvent, payload []byte) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	if err := s.conn.Publish(s.subject, payload); err != nil {
+		return err
+	}
+	// wait for the server to confirm it received the message
+	return s.conn.FlushTimeout(eventTimeout)
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSender adds events to a Redis stream. The `rediss://` scheme connects
+// with TLS, and the user, password, and database of the URL are used when
+// connecting. The client reconnects to the server with a backoff after the
+// connection is lost.
+type redisSender struct {
+	client *redis.Client
+	stream string
+}
+
+func newRedisSender(brokerURL string, stream string) (*redisSender, error) {
+	options, err := redis.ParseURL(brokerURL)
+	if err != nil {
+		return nil, err
+	}
+	options.DialTimeout = eventTimeout
+	options.ReadTimeout = eventTimeout
+	options.WriteTimeout = eventTimeout
+	return &redisSender{client: redis.NewClient(options), stream: stream}, nil
+}
+
+func (s *redisSender) send(event PrintEvent, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: []string{"event", event.Event, "target", event.Target, "data", string(payload)},
+	}).Err()
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectFactorChanges exports the number of times the speed factor (M220) and
+// extrude factor (M221) have been changed during the current print. Frequent
+// overrides mid print usually indicate tuning or slicing problems worth
+// reviewing. Changes are detected between scrapes, so several changes within
+// one scrape interval are counted once.
+func (c Collector) collectFactorChanges(ch chan<- prometheus.Metric, event string, printState string, gcodeMove moonraker.PrinterObjectGcodeMove) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	printing := printState == "printing" || printState == "paused"
+	switch {
+	case event == "start":
+		state.speedFactorChanges = 0
+		state.extrudeFactorChanges = 0
+	case printing && state.factorsSeen:
+		if gcodeMove.SpeedFactor != state.lastSpeedFactor {
+			state.speedFactorChanges++
+		}
+		if gcodeMove.ExtrudeFactor != state.lastExtrudeFactor {
+			state.extrudeFactorChanges++
+		}
+	}
+	state.lastSpeedFactor = gcodeMove.SpeedFactor
+	state.lastExtrudeFactor = gcodeMove.ExtrudeFactor
+	state.factorsSeen = true
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_speed_factor_changes", "Number of times the speed factor has been changed during the current print.", nil, nil),
+		prometheus.GaugeValue,
+		float64(state.speedFactorChanges))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_extrude_factor_changes", "Number of times the extrude factor has been changed during the current print.", nil, nil),
+		prometheus.GaugeValue,
+		float64(state.extrudeFactorChanges))
+}
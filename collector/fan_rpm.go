@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// minFanRpmLearningSpeed is the minimum fan speed the RPM per PWM ratio is
+// learned at. The RPM of most fans is not proportional to the PWM duty cycle
+// at low speeds.
+const minFanRpmLearningSpeed = 0.2
+
+// fanRpmSample is the fan speed and tachometer RPM of a fan.
+type fanRpmSample struct {
+	speed float64
+	rpm   float64
+}
+
+// collectFanRpmResidual learns the expected RPM per PWM ratio of each fan with
+// a tachometer as a slow exponential moving average, and exports the relative
+// difference of the measured RPM from the expected RPM. A falling residual
+// indicates a worn bearing long before the fan fails completely. Fans without
+// a tachometer report no RPM and are skipped until an RPM has been seen.
+func (c Collector) collectFanRpmResidual(ch chan<- prometheus.Metric, fans map[string]fanRpmSample) {
+	if c.opts.FanRpmLearningTime <= 0 {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	weight := 1.0
+	if !state.fanRpmLastTime.IsZero() {
+		weight = 1 - math.Exp(-now.Sub(state.fanRpmLastTime).Seconds()/c.opts.FanRpmLearningTime.Seconds())
+	}
+	state.fanRpmLastTime = now
+
+	labels := []string{"fan"}
+	expectedDesc := prometheus.NewDesc("klipper_fan_rpm_expected", "Expected fan RPM at the current speed from the learned RPM per PWM ratio.", labels, nil)
+	residualDesc := prometheus.NewDesc("klipper_fan_rpm_residual_ratio", "Relative difference of the fan RPM from the expected RPM, negative when the fan is slower than expected.", labels, nil)
+	for name, fan := range fans {
+		ratio, learned := state.fanRpmRatio[name]
+		if fan.speed >= minFanRpmLearningSpeed && fan.rpm > 0 {
+			if learned {
+				ratio += weight * (fan.rpm/fan.speed - ratio)
+			} else {
+				ratio = fan.rpm / fan.speed
+				learned = true
+			}
+			state.fanRpmRatio[name] = ratio
+		}
+		if !learned || fan.speed == 0 {
+			continue
+		}
+		expected := ratio * fan.speed
+		sendConstMetric(ch, expectedDesc, prometheus.GaugeValue, expected, name)
+		sendConstMetric(ch, residualDesc, prometheus.GaugeValue, (fan.rpm-expected)/expected, name)
+	}
+}
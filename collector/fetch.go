@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// responseBytesTotal counts the size of the response bodies read from each
+// target, to show the bandwidth used by each module. Reported from the
+// exporter's own `/metrics` endpoint.
+var responseBytesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_moonraker_response_bytes_total",
+		Help: "Number of response body bytes read from the Moonraker API of the target.",
+	},
+	[]string{"target", "module"},
+)
+
+// moonrakerStatusError is returned when Moonraker responds to a request with a
+// non successful HTTP status code.
+type moonrakerStatusError struct {
+	url        string
+	statusCode int
+}
+
+func (e *moonrakerStatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP status %d", e.url, e.statusCode)
+}
+
+// fetch queries the Moonraker API path on the klipperHost and decodes the JSON
+// response into response. The outcome is recorded against the module so that
+// modules that are not available on the target can be automatically disabled,
+// and the duration and any failure are reported in the module scrape metrics.
+func (c Collector) fetch(module string, klipperHost string, apiKey string, path string, response interface{}) error {
+	return c.fetchRequest(module, klipperHost, apiKey, path, response, false)
+}
+
+// fetchLookup is fetch for a request that looks up an item used by the
+// metrics of the module, e.g. the metadata of the file being printed or the
+// printer configuration, instead of querying the module's own endpoint. An
+// HTTP 404 response means the item was not found, e.g. the file has been
+// deleted, so it does not fail the module or count towards automatically
+// disabling it.
+func (c Collector) fetchLookup(module string, klipperHost string, apiKey string, path string, response interface{}) error {
+	return c.fetchRequest(module, klipperHost, apiKey, path, response, true)
+}
+
+// moduleRequester sends the Moonraker API requests of a module with fetch, so
+// they share the fetch pipeline of the collection, see Collector.api.
+type moduleRequester struct {
+	c      Collector
+	module string
+	// all requests are sent with fetchLookup
+	lookup bool
+}
+
+func (r moduleRequester) Get(path string, response interface{}) error {
+	if r.lookup {
+		return r.Lookup(path, response)
+	}
+	return r.c.fetch(r.module, r.c.target, r.c.apiKey, path, response)
+}
+
+func (r moduleRequester) Lookup(path string, response interface{}) error {
+	return r.c.fetchLookup(r.module, r.c.target, r.c.apiKey, path, response)
+}
+
+// api returns the Moonraker API of the target for the requests of the module.
+func (c Collector) api(module string) moonraker.API {
+	return moonraker.NewClient(moduleRequester{c: c, module: module})
+}
+
+// lookupAPI returns the Moonraker API of the target for the requests of the
+// module that look up an item from the endpoint of another module, e.g. the
+// last BED_MESH_CALIBRATE command from the gcode store, which are sent with
+// fetchLookup.
+func (c Collector) lookupAPI(module string) moonraker.API {
+	return moonraker.NewClient(moduleRequester{c: c, module: module, lookup: true})
+}
+
+func (c Collector) fetchRequest(module string, klipperHost string, apiKey string, path string, response interface{}, lookup bool) (err error) {
+	start := time.Now()
+	defer func() {
+		c.results.addDuration(module, time.Since(start))
+		if err != nil && !(lookup && isNotFound(err)) {
+			c.results.fail(module)
+		}
+	}()
+	target, err := moonraker.ParseTarget(klipperHost)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	url := target.URL(path)
+	key := c.requestKey(url, apiKey)
+	if res, ok := c.cachedResponse(key); ok {
+		log.Debugf("Using cached response of %s", url)
+		cacheHitsTotal.WithLabelValues(c.target, module).Inc()
+		return c.decodeResponse(module, res, response)
+	}
+	if err := c.rateLimited(); err != nil {
+		log.Debugf("Skipping %s, %v", url, err)
+		return err
+	}
+	log.Debug("Collecting metrics from " + url)
+
+	// requests are cancelled with the scrape, e.g. when the scrape times out
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.opts.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.RequestTimeout)
+		defer cancel()
+	}
+
+	// concurrent scrapes of the target share the same request
+	request := func() (*moonrakerResponse, error) {
+		return c.get(ctx, klipperHost, apiKey, path, url, module)
+	}
+	res, shared, err := coalesce(key, request)
+	if shared && errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		// the scrape that made the shared request was cancelled, but this one
+		// was not so the request is made again
+		res, shared, err = coalesce(key, request)
+	}
+	if shared {
+		coalescedRequestsTotal.WithLabelValues(c.target, module).Inc()
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			// the scrape was cancelled, e.g. the client closed the connection
+			log.Debugf("Cancelled %s, %v", url, err)
+			return err
+		}
+		log.Error(err)
+		return err
+	}
+	c.cacheResponse(module, key, res)
+	if res.statusCode == http.StatusTooManyRequests {
+		return c.recordRateLimited(res)
+	}
+	if res.statusCode < 200 || res.statusCode > 299 {
+		err = &moonrakerStatusError{url: url, statusCode: res.statusCode}
+		if lookup && res.statusCode == http.StatusNotFound {
+			log.Debug(err)
+			return err
+		}
+		c.recordModuleStatus(module, res.statusCode)
+		log.Error(err)
+		return err
+	}
+	return c.decodeResponse(module, res, response)
+}
+
+// isNotFound returns true if Moonraker responded with HTTP 404 Not Found.
+func isNotFound(err error) bool {
+	var statusErr *moonrakerStatusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound
+}
+
+// decodeResponse decodes the JSON body of a successful response into response.
+func (c Collector) decodeResponse(module string, res *moonrakerResponse, response interface{}) error {
+	log.Tracef("%+v", string(res.body))
+
+	err := json.Unmarshal(replaceNonFiniteNumbers(res.body), response)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	c.recordModuleStatus(module, res.statusCode)
+
+	return nil
+}
+
+// requestKey identifies a request by everything that is sent to Moonraker.
+func (c Collector) requestKey(url string, apiKey string) string {
+	proxy := ""
+	if c.opts.ProxyURL != nil {
+		proxy = c.opts.ProxyURL.String()
+	}
+	return strings.Join([]string{url, apiKey, c.opts.RequestTag, proxy}, "\x00")
+}
+
+// get sends the request of the API path on the klipperHost to Moonraker, in
+// a JSON-RPC batch with the other requests of the collection if enabled.
+func (c Collector) get(ctx context.Context, klipperHost string, apiKey string, path string, url string, module string) (*moonrakerResponse, error) {
+	if c.batch != nil && klipperHost == c.target && apiKey == c.apiKey && c.jsonRPCSupported() {
+		return c.batch.get(ctx, path, module)
+	}
+	return c.httpGet(ctx, url, apiKey, module)
+}
+
+// newRequest returns a request to Moonraker with the exporter headers and the
+// API key.
+func (c Collector) newRequest(ctx context.Context, method string, url string, body io.Reader, apiKey string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+	if c.opts.RequestTag != "" {
+		req.Header.Set("X-Exporter-Tag", c.opts.RequestTag)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-KEY", apiKey)
+	}
+	return req, nil
+}
+
+// httpGet sends the HTTP API request to Moonraker and reads the response.
+func (c Collector) httpGet(ctx context.Context, url string, apiKey string, module string) (*moonrakerResponse, error) {
+	req, err := c.newRequest(ctx, "GET", url, nil, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := &moonrakerResponse{url: url, statusCode: res.StatusCode, header: res.Header}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return response, nil
+	}
+	response.body, err = io.ReadAll(res.Body)
+	responseBytesTotal.WithLabelValues(c.target, module).Add(float64(len(response.body)))
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
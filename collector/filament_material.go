@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// FilamentTotals accumulates the filament used by each target per material
+// across prints. The totals are saved to the state file, if set, so they are
+// kept across restarts.
+type FilamentTotals struct {
+	mu        sync.Mutex
+	stateFile string
+	// millimeters of filament used keyed by target and material
+	totals map[string]map[string]float64
+}
+
+// NewFilamentTotals loads the saved totals from the state file.
+func NewFilamentTotals(stateFile string) (*FilamentTotals, error) {
+	f := &FilamentTotals{stateFile: stateFile, totals: make(map[string]map[string]float64)}
+	if stateFile != "" {
+		data, err := os.ReadFile(stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &f.totals); err != nil {
+				return nil, fmt.Errorf("invalid filament state file %s: %v", stateFile, err)
+			}
+		}
+	}
+	return f, nil
+}
+
+// add increases the total of the material for the target.
+func (f *FilamentTotals) add(target string, material string, used float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	totals, ok := f.totals[target]
+	if !ok {
+		totals = make(map[string]float64)
+		f.totals[target] = totals
+	}
+	totals[material] += used
+	f.save()
+}
+
+// targetTotals returns a copy of the totals of the target.
+func (f *FilamentTotals) targetTotals(target string) map[string]float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	totals := make(map[string]float64, len(f.totals[target]))
+	for material, used := range f.totals[target] {
+		totals[material] = used
+	}
+	return totals
+}
+
+// save writes the totals to the state file. Must be called with the mutex held.
+func (f *FilamentTotals) save() error {
+	if f.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(f.totals)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(f.stateFile), "."+filepath.Base(f.stateFile)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Errorf("Unable to save filament state: %v", err)
+		return err
+	}
+	if err := os.Rename(tmp, f.stateFile); err != nil {
+		log.Errorf("Unable to save filament state: %v", err)
+		return err
+	}
+	return nil
+}
+
+// filamentMaterial returns the material label from the slicer `filament_type`
+// metadata, e.g. `PLA`. Multi material files list a type for each filament,
+// e.g. `PLA;PETG`, which is reported as `mixed` unless all of the types are
+// the same. Files without the metadata are reported as `unknown`.
+func filamentMaterial(filamentType string) string {
+	material := ""
+	for _, t := range strings.Split(filamentType, ";") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t == "" || t == material {
+			continue
+		}
+		if material != "" {
+			return "mixed"
+		}
+		material = t
+	}
+	if material == "" {
+		return "unknown"
+	}
+	return material
+}
+
+// filamentMaterialSample is the filament used at the previous scrape.
+type filamentMaterialSample struct {
+	filename     string
+	filamentUsed float64
+}
+
+// collectFilamentByMaterial adds the filament used since the previous scrape
+// to the total of the material of the loaded file, and exports the totals of
+// all of the materials the target has printed.
+func (c Collector) collectFilamentByMaterial(ch chan<- prometheus.Metric, printStats moonraker.PrinterObjectPrintStats, metadata *moonraker.FileMetadataResponse) {
+	f := c.opts.FilamentTotals
+	if f == nil {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	previous := state.filamentMaterialSample
+	state.filamentMaterialSample = &filamentMaterialSample{filename: printStats.Filename, filamentUsed: printStats.FilamentUsed}
+	state.mu.Unlock()
+
+	if previous != nil && printStats.Filename != "" && metadata != nil {
+		used := printStats.FilamentUsed
+		if previous.filename == printStats.Filename {
+			used = counterIncrease(previous.filamentUsed, printStats.FilamentUsed)
+		}
+		if used > 0 {
+			f.add(c.target, filamentMaterial(metadata.Result.FilamentType), used)
+		}
+	}
+
+	desc := prometheus.NewDesc("klipper_filament_used_by_material_mm_total", "Total filament used in millimeters for each material, from the filament_type slicer metadata.", []string{"material"}, nil)
+	for material, used := range f.targetTotals(c.target) {
+		sendConstMetric(ch, desc, prometheus.CounterValue, used, material)
+	}
+}
@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector/modules"
+)
+
+// filamentMotionSample is the commanded extrusion between two scrapes and
+// whether the motion sensor was detecting filament movement at the time.
+type filamentMotionSample struct {
+	time     time.Time
+	extruded float64
+	detected bool
+}
+
+// collectFilamentMotion exports the state of each `filament_motion_sensor` and
+// an anomaly ratio of the extrusion during which the sensor detected filament
+// motion to the total commanded extrusion over the FilamentMotionWindow. A
+// ratio below 1 while printing indicates the sensor stopped detecting motion
+// while the extruder was still being driven, e.g. a partial clog.
+func (c Collector) collectFilamentMotion(ch chan<- prometheus.Metric, sensors map[string]moonraker.PrinterObjectFilamentMotionSensor, extruded float64) {
+	sensorLabels := []string{"sensor"}
+	detectedDesc := prometheus.NewDesc("klipper_filament_motion_sensor_detected", "Set to 1 if the filament motion sensor detects filament.", sensorLabels, nil)
+	enabledDesc := prometheus.NewDesc("klipper_filament_motion_sensor_enabled", "Set to 1 if the filament motion sensor is enabled.", sensorLabels, nil)
+	ratioDesc := prometheus.NewDesc("klipper_filament_motion_ratio", "Ratio of commanded extrusion with detected filament motion to total commanded extrusion over the filament motion window.", sensorLabels, nil)
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	for name, sensor := range sensors {
+		sensorName := getValidLabelName(name)
+		sendConstMetric(ch,
+			detectedDesc,
+			prometheus.GaugeValue,
+			boolToFloat64(sensor.FilamentDetected),
+			sensorName)
+		sendConstMetric(ch,
+			enabledDesc,
+			prometheus.GaugeValue,
+			boolToFloat64(sensor.Enabled),
+			sensorName)
+
+		samples := state.filamentMotion[name]
+		if extruded > 0 && sensor.Enabled {
+			samples = append(samples, filamentMotionSample{time: now, extruded: extruded, detected: sensor.FilamentDetected})
+		}
+		// drop samples that have passed out of the window
+		for len(samples) > 0 && now.Sub(samples[0].time) > c.opts.FilamentMotionWindow {
+			samples = samples[1:]
+		}
+		state.filamentMotion[name] = samples
+
+		total := 0.0
+		detected := 0.0
+		for _, sample := range samples {
+			total += sample.extruded
+			if sample.detected {
+				detected += sample.extruded
+			}
+		}
+		if total > 0 {
+			sendConstMetric(ch,
+				ratioDesc,
+				prometheus.GaugeValue,
+				detected/total,
+				sensorName)
+		}
+	}
+}
+
+func boolToFloat64(b bool) float64 {
+	return modules.BoolToFloat64(b)
+}
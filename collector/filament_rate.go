@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectFilamentRate exports the filament used per second since the previous
+// scrape, and returns the length of filament extruded. A rate of 0 while
+// printing means the extruder is not being driven, e.g. the print continued
+// in mid air after a jam. The rate is not reported on the first scrape of the
+// target, or when the filament used was reset at the start of a print.
+func (c Collector) collectFilamentRate(ch chan<- prometheus.Metric, filamentUsed float64) float64 {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	previous := state.lastFilamentUsedTime
+	// filament used is reset at the start of each print
+	extruded := filamentUsed - state.lastFilamentUsed
+	if extruded < 0 || previous.IsZero() {
+		extruded = 0
+	}
+	reset := filamentUsed < state.lastFilamentUsed
+	state.lastFilamentUsed = filamentUsed
+	state.lastFilamentUsedTime = now
+
+	if previous.IsZero() || reset || !now.After(previous) {
+		return extruded
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_filament_used_rate_mm_per_second", "Millimeters of filament used per second since the previous scrape.", nil, nil),
+		prometheus.GaugeValue,
+		extruded/now.Sub(previous).Seconds())
+	return extruded
+}
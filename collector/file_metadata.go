@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-gcode-metadata
+
+// fileMetadataRetryInterval is how long a failed metadata lookup of a file is
+// cached before the metadata is requested again, e.g. for a file that has been
+// deleted while it is still loaded or queued.
+const fileMetadataRetryInterval = 5 * time.Minute
+
+// lookupFileMetadata fetches the metadata of the file unless the previous
+// lookup of the file failed within the retry interval. A lookup that is
+// cancelled with the scrape is not cached as failed.
+func (c Collector) lookupFileMetadata(module string, filename string) (*moonraker.FileMetadataResponse, error) {
+	state := getTargetState(c.target)
+	now := time.Now()
+	state.mu.Lock()
+	for name, failed := range state.fileMetadataFailures {
+		if now.Sub(failed) >= fileMetadataRetryInterval {
+			delete(state.fileMetadataFailures, name)
+		}
+	}
+	_, failed := state.fileMetadataFailures[filename]
+	state.mu.Unlock()
+	if failed {
+		return nil, fmt.Errorf("metadata lookup of %s failed in the last %v", filename, fileMetadataRetryInterval)
+	}
+
+	metadata, err := c.api(module).FileMetadata(filename)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		state.mu.Lock()
+		if state.fileMetadataFailures == nil {
+			state.fileMetadataFailures = make(map[string]time.Time)
+		}
+		state.fileMetadataFailures[filename] = now
+		state.mu.Unlock()
+	}
+	return metadata, err
+}
+
+// collectFileMetadata exports the slicer metadata of the file that is loaded
+// for printing. The metadata does not change while the file is loaded, so it
+// is only fetched again when a different file is loaded. The metadata is
+// returned for the metrics derived from it, or nil if no file is loaded.
+func (c Collector) collectFileMetadata(ch chan<- prometheus.Metric, module string, filename string) *moonraker.FileMetadataResponse {
+	if filename == "" {
+		return nil
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	metadata := state.fileMetadata
+	state.mu.Unlock()
+
+	if metadata == nil || metadata.Result.Filename != filename {
+		var err error
+		metadata, err = c.lookupFileMetadata(module, filename)
+		if err != nil {
+			return nil
+		}
+		// cache the metadata using the print_stats filename
+		metadata.Result.Filename = filename
+		state.mu.Lock()
+		state.fileMetadata = metadata
+		state.mu.Unlock()
+	}
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_filament_total_expected_millimeters", "Total filament length in millimeters for the current print estimated by the slicer.", nil, nil),
+		prometheus.GaugeValue,
+		metadata.Result.FilamentTotal)
+	return metadata
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectFileReadRate exports the bytes of the print file read per second
+// since the previous scrape. A rate near 0 while printing means the print has
+// stalled, e.g. on a corrupt gcode file. The rate is not reported on the first
+// scrape of the target, or when the file position was reset for a new file.
+func (c Collector) collectFileReadRate(ch chan<- prometheus.Metric, filePosition float64) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	previous := state.lastFilePositionTime
+	read := filePosition - state.lastFilePosition
+	state.lastFilePosition = filePosition
+	state.lastFilePositionTime = now
+
+	if previous.IsZero() || read < 0 || !now.After(previous) {
+		return
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_file_read_rate_bytes_per_second", "Bytes of the print file read per second since the previous scrape.", nil, nil),
+		prometheus.GaugeValue,
+		read/now.Sub(previous).Seconds())
+}
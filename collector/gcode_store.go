@@ -0,0 +1,105 @@
+package collector
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#request-cached-gcode-responses
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// gcodeStoreCount is the maximum number of gcode store entries requested on
+// each scrape.
+const gcodeStoreCount = 1000
+
+func (c Collector) collectGcodeStore(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting gcode_store for %s", c.target)
+
+	objects, err := c.api("gcode_store").PrinterObjectsList()
+	if err == nil {
+		macros := 0
+		for _, object := range objects.Result.Objects {
+			if strings.HasPrefix(object, "gcode_macro ") {
+				macros++
+			}
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_gcode_macros", "The number of gcode macros defined in the printer config.", nil, nil),
+			prometheus.GaugeValue,
+			float64(macros))
+	}
+
+	result, err := c.api("gcode_store").GcodeStore(gcodeStoreCount)
+	if err != nil {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// count the commands, and the commands for the selected macros, that have
+	// been added to the gcode store since the previous scrape. The commands in
+	// the gcode store on the first scrape were processed before the exporter
+	// started, so only the time of the last command is recorded and they are
+	// not included in the counters or the rate.
+	firstScrape := state.gcodeStoreLastScrape.IsZero()
+	lastTime := state.gcodeStoreLastTime
+	commands := 0
+	for _, entry := range result.Result.GcodeStore {
+		if entry.Type != "command" || entry.Time <= lastTime {
+			continue
+		}
+		if entry.Time > state.gcodeStoreLastTime {
+			state.gcodeStoreLastTime = entry.Time
+		}
+		if firstScrape {
+			continue
+		}
+		commands++
+		fields := strings.Fields(entry.Message)
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToUpper(fields[0])
+		if command == "M112" {
+			state.recordEmergencyStopCommand(entry.Time)
+		}
+		for _, macro := range c.opts.GcodeStoreMacros {
+			if strings.ToUpper(macro) == command {
+				state.macroExecutions[command]++
+			}
+		}
+	}
+
+	now := time.Now()
+	commandsPerSecond := 0.0
+	if !firstScrape {
+		if elapsed := now.Sub(state.gcodeStoreLastScrape).Seconds(); elapsed > 0 {
+			commandsPerSecond = float64(commands) / elapsed
+		}
+	}
+	state.gcodeStoreLastScrape = now
+	state.gcodeCommands += commands
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_gcode_store_commands_total", "The number of gcode commands processed, as observed in the gcode store.", nil, nil),
+		prometheus.CounterValue,
+		float64(state.gcodeCommands))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_gcode_store_commands_per_second", "The rate of gcode commands processed since the previous scrape, as observed in the gcode store.", nil, nil),
+		prometheus.GaugeValue,
+		commandsPerSecond)
+
+	macroExecutions := prometheus.NewDesc("klipper_macro_executions_total", "The number of times the macro has been executed, as observed in the gcode store.", []string{"macro"}, nil)
+	for _, macro := range c.opts.GcodeStoreMacros {
+		command := strings.ToUpper(macro)
+		sendConstMetric(ch,
+			macroExecutions,
+			prometheus.CounterValue,
+			float64(state.macroExecutions[command]),
+			command)
+	}
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectHeatSoak derives whether the heater bed has been held within the
+// HeatSoakTolerance of its target temperature for at least HeatSoakDuration.
+// The time the bed first came within tolerance is tracked between scrapes and
+// reset whenever the temperature drifts out of tolerance or the heater is off.
+func (c Collector) collectHeatSoak(ch chan<- prometheus.Metric, bed moonraker.PrinterObjectHeaterBed) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	if bed.Target > 0 && math.Abs(bed.Temperature-bed.Target) <= c.opts.HeatSoakTolerance {
+		if state.bedSoakStart.IsZero() {
+			state.bedSoakStart = now
+		}
+	} else {
+		state.bedSoakStart = time.Time{}
+	}
+
+	soakSeconds := 0.0
+	if !state.bedSoakStart.IsZero() {
+		soakSeconds = now.Sub(state.bedSoakStart).Seconds()
+	}
+	soaked := 0.0
+	if !state.bedSoakStart.IsZero() && now.Sub(state.bedSoakStart) >= c.opts.HeatSoakDuration {
+		soaked = 1
+	}
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heater_bed_soak_seconds", "Time in seconds the heater bed has been within the heat soak tolerance of the target temperature.", nil, nil),
+		prometheus.GaugeValue,
+		soakSeconds)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heater_bed_soaked", "Set to 1 when the heater bed has been within the heat soak tolerance of the target temperature for the heat soak duration.", nil, nil),
+		prometheus.GaugeValue,
+		soaked)
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"math"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// heaterSaturationTolerance is how close to the maximum power a heater must
+// be to count as saturated, as the temperature store rounds the power.
+const heaterSaturationTolerance = 0.001
+
+// collectHeaterSaturation exports, for each heater in the temperature store,
+// the fraction of the samples within the saturation window where the heater
+// was at its maximum power while still below the target temperature. A heater
+// that is saturated for long periods is undersized, or failing, e.g. a loose
+// thermistor or a degraded heater cartridge. The maximum power is the
+// `max_power` of the heater section in printer.cfg.
+func (c Collector) collectHeaterSaturation(ch chan<- prometheus.Metric, module string, result *moonraker.TemperatureDataQueryResponse) {
+	window := int(c.opts.HeaterSaturationWindow / temperatureStoreInterval)
+	if window <= 0 {
+		return
+	}
+	config := c.printerConfig(module)
+
+	desc := prometheus.NewDesc("klipper_heater_saturation_ratio", "Fraction of the recent temperature store samples where the heater was at maximum power while below the target temperature.", []string{"heater"}, nil)
+	for k, v := range result.Result {
+		attributes, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		temperatures, _ := attributes["temperatures"].([]interface{})
+		targets, _ := attributes["targets"].([]interface{})
+		powers, ok := attributes["powers"].([]interface{})
+		if !ok {
+			// not a heater
+			continue
+		}
+		samples := len(powers)
+		if len(temperatures) < samples {
+			samples = len(temperatures)
+		}
+		if len(targets) < samples {
+			samples = len(targets)
+		}
+		if samples == 0 {
+			continue
+		}
+		if samples > window {
+			samples = window
+		}
+
+		maxPower := settingFloat64(config[strings.ToLower(k)]["max_power"], 1.0)
+		saturated := 0
+		for i := 1; i <= samples; i++ {
+			power, _ := powers[len(powers)-i].(float64)
+			temperature, _ := temperatures[len(temperatures)-i].(float64)
+			target, _ := targets[len(targets)-i].(float64)
+			if target > 0 && temperature < target && math.Abs(power-maxPower) <= heaterSaturationTolerance {
+				saturated++
+			}
+		}
+		sendConstMetric(ch, desc, prometheus.GaugeValue, float64(saturated)/float64(samples), temperatureStoreSensor(k))
+	}
+}
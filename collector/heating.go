@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// heatingTolerance is how far in degrees celsius below the target temperature
+// a heater is still considered to be heating. Klipper holds the temperature
+// within a fraction of a degree of the target once it is reached, so a small
+// margin stops the regulation around the target being counted as heating.
+const heatingTolerance = 2.0
+
+// heating returns true if the heater has a target temperature set and has not
+// yet reached it.
+func heating(temperature float64, target float64) bool {
+	return target > 0 && temperature < target-heatingTolerance
+}
+
+// collectHeating exports whether the extruder or heater bed is heating up to
+// its target temperature, and the time spent heating, both in total and during
+// the current print, to separate the heat up time from the printing time. The
+// heating time is accumulated between scrapes and the print heating time is
+// reset when the next print starts.
+func (c Collector) collectHeating(ch chan<- prometheus.Metric, event string, status moonraker.PrinterObjectStatus) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	if event == "start" {
+		state.printHeatingSeconds = 0
+	}
+	// the heaters are counted as heating for the whole interval if they were
+	// heating at the previous scrape
+	if state.heatingActive && !state.heatingLastTime.IsZero() {
+		elapsed := now.Sub(state.heatingLastTime).Seconds()
+		state.heatingSeconds += elapsed
+		if status.PrintStats.State == "printing" || status.PrintStats.State == "paused" {
+			state.printHeatingSeconds += elapsed
+		}
+	}
+	state.heatingLastTime = now
+	state.heatingActive = heating(status.Extruder.Temperature, status.Extruder.Target) ||
+		heating(status.HeaterBed.Temperature, status.HeaterBed.Target)
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heating_active", "Set to 1 while the extruder or heater bed is heating up to its target temperature.", nil, nil),
+		prometheus.GaugeValue,
+		boolToFloat64(state.heatingActive))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heating_seconds_total", "Total time in seconds the extruder or heater bed has been heating up to its target temperature.", nil, nil),
+		prometheus.CounterValue,
+		state.heatingSeconds)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_heating_seconds", "Time in seconds the extruder or heater bed has been heating up to its target temperature during the current print.", nil, nil),
+		prometheus.GaugeValue,
+		state.printHeatingSeconds)
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDurationBuckets are the default upper bounds in seconds of the print
+// duration histogram, from 10 minutes to 2 days.
+var DefaultDurationBuckets = []float64{600, 1800, 3600, 7200, 14400, 28800, 57600, 86400, 172800}
+
+// DefaultFilamentBuckets are the default upper bounds in millimeters of the
+// filament used histogram, from 1 to 100 meters.
+var DefaultFilamentBuckets = []float64{1000, 2500, 5000, 10000, 25000, 50000, 100000}
+
+// collectHistoryHistograms exports the distribution of the print duration and
+// filament used of the most recent finished jobs in the print history. The
+// histograms are rebuilt from the history on each scrape, so the counts can
+// decrease as older jobs are no longer included and should not be used with
+// rate().
+func (c Collector) collectHistoryHistograms(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting history histograms for %s", c.target)
+	result, err := c.api("history").HistoryJobs(c.opts.HistoryJobs)
+	if err != nil {
+		return
+	}
+	durations := []float64{}
+	filament := []float64{}
+	for _, job := range result.Result.Jobs {
+		if job.Status == "in_progress" {
+			continue
+		}
+		durations = append(durations, job.PrintDuration)
+		filament = append(filament, job.FilamentUsed)
+	}
+	sendConstHistogram(ch,
+		prometheus.NewDesc("klipper_print_duration_seconds", "Print time of the most recent finished jobs in the print history.", nil, nil),
+		c.opts.DurationBuckets, durations)
+	sendConstHistogram(ch,
+		prometheus.NewDesc("klipper_print_filament_used_mm", "Millimeters of filament used by the most recent finished jobs in the print history.", nil, nil),
+		c.opts.FilamentBuckets, filament)
+}
+
+// sendConstHistogram sends a histogram of the values with the bucket upper
+// bounds.
+func sendConstHistogram(ch chan<- prometheus.Metric, desc *prometheus.Desc, bounds []float64, values []float64, labelValues ...string) {
+	sum := 0.0
+	buckets := make(map[float64]uint64, len(bounds))
+	for _, bound := range bounds {
+		buckets[bound] = 0
+	}
+	for _, value := range values {
+		sum += value
+		for _, bound := range bounds {
+			if value <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+	metric, err := prometheus.NewConstHistogram(desc, uint64(len(values)), sum, buckets, labelValues...)
+	if err != nil {
+		log.Warnf("Skipping invalid histogram %s: %v", desc, err)
+		return
+	}
+	ch <- metric
+}
@@ -3,55 +3,56 @@ package collector
 // https://moonraker.readthedocs.io/en/latest/web_api/#retrieve-the-job-queue-status
 
 import (
-	"encoding/json"
-	log "github.com/sirupsen/logrus"
-	"io"
-	"net/http"
-)
-
-type MoonrakerJobQueueResponse struct {
-	Result struct {
-		QueuedJobs []MoonrakerQueuedJob `json:"queued_jobs"`
-		QueueState string               `json:"queue_state"`
-	} `json:"result"`
-}
+	"github.com/prometheus/client_golang/prometheus"
 
-type MoonrakerQueuedJob struct {
-	TimeInQueue float64 `json:"time_in_queue"`
-}
-
-func (c Collector) fetchMoonrakerJobQueue(klipperHost string, apiKey string) (*MoonrakerJobQueueResponse, error) {
-	var url = "http://" + klipperHost + "/server/job_queue/status"
-	log.Debug("Collecting metrics from " + url)
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	if apiKey != "" {
-		req.Header.Set("X-API-KEY", apiKey)
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// collectQueuedJobs exports the file size and slicer estimated print time of
+// each queued job, and the total estimated time to print the queue so plate
+// swaps can be planned. The file metadata is cached for as long as the file is
+// queued.
+func (c Collector) collectQueuedJobs(ch chan<- prometheus.Metric, jobs []moonraker.QueuedJob) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	cached := state.queueMetadata
+	state.mu.Unlock()
+
+	metadata := make(map[string]*moonraker.FileMetadataResponse)
+	for _, job := range jobs {
+		if _, ok := metadata[job.Filename]; ok {
+			continue
+		}
+		if m, ok := cached[job.Filename]; ok {
+			metadata[job.Filename] = m
+			continue
+		}
+		m, err := c.lookupFileMetadata("job_queue", job.Filename)
+		if err != nil {
+			continue
+		}
+		metadata[job.Filename] = m
 	}
 
-	var response MoonrakerJobQueueResponse
-
-	err = json.Unmarshal(data, &response)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+	state.mu.Lock()
+	state.queueMetadata = metadata
+	state.mu.Unlock()
+
+	labels := []string{"job_id", "filename"}
+	sizeDesc := prometheus.NewDesc("klipper_job_queue_job_size_bytes", "File size in bytes of the queued job.", labels, nil)
+	estimatedDesc := prometheus.NewDesc("klipper_job_queue_job_estimated_seconds", "Print time in seconds of the queued job estimated by the slicer.", labels, nil)
+	total := 0.0
+	for _, job := range jobs {
+		m, ok := metadata[job.Filename]
+		if !ok {
+			continue
+		}
+		total += m.Result.EstimatedTime
+		sendConstMetric(ch, sizeDesc, prometheus.GaugeValue, float64(m.Result.Size), job.JobID, job.Filename)
+		sendConstMetric(ch, estimatedDesc, prometheus.GaugeValue, m.Result.EstimatedTime, job.JobID, job.Filename)
 	}
-
-	return &response, nil
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_job_queue_estimated_seconds", "Total print time in seconds of the queued jobs estimated by the slicer.", nil, nil),
+		prometheus.GaugeValue,
+		total)
 }
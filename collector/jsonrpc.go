@@ -0,0 +1,282 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// jsonRPCBatchWindow is how long a request waits for the requests of the
+// other modules collected in parallel before the batch is sent.
+const jsonRPCBatchWindow = 10 * time.Millisecond
+
+var jsonRPCBatchesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_moonraker_jsonrpc_batches_total",
+		Help: "Number of JSON-RPC batch requests sent to Moonraker.",
+	},
+	[]string{"target"},
+)
+
+var jsonRPCBatchedRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_moonraker_jsonrpc_batched_requests_total",
+		Help: "Number of Moonraker requests sent in a JSON-RPC batch.",
+	},
+	[]string{"target"},
+)
+
+// jsonRPCCall is a request waiting to be sent in the next batch.
+type jsonRPCCall struct {
+	path   string
+	module string
+	done   chan struct{}
+	res    *moonrakerResponse
+	err    error
+}
+
+// jsonRPCBatch sends the requests of a collection that are made at the same
+// time, e.g. by the modules collected in parallel, to Moonraker in a single
+// JSON-RPC batch request to the `/server/jsonrpc` endpoint, to reduce the
+// number of round trips over slow networks. The JSON-RPC results are returned
+// in the same form as the HTTP API responses so they are decoded the same way.
+type jsonRPCBatch struct {
+	c      Collector
+	target *moonraker.Target
+
+	mu      sync.Mutex
+	pending []*jsonRPCCall
+}
+
+// newJSONRPCBatch returns the batch for the requests of the collector target,
+// or nil if the target is invalid.
+func newJSONRPCBatch(c Collector) *jsonRPCBatch {
+	target, err := moonraker.ParseTarget(c.target)
+	if err != nil {
+		return nil
+	}
+	return &jsonRPCBatch{c: c, target: target}
+}
+
+// get adds the request of the API path to the next batch and waits for its
+// response.
+func (b *jsonRPCBatch) get(ctx context.Context, path string, module string) (*moonrakerResponse, error) {
+	call := &jsonRPCCall{path: path, module: module, done: make(chan struct{})}
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	if len(b.pending) == 1 {
+		time.AfterFunc(jsonRPCBatchWindow, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.res, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends the pending requests.
+func (b *jsonRPCBatch) flush() {
+	b.mu.Lock()
+	calls := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	ctx := b.c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if b.c.opts.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.c.opts.RequestTimeout)
+		defer cancel()
+	}
+	if err := b.send(ctx, calls); err != nil {
+		for _, call := range calls {
+			call.err = err
+		}
+	}
+	for _, call := range calls {
+		close(call.done)
+	}
+}
+
+// jsonRPCRequest is a JSON-RPC 2.0 request.
+type jsonRPCRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	ID      int                    `json:"id"`
+}
+
+// jsonRPCResponse is a JSON-RPC 2.0 response.
+type jsonRPCResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// send sends the calls in a single batch request and sets the response of
+// each call. If Moonraker does not support JSON-RPC over HTTP the calls are
+// sent as separate HTTP API requests instead.
+func (b *jsonRPCBatch) send(ctx context.Context, calls []*jsonRPCCall) error {
+	requests := make([]jsonRPCRequest, len(calls))
+	for i, call := range calls {
+		method, params, err := jsonRPCMethod(call.path)
+		if err != nil {
+			return err
+		}
+		requests[i] = jsonRPCRequest{JSONRPC: "2.0", Method: method, Params: params, ID: i}
+	}
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+
+	endpoint := b.target.URL("/server/jsonrpc")
+	req, err := b.c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body), b.c.apiKey)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	log.Debugf("Sending %d requests to %s", len(calls), endpoint)
+	res, err := b.c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		log.Warnf("%s does not support JSON-RPC over HTTP, sending the requests separately", b.c.target)
+		b.c.disableJSONRPC()
+		b.sendSeparately(ctx, calls)
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		// e.g. an authentication error or rate limit applies to all calls
+		for _, call := range calls {
+			call.res = &moonrakerResponse{url: endpoint, statusCode: res.StatusCode, header: res.Header}
+		}
+		return nil
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	responses := []jsonRPCResponse{}
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return fmt.Errorf("invalid JSON-RPC response from %s: %v", endpoint, err)
+	}
+	jsonRPCBatchesTotal.WithLabelValues(b.c.target).Inc()
+	jsonRPCBatchedRequestsTotal.WithLabelValues(b.c.target).Add(float64(len(calls)))
+
+	for _, response := range responses {
+		if response.ID < 0 || response.ID >= len(calls) {
+			continue
+		}
+		call := calls[response.ID]
+		callURL := b.target.URL(call.path)
+		if response.Error != nil {
+			// Moonraker uses the HTTP status codes as the JSON-RPC error codes
+			statusCode := response.Error.Code
+			if statusCode < 400 || statusCode > 599 {
+				statusCode = http.StatusInternalServerError
+			}
+			call.res = &moonrakerResponse{url: callURL, statusCode: statusCode, header: http.Header{}}
+			continue
+		}
+		result := append(append([]byte(`{"result":`), response.Result...), '}')
+		responseBytesTotal.WithLabelValues(b.c.target, call.module).Add(float64(len(result)))
+		call.res = &moonrakerResponse{url: callURL, statusCode: http.StatusOK, header: http.Header{}, body: result}
+	}
+	for _, call := range calls {
+		if call.res == nil {
+			call.err = fmt.Errorf("no JSON-RPC response for %s from %s", call.path, endpoint)
+		}
+	}
+	return nil
+}
+
+// sendSeparately sends each of the calls as a HTTP API request.
+func (b *jsonRPCBatch) sendSeparately(ctx context.Context, calls []*jsonRPCCall) {
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call *jsonRPCCall) {
+			defer wg.Done()
+			call.res, call.err = b.c.httpGet(ctx, b.target.URL(call.path), b.c.apiKey, call.module)
+		}(call)
+	}
+	wg.Wait()
+}
+
+// jsonRPCMethod returns the JSON-RPC method and parameters of a HTTP API
+// path, e.g. `machine.proc_stats` for `/machine/proc_stats`. The query
+// parameters are passed as the method parameters, except for the printer
+// objects query where they are the objects and their attributes.
+func jsonRPCMethod(path string) (string, map[string]interface{}, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", nil, err
+	}
+	method := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(query) == 0 {
+		return method, nil, nil
+	}
+	params := make(map[string]interface{})
+	if method == "printer.objects.query" {
+		objects := make(map[string]interface{})
+		for object, values := range query {
+			if len(values) == 0 || values[0] == "" {
+				// all of the attributes of the object
+				objects[object] = nil
+				continue
+			}
+			objects[object] = strings.Split(values[0], ",")
+		}
+		params["objects"] = objects
+		return method, params, nil
+	}
+	for name, values := range query {
+		params[name] = values[0]
+	}
+	return method, params, nil
+}
+
+// disableJSONRPC stops sending the requests of the target in JSON-RPC batches.
+func (c Collector) disableJSONRPC() {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.jsonRPCUnsupported = true
+}
+
+// jsonRPCSupported returns false if the target does not support JSON-RPC over
+// HTTP.
+func (c Collector) jsonRPCSupported() bool {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return !state.jsonRPCUnsupported
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// labeledMetric adds constant labels to a metric, e.g. the printer label.
+// Labels that are already set by the metric are not overridden.
+type labeledMetric struct {
+	prometheus.Metric
+	labels []*dto.LabelPair
+}
+
+func (m labeledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	for _, label := range m.labels {
+		if !hasLabel(out, label.GetName()) {
+			out.Label = append(out.Label, label)
+		}
+	}
+	sort.Slice(out.Label, func(i, j int) bool { return out.Label[i].GetName() < out.Label[j].GetName() })
+	return nil
+}
+
+func hasLabel(m *dto.Metric, name string) bool {
+	for _, label := range m.Label {
+		if label.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addLabels forwards the metrics to ch with the constant labels added. The
+// returned channel must be closed once collection is complete, and done is
+// closed after the last metric has been forwarded.
+func (c Collector) addLabels(ch chan<- prometheus.Metric, labels map[string]string) (labeled chan prometheus.Metric, done chan struct{}) {
+	pairs := []*dto.LabelPair{}
+	for name, value := range labels {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	labeled = make(chan prometheus.Metric)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range labeled {
+			ch <- labeledMetric{Metric: m, labels: pairs}
+		}
+	}()
+	return labeled, done
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// historyJobStatuses are the statuses of a finished job in the print history.
+var historyJobStatuses = []string{"completed", "cancelled", "error", "klippy_shutdown", "klippy_disconnect", "server_exit", "interrupted"}
+
+// collectLastJob exports the status, duration, and filament used of the most
+// recent finished job in the print history, e.g. to alert on failed prints.
+func (c Collector) collectLastJob(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting last job for %s", c.target)
+	// the most recent job may still be in progress, in which case the job
+	// before it is the last finished job
+	result, err := c.api("history").HistoryJobs(2)
+	if err != nil {
+		return
+	}
+	for _, job := range result.Result.Jobs {
+		if job.Status == "in_progress" {
+			continue
+		}
+		statusDesc := prometheus.NewDesc("klipper_history_last_job_status", "Set to 1 for the status of the last finished job.", []string{"status"}, nil)
+		for _, status := range historyJobStatuses {
+			sendConstMetric(ch, statusDesc, prometheus.GaugeValue, boolToFloat64(status == job.Status), status)
+		}
+		if !slices.Contains(historyJobStatuses, job.Status) {
+			sendConstMetric(ch, statusDesc, prometheus.GaugeValue, 1, job.Status)
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_history_last_job_duration_seconds", "Total duration of the last finished job.", nil, nil),
+			prometheus.GaugeValue,
+			job.TotalDuration)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_history_last_job_print_duration_seconds", "Print time of the last finished job.", nil, nil),
+			prometheus.GaugeValue,
+			job.PrintDuration)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_history_last_job_filament_used_mm", "Millimeters of filament used by the last finished job.", nil, nil),
+			prometheus.GaugeValue,
+			job.FilamentUsed)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_history_last_job_end_timestamp_seconds", "Unix timestamp the last finished job ended.", nil, nil),
+			prometheus.GaugeValue,
+			job.EndTime)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_history_last_job_info", "The filename and job ID of the last finished job.", []string{"filename", "job_id"}, nil),
+			prometheus.GaugeValue,
+			1,
+			job.Filename, job.JobID)
+		return
+	}
+}
+
+// lastSuccessJobs is the number of jobs searched for the last completed job if
+// the history histograms are disabled.
+const lastSuccessJobs = 100
+
+// collectLastSuccess exports the time the most recent completed job ended,
+// e.g. to find printers that have not produced anything in weeks. The job is
+// searched for in the same jobs as the history histograms. If it is no longer
+// included, e.g. after many cancelled jobs, the last time found is kept.
+func (c Collector) collectLastSuccess(ch chan<- prometheus.Metric) {
+	log.Infof("Collecting last successful job for %s", c.target)
+	limit := c.opts.HistoryJobs
+	if limit <= 0 {
+		limit = lastSuccessJobs
+	}
+	result, err := c.api("history").HistoryJobs(limit)
+	if err != nil {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	for _, job := range result.Result.Jobs {
+		if job.Status == "completed" && job.EndTime > state.lastSuccessTime {
+			state.lastSuccessTime = job.EndTime
+		}
+	}
+	lastSuccess := state.lastSuccessTime
+	state.mu.Unlock()
+	if lastSuccess == 0 {
+		return
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_last_success_timestamp_seconds", "Unix timestamp the last completed job ended.", nil, nil),
+		prometheus.GaugeValue,
+		lastSuccess)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_history_since_last_success_seconds", "Seconds since the last completed job ended.", nil, nil),
+		prometheus.GaugeValue,
+		float64(time.Now().UnixNano())/1e9-lastSuccess)
+}
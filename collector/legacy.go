@@ -0,0 +1,16 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// emitLegacy sends a metric using the pre v0.7.0 naming scheme where the entity
+// name is part of the metric name, e.g. `klipper_network_wlan0_rx_bytes` rather
+// than `klipper_network_rx_bytes{interface="wlan0"}`. Only used when dual emit
+// is enabled during the migration to the labeled metrics.
+func (c Collector) emitLegacy(ch chan<- prometheus.Metric, prefix string, entity string, suffix string, help string, valueType prometheus.ValueType, value float64) {
+	sendConstMetric(ch,
+		prometheus.NewDesc(prefix+entity+suffix, help, nil, nil),
+		valueType,
+		value)
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectLoadCells exports the force measured by each load cell in grams, and
+// for spool scales with a configured empty spool weight the weight of the
+// filament remaining, e.g. to alert before a print runs out of filament
+// independent of the filament used bookkeeping. The unnamed `load_cell` is
+// reported with the `load_cell` sensor name.
+func (c Collector) collectLoadCells(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	loadCells := make(map[string]moonraker.PrinterObjectLoadCell, len(status.LoadCells)+1)
+	for name, loadCell := range status.LoadCells {
+		loadCells[name] = loadCell
+	}
+	if status.LoadCell != nil {
+		loadCells["load_cell"] = *status.LoadCell
+	}
+
+	sensorLabels := []string{"sensor"}
+	for name, loadCell := range loadCells {
+		sensorName := getValidLabelName(name)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_load_cell_calibrated", "Set to 1 if the load cell has been calibrated.", sensorLabels, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(loadCell.IsCalibrated),
+			sensorName)
+		if loadCell.ForceG == nil {
+			continue
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_load_cell_force_grams", "The force measured by the load cell in grams.", sensorLabels, nil),
+			prometheus.GaugeValue,
+			*loadCell.ForceG,
+			sensorName)
+		if emptySpool, ok := c.opts.EmptySpoolWeight[name]; ok {
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_load_cell_filament_remaining_grams", "The weight of the filament remaining on the spool scale, the measured weight less the weight of the empty spool.", sensorLabels, nil),
+				prometheus.GaugeValue,
+				*loadCell.ForceG-emptySpool,
+				sensorName)
+		}
+	}
+}
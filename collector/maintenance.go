@@ -0,0 +1,281 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// MaintenanceCounters are the usage counters that maintenance tasks can be
+// tracked against, and the unit of the task interval.
+var MaintenanceCounters = map[string]string{
+	"filament_mm":             "millimeters of filament extruded",
+	"print_seconds":           "seconds of printing",
+	"travel_x_mm":             "millimeters of X axis travel",
+	"travel_y_mm":             "millimeters of Y axis travel",
+	"travel_z_mm":             "millimeters of Z axis travel",
+	"extruder_heater_seconds": "seconds the extruder heater is on",
+	"bed_heater_seconds":      "seconds the heater bed is on",
+}
+
+// MaintenanceTask is a maintenance item that is due after the usage counter
+// has increased by the interval since the task was last reset, e.g. lubricate
+// the Z lead screws every 50000 millimeters of Z travel.
+type MaintenanceTask struct {
+	Name     string  `yaml:"name" json:"name"`
+	Counter  string  `yaml:"counter" json:"counter"`
+	Interval float64 `yaml:"interval" json:"interval"`
+}
+
+// maintenanceUsage is the usage of a task since it was last reset.
+type maintenanceUsage struct {
+	Usage     float64 `json:"usage"`
+	LastReset float64 `json:"last_reset"`
+}
+
+// ErrUnknownMaintenanceTask is returned when resetting a task that is not configured.
+var ErrUnknownMaintenanceTask = errors.New("unknown maintenance task")
+
+// maintenanceSaveInterval is the minimum time between writes of the usage to
+// the state file, so an SD card is not written on every scrape.
+const maintenanceSaveInterval = 5 * time.Minute
+
+// Maintenance tracks the usage of the maintenance tasks for each target. The
+// usage is saved to the state file, if set, so it is kept across restarts. The
+// usage is saved at most once per save interval, when a task is reset, and by
+// Flush.
+type Maintenance struct {
+	mu        sync.Mutex
+	tasks     []MaintenanceTask
+	stateFile string
+	// usage of each task keyed by target and task name
+	usage map[string]map[string]*maintenanceUsage
+	// set if the usage changed since it was last saved, and the time it was
+	// last saved
+	changed   bool
+	lastSaved time.Time
+}
+
+// validateMaintenanceTasks checks the task names are unique and the counters
+// and intervals are valid.
+func validateMaintenanceTasks(tasks []MaintenanceTask) error {
+	names := []string{}
+	for _, task := range tasks {
+		if _, ok := MaintenanceCounters[task.Counter]; !ok {
+			return fmt.Errorf("maintenance task '%s' has unknown counter '%s'", task.Name, task.Counter)
+		}
+		if task.Name == "" || slices.Contains(names, task.Name) {
+			return fmt.Errorf("maintenance task names must be set and unique, '%s'", task.Name)
+		}
+		if task.Interval <= 0 {
+			return fmt.Errorf("maintenance task '%s' interval must be greater than 0", task.Name)
+		}
+		names = append(names, task.Name)
+	}
+	return nil
+}
+
+// NewMaintenance validates the tasks and loads the saved usage from the state file.
+func NewMaintenance(tasks []MaintenanceTask, stateFile string) (*Maintenance, error) {
+	if err := validateMaintenanceTasks(tasks); err != nil {
+		return nil, err
+	}
+
+	m := &Maintenance{tasks: tasks, stateFile: stateFile, usage: make(map[string]map[string]*maintenanceUsage), lastSaved: time.Now()}
+	if stateFile != "" {
+		data, err := os.ReadFile(stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &m.usage); err != nil {
+				return nil, fmt.Errorf("invalid maintenance state file %s: %v", stateFile, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// SetTasks replaces the tracked tasks, e.g. after the configuration is
+// reloaded. The usage of tasks that are kept is not changed.
+func (m *Maintenance) SetTasks(tasks []MaintenanceTask) error {
+	if err := validateMaintenanceTasks(tasks); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks = tasks
+	return nil
+}
+
+// taskUsage returns the usage of the task for the target, creating it if the
+// task has not been tracked before. Must be called with the mutex held.
+func (m *Maintenance) taskUsage(target string, task string) *maintenanceUsage {
+	usage, ok := m.usage[target]
+	if !ok {
+		usage = make(map[string]*maintenanceUsage)
+		m.usage[target] = usage
+	}
+	if _, ok := usage[task]; !ok {
+		usage[task] = &maintenanceUsage{LastReset: float64(time.Now().Unix())}
+		m.changed = true
+	}
+	return usage[task]
+}
+
+// add increases the usage of the tasks for the target by the counter deltas,
+// and saves the usage if it changed and was last saved more than the save
+// interval ago.
+func (m *Maintenance) add(target string, deltas map[string]float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, task := range m.tasks {
+		usage := m.taskUsage(target, task.Name)
+		if delta := deltas[task.Counter]; delta != 0 {
+			usage.Usage += delta
+			m.changed = true
+		}
+	}
+	if m.changed && time.Since(m.lastSaved) >= maintenanceSaveInterval {
+		// the error is logged and the save is retried after the interval
+		_ = m.save()
+	}
+}
+
+// Flush saves the usage if it changed since it was last saved, e.g. when the
+// exporter is stopped.
+func (m *Maintenance) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.changed {
+		return nil
+	}
+	return m.save()
+}
+
+// Reset marks the task as done for the target, restarting its usage from 0.
+func (m *Maintenance) Reset(target string, task string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.IndexFunc(m.tasks, func(t MaintenanceTask) bool { return t.Name == task }) < 0 {
+		return ErrUnknownMaintenanceTask
+	}
+	usage := m.taskUsage(target, task)
+	usage.Usage = 0
+	usage.LastReset = float64(time.Now().Unix())
+	m.changed = true
+	log.Infof("Reset maintenance task %s for %s", task, target)
+	return m.save()
+}
+
+// save writes the usage to the state file. Must be called with the mutex held.
+func (m *Maintenance) save() error {
+	m.lastSaved = time.Now()
+	if m.stateFile == "" {
+		m.changed = false
+		return nil
+	}
+	data, err := json.Marshal(m.usage)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so the state is not lost if the
+	// exporter is stopped while writing
+	tmp := filepath.Join(filepath.Dir(m.stateFile), "."+filepath.Base(m.stateFile)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Errorf("Unable to save maintenance state: %v", err)
+		return err
+	}
+	if err := os.Rename(tmp, m.stateFile); err != nil {
+		log.Errorf("Unable to save maintenance state: %v", err)
+		return err
+	}
+	m.changed = false
+	return nil
+}
+
+// maintenanceSample is the printer state at the previous scrape used to
+// calculate the increase of the maintenance counters.
+type maintenanceSample struct {
+	time          time.Time
+	filamentUsed  float64
+	printDuration float64
+	extruderOn    bool
+	bedOn         bool
+}
+
+// counterIncrease returns the increase of a value that is reset to 0 at the
+// start of each print.
+func counterIncrease(previous float64, current float64) float64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// collectMaintenance updates the usage of the maintenance tasks from the change
+// in the printer status since the previous scrape, and exports the usage and
+// remaining life of each task.
+func (c Collector) collectMaintenance(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus, travel map[string]float64) {
+	m := c.opts.Maintenance
+	if m == nil {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	now := time.Now()
+	previous := state.maintenanceSample
+	state.maintenanceSample = &maintenanceSample{
+		time:          now,
+		filamentUsed:  status.PrintStats.FilamentUsed,
+		printDuration: status.PrintStats.PrintDuration,
+		extruderOn:    status.Extruder.Target > 0,
+		bedOn:         status.HeaterBed.Target > 0,
+	}
+	state.mu.Unlock()
+
+	if previous != nil {
+		elapsed := now.Sub(previous.time).Seconds()
+		deltas := map[string]float64{
+			"filament_mm":   counterIncrease(previous.filamentUsed, status.PrintStats.FilamentUsed),
+			"print_seconds": counterIncrease(previous.printDuration, status.PrintStats.PrintDuration),
+			"travel_x_mm":   travel["x"],
+			"travel_y_mm":   travel["y"],
+			"travel_z_mm":   travel["z"],
+		}
+		if previous.extruderOn {
+			deltas["extruder_heater_seconds"] = elapsed
+		}
+		if previous.bedOn {
+			deltas["bed_heater_seconds"] = elapsed
+		}
+		m.add(c.target, deltas)
+	}
+
+	labels := []string{"task", "counter"}
+	usageDesc := prometheus.NewDesc("klipper_maintenance_usage", "Usage of the maintenance counter since the task was last reset.", labels, nil)
+	intervalDesc := prometheus.NewDesc("klipper_maintenance_interval", "Usage of the maintenance counter after which the task is due.", labels, nil)
+	remainingDesc := prometheus.NewDesc("klipper_maintenance_remaining_ratio", "Remaining life until the maintenance task is due, from 1 after a reset to 0 or below when due.", labels, nil)
+	resetDesc := prometheus.NewDesc("klipper_maintenance_last_reset_timestamp_seconds", "Unix timestamp the maintenance task was last reset.", labels, nil)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, task := range m.tasks {
+		usage := m.taskUsage(c.target, task.Name)
+		sendConstMetric(ch, usageDesc, prometheus.GaugeValue, usage.Usage, task.Name, task.Counter)
+		sendConstMetric(ch, intervalDesc, prometheus.GaugeValue, task.Interval, task.Name, task.Counter)
+		sendConstMetric(ch, remainingDesc, prometheus.GaugeValue, 1-usage.Usage/task.Interval, task.Name, task.Counter)
+		sendConstMetric(ch, resetDesc, prometheus.GaugeValue, usage.LastReset, task.Name, task.Counter)
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// mcuStats are the metrics of the mcu statistics.
+var mcuStats = []struct {
+	name  string
+	help  string
+	value func(moonraker.PrinterObjectMcu) float64
+}{
+	{"klipper_mcu_awake", "Klipper mcu awake.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.McuAwake }},
+	{"klipper_mcu_task_avg", "Klipper mcu average task time in seconds.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.McuTaskAvg }},
+	{"klipper_mcu_task_stddev", "Klipper mcu task time standard deviation in seconds.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.McuTaskStddev }},
+	{"klipper_mcu_write_bytes", "Klipper mcu write bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.BytesWrite }},
+	{"klipper_mcu_read_bytes", "Klipper mcu read bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.BytesRead }},
+	{"klipper_mcu_retransmit_bytes", "Klipper mcu retransmit bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.BytesRetransmit }},
+	{"klipper_mcu_invalid_bytes", "Klipper mcu invalid bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.BytesInvalid }},
+	{"klipper_mcu_send_seq", "Klipper mcu send sequence.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.SendSeq }},
+	{"klipper_mcu_receive_seq", "Klipper mcu receive sequence.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.ReceiveSeq }},
+	{"klipper_mcu_retransmit_seq", "Klipper mcu retransmit sequence.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.RetransmitSeq }},
+	{"klipper_mcu_srtt", "Klipper mcu smoothed round trip time.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.Srtt }},
+	{"klipper_mcu_rttvar", "Klipper mcu round trip time variance.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.Rttvar }},
+	{"klipper_mcu_rto", "Klipper mcu retransmission timeouts.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.Rto }},
+	{"klipper_mcu_ready_bytes", "Klipper mcu ready bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.ReadyBytes }},
+	{"klipper_mcu_stalled_bytes", "Klipper mcu stalled bytes.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.StalledBytes }},
+	{"klipper_mcu_clock_frequency", "Klipper mcu clock frequency.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.Freq }},
+	{"klipper_mcu_clock_frequency_adjusted", "Klipper mcu clock frequency adjusted by the host clock synchronization.", func(m moonraker.PrinterObjectMcu) float64 { return m.LastStats.Adj }},
+}
+
+// collectMcuStats exports the statistics of the primary mcu. With McuLabels
+// set the statistics of every mcu, including additional mcus such as CAN
+// toolhead boards or the host mcu, are exported with an `mcu` label instead,
+// where the primary mcu is `mcu`.
+func (c Collector) collectMcuStats(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	if !c.opts.McuLabels {
+		for _, stat := range mcuStats {
+			sendConstMetric(ch, prometheus.NewDesc(stat.name, stat.help, nil, nil), prometheus.GaugeValue, stat.value(status.Mcu))
+		}
+		return
+	}
+
+	mcus := map[string]moonraker.PrinterObjectMcu{"mcu": status.Mcu}
+	for name, mcu := range status.Mcus {
+		mcus[name] = mcu
+	}
+	for _, stat := range mcuStats {
+		desc := prometheus.NewDesc(stat.name, stat.help, []string{"mcu"}, nil)
+		for name, mcu := range mcus {
+			sendConstMetric(ch, desc, prometheus.GaugeValue, stat.value(mcu), name)
+		}
+	}
+}
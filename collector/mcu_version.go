@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// printerInfo returns the Klippy host information, including the host software
+// version. The version only changes when Klipper is updated, which restarts
+// Klippy, so it is cached until a restart is seen and then fetched again by
+// the module that uses it.
+func (c Collector) printerInfo(module string) *moonraker.PrinterInfoResponse {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	info := state.printerInfo
+	state.mu.Unlock()
+	if info != nil {
+		return info
+	}
+
+	info, err := c.api(module).PrinterInfo()
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	state.mu.Lock()
+	state.printerInfo = info
+	state.mu.Unlock()
+	return info
+}
+
+// collectMcuVersions compares the firmware version reported by each MCU with
+// the version of the Klipper host software. Klipper expects the MCU firmware
+// to be rebuilt and flashed whenever the host is updated, so a mismatch is
+// usually a forgotten flash after an update.
+func (c Collector) collectMcuVersions(ch chan<- prometheus.Metric, module string, status moonraker.PrinterObjectStatus) {
+	info := c.printerInfo(module)
+	if info == nil {
+		return
+	}
+	hostVersion := info.Result.SoftwareVersion
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_software_version_info", "Klipper host software version.", []string{"version"}, nil),
+		prometheus.GaugeValue,
+		1,
+		hostVersion)
+
+	mcuVersions := map[string]string{"mcu": status.Mcu.McuVersion}
+	for name, mcu := range status.Mcus {
+		mcuVersions[name] = mcu.McuVersion
+	}
+	for name, version := range mcuVersions {
+		if version == "" {
+			continue
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_mcu_version_info", "Klipper mcu firmware version.", []string{"mcu", "version"}, nil),
+			prometheus.GaugeValue,
+			1,
+			name, version)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_mcu_version_mismatch", "Klipper mcu firmware version does not match the host software version.", []string{"mcu"}, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(version != hostVersion),
+			name)
+	}
+}
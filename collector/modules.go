@@ -0,0 +1,42 @@
+package collector
+
+// ModuleInfo describes a group of metrics that can be enabled using the
+// `modules` parameter.
+type ModuleInfo struct {
+	Name        string
+	Description string
+	// Default modules are collected when no modules are specified
+	Default bool
+}
+
+// Modules lists all of the available modules.
+var Modules = []ModuleInfo{
+	{Name: "process_stats", Description: "Moonraker process and host system statistics.", Default: true},
+	{Name: "network_stats", Description: "Host network interface statistics."},
+	{Name: "job_queue", Description: "Moonraker job queue.", Default: true},
+	{Name: "system_info", Description: "Host system information.", Default: true},
+	{Name: "directory_info", Description: "Disk usage of the gcodes directory."},
+	{Name: "printer_objects", Description: "Klipper printer object status, temperatures, fans, and mcu statistics."},
+	{Name: "history", Description: "Print job history totals and current print."},
+	{Name: "gcode_store", Description: "Macro execution counts observed in the gcode store."},
+	{Name: "server_info", Description: "Moonraker version and loaded components."},
+	{Name: "logs", Description: "Size of the Klipper and Moonraker log files."},
+	{Name: "power", Description: "State of the Moonraker power devices, e.g. smart plugs and relays."},
+	{Name: "update_manager", Description: "Update status of the components managed by the Moonraker update_manager."},
+	{Name: "webcams", Description: "Webcams configured in Moonraker."},
+	{Name: "announcements", Description: "Announcements from the feeds subscribed to in Moonraker."},
+	{Name: "agents", Description: "Agents connected to Moonraker, e.g. Obico and OctoEverywhere."},
+	{Name: "temperature", Description: "(Deprecated) Cached temperature data, use printer_objects instead."},
+}
+
+// DefaultModules returns the names of the modules that are collected when no
+// modules are specified.
+func DefaultModules() []string {
+	modules := []string{}
+	for _, m := range Modules {
+		if m.Default {
+			modules = append(modules, m.Name)
+		}
+	}
+	return modules
+}
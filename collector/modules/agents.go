@@ -0,0 +1,29 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-extensions
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Agents exports the agents connected to Moonraker, e.g. the Obico,
+// OctoEverywhere, and Mobileraker companions, so a disconnected cloud tunnel
+// can be alerted on. Agents are only listed while they are connected.
+func Agents(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.Extensions()
+	if err != nil {
+		return err
+	}
+
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_agents_connected", "Number of agents connected to Moonraker.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Agents)))
+	infoDesc := prometheus.NewDesc("klipper_moonraker_agent_info", "An agent connected to Moonraker, with its version and type.", []string{"agent", "version", "type"}, nil)
+	for _, agent := range result.Result.Agents {
+		SendConstMetric(ch, infoDesc, prometheus.GaugeValue, 1, agent.Name, agent.Version, agent.Type)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-announcements
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Announcements exports the number of announcements Moonraker has fetched from
+// the subscribed feeds, e.g. notices of breaking changes, and the number that
+// have not been dismissed in a web client yet.
+func Announcements(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.Announcements()
+	if err != nil {
+		return err
+	}
+
+	unread := 0
+	for _, entry := range result.Result.Entries {
+		if !entry.Dismissed {
+			unread++
+		}
+	}
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_announcements_total", "Number of announcements from the subscribed feeds, including dismissed announcements.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Entries)))
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_announcements_unread", "Number of announcements that have not been dismissed.", nil, nil),
+		prometheus.GaugeValue,
+		float64(unread))
+	return nil
+}
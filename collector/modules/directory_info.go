@@ -0,0 +1,29 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-directory-information
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+func DirectoryInfo(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.DirectoryInfo()
+	if err != nil {
+		return err
+	}
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_disk_usage_total", "Klipper total disk space.", nil, nil),
+		prometheus.GaugeValue,
+		float64(result.Result.DiskUsage.Total))
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_disk_usage_used", "Klipper used disk space.", nil, nil),
+		prometheus.GaugeValue,
+		float64(result.Result.DiskUsage.Used))
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_disk_usage_available", "Klipper available disk space.", nil, nil),
+		prometheus.GaugeValue,
+		float64(result.Result.DiskUsage.Free))
+	return nil
+}
@@ -0,0 +1,62 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-available-files
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Logs exports the size of each log file in the logs root, e.g. klippy.log and
+// moonraker.log, and the number and size of the rotated copies of each log,
+// e.g. klippy.log.2024-01-01 or moonraker.log.1.
+func Logs(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.LogFiles()
+	if err != nil {
+		return err
+	}
+
+	logs := []moonraker.File{}
+	total := int64(0)
+	for _, file := range result.Result {
+		if strings.HasSuffix(file.Path, ".log") {
+			logs = append(logs, file)
+		}
+		total += file.Size
+	}
+	// match the longest log names first so a rotated file is only counted
+	// against one log
+	sort.Slice(logs, func(i, j int) bool { return len(logs[i].Path) > len(logs[j].Path) })
+	rotatedFiles := make(map[string]int)
+	rotatedBytes := make(map[string]int64)
+	for _, file := range result.Result {
+		for _, l := range logs {
+			if strings.HasPrefix(file.Path, l.Path+".") {
+				rotatedFiles[l.Path]++
+				rotatedBytes[l.Path] += file.Size
+				break
+			}
+		}
+	}
+
+	fileLabels := []string{"file"}
+	sizeDesc := prometheus.NewDesc("klipper_log_file_size_bytes", "Size in bytes of the log file.", fileLabels, nil)
+	modifiedDesc := prometheus.NewDesc("klipper_log_file_modified_timestamp_seconds", "Unix timestamp of the last modification of the log file.", fileLabels, nil)
+	rotatedFilesDesc := prometheus.NewDesc("klipper_log_file_rotated_files", "Number of rotated copies of the log file.", fileLabels, nil)
+	rotatedBytesDesc := prometheus.NewDesc("klipper_log_file_rotated_size_bytes", "Total size in bytes of the rotated copies of the log file.", fileLabels, nil)
+	for _, l := range logs {
+		SendConstMetric(ch, sizeDesc, prometheus.GaugeValue, float64(l.Size), l.Path)
+		SendConstMetric(ch, modifiedDesc, prometheus.GaugeValue, l.Modified, l.Path)
+		SendConstMetric(ch, rotatedFilesDesc, prometheus.GaugeValue, float64(rotatedFiles[l.Path]), l.Path)
+		SendConstMetric(ch, rotatedBytesDesc, prometheus.GaugeValue, float64(rotatedBytes[l.Path]), l.Path)
+	}
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_logs_size_bytes", "Total size in bytes of all files in the logs directory.", nil, nil),
+		prometheus.GaugeValue,
+		float64(total))
+	return nil
+}
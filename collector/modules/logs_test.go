@@ -0,0 +1,45 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker/mock_moonraker"
+)
+
+func TestLogs(t *testing.T) {
+	var response moonraker.FileListResponse
+	decode(t, `{"result": [
+		{"path": "klippy.log", "modified": 1700000000.5, "size": 1000, "permissions": "rw"},
+		{"path": "klippy.log.2024-01-01", "modified": 1690000000, "size": 300, "permissions": "rw"},
+		{"path": "klippy.log.2024-01-02", "modified": 1690000100, "size": 200, "permissions": "rw"},
+		{"path": "moonraker.log", "modified": 1700000001, "size": 400, "permissions": "rw"},
+		{"path": "moonraker.log.1", "modified": 1690000200, "size": 50, "permissions": "rw"},
+		{"path": "crowsnest.txt", "modified": 1690000300, "size": 10, "permissions": "rw"}
+	]}`, &response)
+
+	ctrl := gomock.NewController(t)
+	api := mock_moonraker.NewMockAPI(ctrl)
+	api.EXPECT().LogFiles().Return(&response, nil)
+
+	compare(t, func(ch chan<- prometheus.Metric) error { return Logs(api, ch) }, `
+# HELP klipper_log_file_rotated_files Number of rotated copies of the log file.
+# TYPE klipper_log_file_rotated_files gauge
+klipper_log_file_rotated_files{file="klippy.log"} 2
+klipper_log_file_rotated_files{file="moonraker.log"} 1
+# HELP klipper_log_file_rotated_size_bytes Total size in bytes of the rotated copies of the log file.
+# TYPE klipper_log_file_rotated_size_bytes gauge
+klipper_log_file_rotated_size_bytes{file="klippy.log"} 500
+klipper_log_file_rotated_size_bytes{file="moonraker.log"} 50
+# HELP klipper_log_file_size_bytes Size in bytes of the log file.
+# TYPE klipper_log_file_size_bytes gauge
+klipper_log_file_size_bytes{file="klippy.log"} 1000
+klipper_log_file_size_bytes{file="moonraker.log"} 400
+# HELP klipper_logs_size_bytes Total size in bytes of all files in the logs directory.
+# TYPE klipper_logs_size_bytes gauge
+klipper_logs_size_bytes 1960
+`, "klipper_log_file_rotated_files", "klipper_log_file_rotated_size_bytes", "klipper_log_file_size_bytes", "klipper_logs_size_bytes")
+}
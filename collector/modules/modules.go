@@ -0,0 +1,37 @@
+// Package modules contains the modules that are collected only from the
+// responses of the Moonraker API, without the state kept for the target
+// between scrapes, so they can be tested with a mock of the moonraker.API.
+package modules
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Emitter sends the metrics of a module to ch from the responses of the
+// Moonraker API. The error of a failed request is returned once it has been
+// logged and recorded against the module by the API, and the metrics of the
+// module are left out.
+type Emitter func(api moonraker.API, ch chan<- prometheus.Metric) error
+
+// SendConstMetric sends a constant metric to ch. If the metric cannot be
+// created, e.g. the label values are not valid UTF-8, the error is logged and
+// the sample is skipped so the remaining metrics are still collected.
+func SendConstMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	metric, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if err != nil {
+		log.Warnf("Skipping invalid sample %s: %v", desc, err)
+		return
+	}
+	ch <- metric
+}
+
+// BoolToFloat64 returns 1 for true and 0 for false.
+func BoolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// emitterCollector collects the metrics of the emitter for testutil. It is an
+// unchecked collector, so the emitter is only called once by the gather and
+// the mock API expects each request once.
+type emitterCollector struct {
+	t       *testing.T
+	emitter func(ch chan<- prometheus.Metric) error
+}
+
+func (c emitterCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c emitterCollector) Collect(ch chan<- prometheus.Metric) {
+	if err := c.emitter(ch); err != nil {
+		c.t.Errorf("emitter error: %v", err)
+	}
+}
+
+// decode decodes the Moonraker JSON response into response.
+func decode(t *testing.T, body string, response interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), response); err != nil {
+		t.Fatalf("unable to decode the response: %v", err)
+	}
+}
+
+// compare checks the metrics of the emitter match the expected metrics in the
+// text exposition format.
+func compare(t *testing.T, emitter func(ch chan<- prometheus.Metric) error, expected string, metricNames ...string) {
+	t.Helper()
+	err := testutil.CollectAndCompare(emitterCollector{t: t, emitter: emitter}, strings.NewReader(expected), metricNames...)
+	if err != nil {
+		t.Error(err)
+	}
+}
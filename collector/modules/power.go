@@ -0,0 +1,30 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-device-list
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Power exports the state of each power device configured in the Moonraker
+// `[power]` sections, e.g. smart plugs and relays. A device that cannot be
+// reached reports the `error` status and is not on.
+func Power(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.PowerDevices()
+	if err != nil {
+		return err
+	}
+
+	labels := []string{"device"}
+	onDesc := prometheus.NewDesc("klipper_power_device_on", "Set to 1 if the power device is on.", labels, nil)
+	lockedDesc := prometheus.NewDesc("klipper_power_device_locked", "Set to 1 if the power device cannot be switched while printing.", labels, nil)
+	infoDesc := prometheus.NewDesc("klipper_power_device_info", "The type and status of the power device, e.g. on, off, init, or error.", []string{"device", "type", "status"}, nil)
+	for _, device := range result.Result.Devices {
+		SendConstMetric(ch, onDesc, prometheus.GaugeValue, BoolToFloat64(device.Status == "on"), device.Device)
+		SendConstMetric(ch, lockedDesc, prometheus.GaugeValue, BoolToFloat64(device.LockedWhilePrinting), device.Device)
+		SendConstMetric(ch, infoDesc, prometheus.GaugeValue, 1, device.Device, device.Type, device.Status)
+	}
+	return nil
+}
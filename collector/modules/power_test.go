@@ -0,0 +1,58 @@
+package modules
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker/mock_moonraker"
+)
+
+func TestPower(t *testing.T) {
+	var response moonraker.PowerDevicesResponse
+	decode(t, `{"result": {"devices": [
+		{"device": "printer", "status": "on", "locked_while_printing": true, "type": "tplink_smartplug"},
+		{"device": "lights", "status": "off", "locked_while_printing": false, "type": "gpio"},
+		{"device": "fan", "status": "error", "locked_while_printing": false, "type": "http"}
+	]}}`, &response)
+
+	ctrl := gomock.NewController(t)
+	api := mock_moonraker.NewMockAPI(ctrl)
+	api.EXPECT().PowerDevices().Return(&response, nil)
+
+	compare(t, func(ch chan<- prometheus.Metric) error { return Power(api, ch) }, `
+# HELP klipper_power_device_info The type and status of the power device, e.g. on, off, init, or error.
+# TYPE klipper_power_device_info gauge
+klipper_power_device_info{device="fan",status="error",type="http"} 1
+klipper_power_device_info{device="lights",status="off",type="gpio"} 1
+klipper_power_device_info{device="printer",status="on",type="tplink_smartplug"} 1
+# HELP klipper_power_device_locked Set to 1 if the power device cannot be switched while printing.
+# TYPE klipper_power_device_locked gauge
+klipper_power_device_locked{device="fan"} 0
+klipper_power_device_locked{device="lights"} 0
+klipper_power_device_locked{device="printer"} 1
+# HELP klipper_power_device_on Set to 1 if the power device is on.
+# TYPE klipper_power_device_on gauge
+klipper_power_device_on{device="fan"} 0
+klipper_power_device_on{device="lights"} 0
+klipper_power_device_on{device="printer"} 1
+`)
+}
+
+func TestPowerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	api := mock_moonraker.NewMockAPI(ctrl)
+	want := errors.New("unavailable")
+	api.EXPECT().PowerDevices().Return(nil, want)
+
+	ch := make(chan prometheus.Metric, 1)
+	if err := Power(api, ch); !errors.Is(err, want) {
+		t.Errorf("Power() error = %v, want %v", err, want)
+	}
+	if len(ch) != 0 {
+		t.Errorf("Power() sent %d metrics on error, want 0", len(ch))
+	}
+}
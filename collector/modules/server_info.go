@@ -0,0 +1,39 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#query-server-info
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// ServerInfo exports the Moonraker version and the loaded components, e.g.
+// `power`, `spoolman`, or `timelapse`, so dashboards can adapt to what is
+// installed and it is clear why a module that depends on a component that is
+// not loaded returns no metrics.
+func ServerInfo(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.ServerInfo()
+	if err != nil {
+		return err
+	}
+
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_version_info", "The Moonraker version and API version.", []string{"version", "api_version"}, nil),
+		prometheus.GaugeValue,
+		1,
+		result.Result.MoonrakerVersion, result.Result.APIVersionString)
+	componentDesc := prometheus.NewDesc("klipper_moonraker_component_info", "Set to 1 for each Moonraker component that is loaded.", []string{"component"}, nil)
+	for _, component := range result.Result.Components {
+		SendConstMetric(ch, componentDesc, prometheus.GaugeValue, 1, component)
+	}
+	failedDesc := prometheus.NewDesc("klipper_moonraker_component_failed", "Set to 1 for each Moonraker component that failed to load.", []string{"component"}, nil)
+	for _, component := range result.Result.FailedComponents {
+		SendConstMetric(ch, failedDesc, prometheus.GaugeValue, 1, component)
+	}
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_warnings", "The number of warnings reported by Moonraker, e.g. for invalid configuration.", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(result.Result.Warnings)))
+	return nil
+}
@@ -0,0 +1,57 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-update-status
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// UpdateManager exports the update status of each component managed by the
+// Moonraker update_manager, e.g. to alert when Klipper or Moonraker fall
+// behind the remote version. The status is the one last refreshed by
+// Moonraker, the exporter does not request a refresh.
+func UpdateManager(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.UpdateStatus()
+	if err != nil {
+		return err
+	}
+
+	labels := []string{"component"}
+	commitsBehindDesc := prometheus.NewDesc("klipper_update_commits_behind", "Number of commits the component is behind the remote version.", labels, nil)
+	availableDesc := prometheus.NewDesc("klipper_update_available", "Set to 1 if the remote version of the component differs from the installed version.", labels, nil)
+	dirtyDesc := prometheus.NewDesc("klipper_update_is_dirty", "Set to 1 if the component repository has local changes.", labels, nil)
+	validDesc := prometheus.NewDesc("klipper_update_is_valid", "Set to 1 if the component installation is valid and can be updated.", labels, nil)
+	infoDesc := prometheus.NewDesc("klipper_update_version_info", "The installed and remote version of the component.", []string{"component", "version", "remote_version"}, nil)
+	for name, component := range result.Result.VersionInfo {
+		if component.PackageCount != nil {
+			SendConstMetric(ch,
+				prometheus.NewDesc("klipper_update_system_packages", "Number of system packages that can be updated.", nil, nil),
+				prometheus.GaugeValue,
+				float64(*component.PackageCount))
+			continue
+		}
+		commitsBehind := len(component.CommitsBehind)
+		if component.CommitsBehindCount != nil {
+			commitsBehind = *component.CommitsBehindCount
+		}
+		SendConstMetric(ch, commitsBehindDesc, prometheus.GaugeValue, float64(commitsBehind), name)
+		available := component.RemoteVersion != "" && component.RemoteVersion != "?" && component.RemoteVersion != component.Version
+		SendConstMetric(ch, availableDesc, prometheus.GaugeValue, BoolToFloat64(available), name)
+		SendConstMetric(ch, dirtyDesc, prometheus.GaugeValue, BoolToFloat64(component.IsDirty), name)
+		if component.IsValid != nil {
+			SendConstMetric(ch, validDesc, prometheus.GaugeValue, BoolToFloat64(*component.IsValid), name)
+		}
+		SendConstMetric(ch, infoDesc, prometheus.GaugeValue, 1, name, component.Version, component.RemoteVersion)
+	}
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_update_busy", "Set to 1 while the update_manager is refreshing or updating a component.", nil, nil),
+		prometheus.GaugeValue,
+		BoolToFloat64(result.Result.Busy))
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_update_github_requests_remaining", "Number of GitHub API requests the update_manager can make before it is rate limited.", nil, nil),
+		prometheus.GaugeValue,
+		result.Result.GithubRequestsRemaining)
+	return nil
+}
@@ -0,0 +1,36 @@
+package modules
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-webcams
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Webcams exports the webcams configured in Moonraker, either in the
+// `[webcam]` sections or added by the web clients, so a camera that is
+// dropped, e.g. by crowsnest, can be alerted on.
+func Webcams(api moonraker.API, ch chan<- prometheus.Metric) error {
+	result, err := api.Webcams()
+	if err != nil {
+		return err
+	}
+
+	SendConstMetric(ch,
+		prometheus.NewDesc("klipper_webcams_configured", "Number of webcams configured in Moonraker.", nil, nil),
+		prometheus.GaugeValue, float64(len(result.Result.Webcams)))
+
+	labels := []string{"webcam"}
+	enabledDesc := prometheus.NewDesc("klipper_webcam_enabled", "Set to 1 if the webcam is enabled.", labels, nil)
+	streamDesc := prometheus.NewDesc("klipper_webcam_stream_url_configured", "Set to 1 if the webcam has a stream URL.", labels, nil)
+	snapshotDesc := prometheus.NewDesc("klipper_webcam_snapshot_url_configured", "Set to 1 if the webcam has a snapshot URL.", labels, nil)
+	infoDesc := prometheus.NewDesc("klipper_webcam_info", "The streaming service, location, and configuration source of the webcam.", []string{"webcam", "service", "location", "source"}, nil)
+	for _, webcam := range result.Result.Webcams {
+		SendConstMetric(ch, enabledDesc, prometheus.GaugeValue, BoolToFloat64(webcam.Enabled), webcam.Name)
+		SendConstMetric(ch, streamDesc, prometheus.GaugeValue, BoolToFloat64(webcam.StreamURL != ""), webcam.Name)
+		SendConstMetric(ch, snapshotDesc, prometheus.GaugeValue, BoolToFloat64(webcam.SnapshotURL != ""), webcam.Name)
+		SendConstMetric(ch, infoDesc, prometheus.GaugeValue, 1, webcam.Name, webcam.Service, webcam.Location, webcam.Source)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// outputPinConfig is the printer.cfg configuration of an `output_pin`.
+type outputPinConfig struct {
+	pwm           bool
+	hardwarePwm   bool
+	cycleTime     float64
+	value         float64
+	shutdownValue float64
+}
+
+// outputPinConfigs returns the configuration of the output pins keyed by lower
+// case pin name.
+func (c Collector) outputPinConfigs(module string) map[string]outputPinConfig {
+	pins := make(map[string]outputPinConfig)
+	for section, settings := range c.printerConfig(module) {
+		if !strings.HasPrefix(section, "output_pin ") {
+			continue
+		}
+		pins[strings.TrimPrefix(section, "output_pin ")] = outputPinConfig{
+			pwm:           settings["pwm"] == true,
+			hardwarePwm:   settings["hardware_pwm"] == true,
+			cycleTime:     settingFloat64(settings["cycle_time"], 0),
+			value:         settingFloat64(settings["value"], 0),
+			shutdownValue: settingFloat64(settings["shutdown_value"], 0),
+		}
+	}
+	return pins
+}
+
+// collectOutputPinConfig exports the configured mode, cycle time, and static
+// values of each output pin, so the live pin value can be displayed correctly
+// without consulting printer.cfg.
+func (c Collector) collectOutputPinConfig(ch chan<- prometheus.Metric, module string, outputPins map[string]moonraker.PrinterObjectOutputPin) {
+	if len(outputPins) == 0 {
+		return
+	}
+	configs := c.outputPinConfigs(module)
+	pinLabels := []string{"pin"}
+	for name := range outputPins {
+		config, ok := configs[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		pinName := getValidLabelName(name)
+		mode := "digital"
+		if config.pwm {
+			mode = "pwm"
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_output_pin_info", "The configured mode of the output pin.", []string{"pin", "mode", "hardware_pwm"}, nil),
+			prometheus.GaugeValue,
+			1,
+			pinName, mode, strconv.FormatBool(config.hardwarePwm))
+		if config.pwm {
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_output_pin_cycle_time_seconds", "The configured PWM cycle time of the output pin.", pinLabels, nil),
+				prometheus.GaugeValue,
+				config.cycleTime,
+				pinName)
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_output_pin_configured_value", "The configured value the output pin is set to when Klipper starts.", pinLabels, nil),
+			prometheus.GaugeValue,
+			config.value,
+			pinName)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_output_pin_shutdown_value", "The configured value the output pin is set to when the printer shuts down.", pinLabels, nil),
+			prometheus.GaugeValue,
+			config.shutdownValue,
+			pinName)
+	}
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// pauseReason returns the reason for a pause, `runout` if an enabled filament
+// sensor is not detecting filament, otherwise `manual`, and the name of the
+// runout sensor.
+func pauseReason(status moonraker.PrinterObjectStatus) (string, string) {
+	for name, sensor := range status.FilamentSwitch {
+		if sensor.Enabled && !sensor.FilamentDetected {
+			return "runout", name
+		}
+	}
+	for name, sensor := range status.FilamentMotion {
+		if sensor.Enabled && !sensor.FilamentDetected {
+			return "runout", name
+		}
+	}
+	return "manual", ""
+}
+
+// collectPauses exports the number of times the current print has been paused
+// and resumed, and the reason for the last pause. Moonraker does not report
+// why a print was paused, so a pause while a filament sensor is reporting no
+// filament is assumed to be a runout.
+func (c Collector) collectPauses(ch chan<- prometheus.Metric, event string, status moonraker.PrinterObjectStatus) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	switch event {
+	case "start":
+		state.printPauses = 0
+		state.printResumes = 0
+		state.lastPauseReason = ""
+		state.lastPauseSensor = ""
+	case "pause":
+		state.printPauses++
+		state.lastPauseReason, state.lastPauseSensor = pauseReason(status)
+	case "resume":
+		state.printResumes++
+	}
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_pauses", "Number of times the current print has been paused.", nil, nil),
+		prometheus.GaugeValue,
+		float64(state.printPauses))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_resumes", "Number of times the current print has been resumed.", nil, nil),
+		prometheus.GaugeValue,
+		float64(state.printResumes))
+	if state.lastPauseReason != "" {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_print_last_pause_info", "Reason for the last pause of the current print, runout or manual.", []string{"reason", "sensor"}, nil),
+			prometheus.GaugeValue,
+			1,
+			state.lastPauseReason, state.lastPauseSensor)
+	}
+}
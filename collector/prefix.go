@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMetricsPrefix is the namespace of the metric names.
+const DefaultMetricsPrefix = "klipper"
+
+// collectorFunc adapts a collect function to an unchecked prometheus.Collector.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+// collectWithPrefix collects the metrics and sends them to ch with the
+// `klipper` namespace of the metric names replaced by the prefix, e.g.
+// `klipper_extruder_temperature` becomes `printer_extruder_temperature`.
+func (c Collector) collectWithPrefix(ch chan<- prometheus.Metric, prefix string) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectorFunc(c.collect))
+	mfs, err := registry.Gather()
+	if err != nil {
+		log.Errorf("Collection of %s failed: %v", c.target, err)
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if strings.HasPrefix(name, DefaultMetricsPrefix+"_") {
+			name = prefix + strings.TrimPrefix(name, DefaultMetricsPrefix)
+		}
+		for _, m := range mf.Metric {
+			metric, err := constMetric(name, mf.GetHelp(), mf.GetType(), m)
+			if err != nil {
+				log.Errorf("Unable to rename %s: %v", mf.GetName(), err)
+				continue
+			}
+			ch <- metric
+		}
+	}
+}
+
+// constMetric converts a gathered metric back to a prometheus.Metric with the
+// metric name.
+func constMetric(name string, help string, metricType dto.MetricType, m *dto.Metric) (prometheus.Metric, error) {
+	labelNames := []string{}
+	labelValues := []string{}
+	for _, label := range m.Label {
+		labelNames = append(labelNames, label.GetName())
+		labelValues = append(labelValues, label.GetValue())
+	}
+	desc := prometheus.NewDesc(name, help, labelNames, nil)
+
+	var metric prometheus.Metric
+	var err error
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.Counter.GetValue(), labelValues...)
+	case dto.MetricType_GAUGE:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.Gauge.GetValue(), labelValues...)
+	case dto.MetricType_HISTOGRAM:
+		buckets := make(map[float64]uint64)
+		for _, bucket := range m.Histogram.Bucket {
+			buckets[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+		}
+		metric, err = prometheus.NewConstHistogram(desc, m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum(), buckets, labelValues...)
+	case dto.MetricType_SUMMARY:
+		quantiles := make(map[float64]float64)
+		for _, quantile := range m.Summary.Quantile {
+			quantiles[quantile.GetQuantile()] = quantile.GetValue()
+		}
+		metric, err = prometheus.NewConstSummary(desc, m.Summary.GetSampleCount(), m.Summary.GetSampleSum(), quantiles, labelValues...)
+	default:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.Untyped.GetValue(), labelValues...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.TimestampMs != nil {
+		metric = prometheus.NewMetricWithTimestamp(time.UnixMilli(m.GetTimestampMs()), metric)
+	}
+	return metric, nil
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// maxPrintMessageLength is the maximum number of characters of the print_stats
+// message exported as a label value, to bound the size of the series.
+const maxPrintMessageLength = 200
+
+// sanitizeMessage returns the message on a single line, with runs of
+// whitespace and control characters replaced with a single space, truncated to
+// at most maxLength characters.
+func sanitizeMessage(message string, maxLength int) string {
+	message = strings.ToValidUTF8(message, "")
+	message = strings.Join(strings.FieldsFunc(message, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+	if utf8.RuneCountInString(message) > maxLength {
+		message = string([]rune(message)[:maxLength-3]) + "..."
+	}
+	return message
+}
+
+// collectPrintMessage exports the print_stats message, e.g. the reason a print
+// failed while the print state is error, so alert notifications can include
+// it. The message is empty while there is no error.
+func (c Collector) collectPrintMessage(ch chan<- prometheus.Metric, printStats moonraker.PrinterObjectPrintStats) {
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_message_info", "The print state and the print_stats message describing it, e.g. the reason the print failed.", []string{"state", "message"}, nil),
+		prometheus.GaugeValue,
+		1,
+		printStats.State, sanitizeMessage(printStats.Message, maxPrintMessageLength))
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectPrintPeaks exports the highest toolhead and extruder velocity and
+// acceleration limit of the current print, to show whether the configured
+// limits are reached by the slicer profile. The velocities are sampled from
+// the motion_report at each scrape while printing, so short peaks between
+// scrapes are not seen. Klipper does not report the live acceleration, so the
+// highest acceleration limit in effect while printing is used instead, e.g.
+// as set by the M204 commands of the slicer.
+func (c Collector) collectPrintPeaks(ch chan<- prometheus.Metric, event string, status moonraker.PrinterObjectStatus) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if event == "start" {
+		state.printMaxVelocity = 0
+		state.printMaxExtruderVelocity = 0
+		state.printMaxAccel = 0
+	}
+	if status.PrintStats.State == "printing" {
+		if status.MotionReport.LiveVelocity > state.printMaxVelocity {
+			state.printMaxVelocity = status.MotionReport.LiveVelocity
+		}
+		if status.MotionReport.LiveExtruderVelocity > state.printMaxExtruderVelocity {
+			state.printMaxExtruderVelocity = status.MotionReport.LiveExtruderVelocity
+		}
+		if status.Toolhead.MaxAccel > state.printMaxAccel {
+			state.printMaxAccel = status.Toolhead.MaxAccel
+		}
+	}
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_max_velocity_mm_per_second", "Highest toolhead velocity seen while printing the current print.", nil, nil),
+		prometheus.GaugeValue,
+		state.printMaxVelocity)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_max_extruder_velocity_mm_per_second", "Highest extruder velocity seen while printing the current print.", nil, nil),
+		prometheus.GaugeValue,
+		state.printMaxExtruderVelocity)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_print_max_accel_mm_per_second_squared", "Highest toolhead acceleration limit in effect while printing the current print.", nil, nil),
+		prometheus.GaugeValue,
+		state.printMaxAccel)
+}
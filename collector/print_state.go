@@ -0,0 +1,36 @@
+package collector
+
+// printEvent returns the event for the change from the previous print_stats
+// state, or an empty string if the change is not an event.
+func printEvent(previous string, current string) string {
+	if previous == current || previous == "" {
+		return ""
+	}
+	switch current {
+	case "printing":
+		if previous == "paused" {
+			return "resume"
+		}
+		return "start"
+	case "paused":
+		return "pause"
+	case "complete":
+		return "complete"
+	case "cancelled":
+		return "cancel"
+	case "error":
+		return "error"
+	}
+	return ""
+}
+
+// updatePrintState records the print_stats state of the current scrape and
+// returns the state at the previous scrape.
+func (c Collector) updatePrintState(current string) string {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	previous := state.lastPrintState
+	state.lastPrintState = current
+	return previous
+}
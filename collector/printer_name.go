@@ -0,0 +1,60 @@
+package collector
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-database-item
+
+import (
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// printerNameItems are the Moonraker database items the web clients save the
+// printer name in, in the order they are used.
+var printerNameItems = []struct {
+	namespace string
+	key       string
+}{
+	{"mainsail", "general.printername"},
+	{"fluidd", "uiSettings.general.instanceName"},
+}
+
+// printerName returns the printer name set in the Mainsail or Fluidd settings
+// saved in the Moonraker database, or an empty string if it is not set. The
+// name is fetched once per target, and again after a failed request.
+func (c Collector) printerName() string {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	name, ok := state.printerName, state.printerNameFetched
+	state.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	for _, item := range printerNameItems {
+		response, err := c.api("printer_name").DatabaseItem(item.namespace, item.key)
+		var statusError *moonrakerStatusError
+		if errors.As(err, &statusError) {
+			// the web client is not installed or the name is not set
+			continue
+		}
+		if err != nil {
+			return ""
+		}
+		if value, ok := response.Result.Value.(string); ok && strings.TrimSpace(value) != "" {
+			name = strings.TrimSpace(value)
+			break
+		}
+	}
+	if name != "" {
+		log.Infof("Using printer name '%s' for %s", name, c.target)
+	} else {
+		log.Infof("No printer name is set for %s", c.target)
+	}
+
+	state.mu.Lock()
+	state.printerName = name
+	state.printerNameFetched = true
+	state.mu.Unlock()
+	return name
+}
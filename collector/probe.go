@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// beaconProbeName is the probe label of the Beacon probe, which is configured
+// without a name.
+const beaconProbeName = "beacon"
+
+// collectProbes exports the coil temperature and readings of eddy current
+// probes, labeled by probe. The thermal drift of the coil changes the measured
+// distance, so the coil temperature at the start of a print directly affects
+// the first layer.
+func (c Collector) collectProbes(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	labels := []string{"probe"}
+	coilTemperature := prometheus.NewDesc("klipper_probe_coil_temperature_celsius", "Klipper eddy current probe coil temperature.", labels, nil)
+	lastZResult := prometheus.NewDesc("klipper_probe_last_z_result_mm", "Klipper eddy current probe z position of the last probe.", labels, nil)
+
+	for name, probe := range status.TemperatureProbes {
+		sendConstMetric(ch, coilTemperature, prometheus.GaugeValue, probe.Temperature, name)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_probe_estimated_expansion_mm", "Klipper eddy current probe estimated thermal expansion at the coil temperature.", labels, nil),
+			prometheus.GaugeValue,
+			probe.EstimatedExpansion,
+			name)
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_probe_drift_compensation_enabled", "Klipper eddy current probe thermal drift compensation is enabled.", labels, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(probe.CompensationEnabled),
+			name)
+	}
+
+	for name, probe := range status.EddyProbes {
+		sendConstMetric(ch, lastZResult, prometheus.GaugeValue, probe.LastZResult, name)
+	}
+
+	beacon := status.Beacon
+	if beacon == nil {
+		return
+	}
+	if beacon.LastZResult != nil {
+		sendConstMetric(ch, lastZResult, prometheus.GaugeValue, *beacon.LastZResult, beaconProbeName)
+	}
+	if beacon.LastSample == nil {
+		return
+	}
+	if beacon.LastSample.Temp != nil {
+		sendConstMetric(ch, coilTemperature, prometheus.GaugeValue, *beacon.LastSample.Temp, beaconProbeName)
+	}
+	if beacon.LastSample.Freq != nil {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_probe_frequency_hertz", "Klipper eddy current probe coil frequency.", labels, nil),
+			prometheus.GaugeValue,
+			*beacon.LastSample.Freq,
+			beaconProbeName)
+	}
+	if beacon.LastSample.Dist != nil {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_probe_distance_mm", "Klipper eddy current probe measured distance to the bed.", labels, nil),
+			prometheus.GaugeValue,
+			*beacon.LastSample.Dist,
+			beaconProbeName)
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// heaterPowers returns the current power fraction of each heater, keyed by
+// the heater name used in printer.cfg.
+func heaterPowers(status moonraker.PrinterObjectStatus) map[string]float64 {
+	powers := map[string]float64{
+		"extruder":   status.Extruder.Power,
+		"heater_bed": status.HeaterBed.Power,
+	}
+	for name, heater := range status.HeaterGenerics {
+		powers[name] = heater.Power
+	}
+	return powers
+}
+
+// collectPSULoad exports the estimated power drawn by each heater with a
+// configured wattage from its current power fraction, the total of all of the
+// heaters, and the total as a ratio of the PSU capacity if configured, to
+// alert before multiple heaters together trip the PSU limit. Nothing is
+// exported if no heater wattages are configured.
+func (c Collector) collectPSULoad(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	if len(c.opts.HeaterWattage) == 0 {
+		return
+	}
+	powers := heaterPowers(status)
+	heaters := make([]string, 0, len(c.opts.HeaterWattage))
+	for heater := range c.opts.HeaterWattage {
+		heaters = append(heaters, heater)
+	}
+	sort.Strings(heaters)
+
+	wattsDesc := prometheus.NewDesc("klipper_heater_power_watts", "Estimated power drawn by the heater, from the power fraction and the configured heater wattage.", []string{"heater"}, nil)
+	total := 0.0
+	for _, heater := range heaters {
+		power, ok := powers[heater]
+		if !ok {
+			continue
+		}
+		watts := power * c.opts.HeaterWattage[heater]
+		total += watts
+		sendConstMetric(ch, wattsDesc, prometheus.GaugeValue, watts, heater)
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_heater_power_total_watts", "Estimated total power drawn by the heaters with a configured wattage.", nil, nil),
+		prometheus.GaugeValue,
+		total)
+	if c.opts.PSUCapacity > 0 {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_psu_load_ratio", "Estimated total power drawn by the heaters as a ratio of the configured PSU capacity.", nil, nil),
+			prometheus.GaugeValue,
+			total/c.opts.PSUCapacity)
+	}
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultRetryAfter is used when a rate limited response does not include a
+// valid Retry-After header.
+const defaultRetryAfter = 60 * time.Second
+
+// rateLimitedTotal counts the rate limited (HTTP 429) responses received from
+// each target. Reported from the exporter's own `/metrics` endpoint.
+var rateLimitedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_rate_limited_total",
+		Help: "Number of HTTP 429 Too Many Requests responses received from the target.",
+	},
+	[]string{"target"},
+)
+
+// rateLimitedError is returned for requests to a target that has rate limited
+// the exporter, until the Retry-After time has passed.
+type rateLimitedError struct {
+	target string
+	until  time.Time
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("%s is rate limited until %s", e.target, e.until.Format(time.RFC3339))
+}
+
+// parseRetryAfter returns the time to wait from a Retry-After header value,
+// which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return defaultRetryAfter
+}
+
+// rateLimited returns an error if the target is still rate limited, in which
+// case no further requests are sent to it.
+func (c Collector) rateLimited() error {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if time.Now().Before(state.rateLimitedUntil) {
+		return &rateLimitedError{target: c.target, until: state.rateLimitedUntil}
+	}
+	return nil
+}
+
+// recordRateLimited stops requests to the target until the Retry-After time of
+// the rate limited response has passed.
+func (c Collector) recordRateLimited(res *moonrakerResponse) error {
+	now := time.Now()
+	until := now.Add(parseRetryAfter(res.header.Get("Retry-After"), now))
+	log.Warnf("%s rate limited the request to %s, skipping requests until %s", c.target, res.url, until.Format(time.RFC3339))
+	rateLimitedTotal.WithLabelValues(c.target).Inc()
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.rateLimitedUntil = until
+	return &rateLimitedError{target: c.target, until: until}
+}
+
+// collectRateLimit reports whether requests to the target are currently being
+// skipped because it rate limited the exporter.
+func (c Collector) collectRateLimit(ch chan<- prometheus.Metric) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	retryAfter := time.Until(state.rateLimitedUntil).Seconds()
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_rate_limited", "Set to 1 if requests to Moonraker are being skipped because the target responded with HTTP 429 Too Many Requests.", nil, nil),
+		prometheus.GaugeValue,
+		boolToFloat64(retryAfter > 0))
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_rate_limit_retry_after_seconds", "Seconds remaining until requests to the rate limited target are resumed.", nil, nil),
+		prometheus.GaugeValue,
+		retryAfter)
+}
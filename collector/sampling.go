@@ -0,0 +1,408 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// samplerIdleTimeout is how long the sampler of a target keeps sampling after
+// the last scrape, so targets that are no longer scraped stop being sampled.
+const samplerIdleTimeout = 5 * time.Minute
+
+// samplerPingInterval is how often the websocket connection is checked, as
+// Moonraker sends no status updates while the signals do not change. The
+// connection is reconnected when no pong is received within twice the interval.
+const samplerPingInterval = 30 * time.Second
+
+// samplerHandshakeTimeout is the maximum time to open the websocket connection.
+const samplerHandshakeTimeout = 10 * time.Second
+
+// samplerMaxBackoff is the longest wait between the attempts to reconnect the
+// websocket connection.
+const samplerMaxBackoff = time.Minute
+
+// samplerSubscribeID is the JSON-RPC request id of the subscription request.
+const samplerSubscribeID = 1
+
+// DefaultSampledSignals are the fast changing signals sampled by default.
+var DefaultSampledSignals = []string{"fan.rpm", "motion_report.live_velocity"}
+
+// SampledSignal is a printer object attribute that is sampled between scrapes.
+type SampledSignal struct {
+	Object    string
+	Attribute string
+}
+
+func (s SampledSignal) String() string {
+	return s.Object + "." + s.Attribute
+}
+
+// ParseSampledSignals parses `object.attribute` signals, e.g. `fan.rpm` or
+// `temperature_fan exhaust.rpm`.
+func ParseSampledSignals(signals []string) ([]SampledSignal, error) {
+	parsed := []SampledSignal{}
+	for _, signal := range signals {
+		i := strings.LastIndex(signal, ".")
+		if i <= 0 || i == len(signal)-1 {
+			return nil, fmt.Errorf("invalid sampled signal '%s', must be object.attribute", signal)
+		}
+		parsed = append(parsed, SampledSignal{Object: signal[:i], Attribute: signal[i+1:]})
+	}
+	return parsed, nil
+}
+
+// signalWindow is the minimum and maximum of a signal, and the sum of its
+// samples, since the previous scrape.
+type signalWindow struct {
+	min float64
+	max float64
+	// observed is true once the minimum and maximum are set
+	observed bool
+	sum      float64
+	count    int
+}
+
+// observe updates the minimum and maximum with a value of the signal.
+func (w *signalWindow) observe(value float64) {
+	if !w.observed || value < w.min {
+		w.min = value
+	}
+	if !w.observed || value > w.max {
+		w.max = value
+	}
+	w.observed = true
+}
+
+// add adds a sample of the signal.
+func (w *signalWindow) add(value float64) {
+	w.observe(value)
+	w.sum += value
+	w.count++
+}
+
+// signalSampler subscribes to the signals of a target on the Moonraker
+// websocket, so short transients between scrapes, e.g. a fan stalling for a
+// few seconds, are reported in the minimum and maximum of the scrape interval.
+// Every status update of the subscription is included in the minimum and
+// maximum, and the latest values are sampled at the sampling interval for the
+// average. Each scraper has its own windows, so the scrapers of a highly
+// available Prometheus pair each report their full scrape interval.
+type signalSampler struct {
+	c       Collector
+	signals []SampledSignal
+
+	mu sync.Mutex
+	// latest value of each signal received from the subscription
+	values map[SampledSignal]float64
+	// windows of each scraper since its previous read, and the time of the read
+	windows  map[string]map[SampledSignal]*signalWindow
+	lastRead map[string]time.Time
+	stopped  bool
+}
+
+func newSignalSampler(c Collector, signals []SampledSignal) *signalSampler {
+	return &signalSampler{
+		c:        c,
+		signals:  signals,
+		values:   make(map[SampledSignal]float64),
+		windows:  make(map[string]map[SampledSignal]*signalWindow),
+		lastRead: make(map[string]time.Time),
+	}
+}
+
+// samplerMessage is a JSON-RPC response or notification received on the
+// websocket.
+type samplerMessage struct {
+	jsonRPCResponse
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// samplerStatus is the status of the subscribed printer objects.
+type samplerStatus map[string]map[string]interface{}
+
+// run samples the signals until the target has not been scraped for the
+// samplerIdleTimeout, reconnecting the websocket when the connection fails.
+func (s *signalSampler) run(interval time.Duration) {
+	log.Infof("Sampling %v of %s every %s", s.signals, s.c.target, interval)
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.subscribe(interval)
+		if s.idle() {
+			log.Infof("Stopped sampling %s, not scraped for %s", s.c.target, samplerIdleTimeout)
+			return
+		}
+		if time.Since(start) > samplerMaxBackoff {
+			backoff = time.Second
+		}
+		log.Warnf("Sampling subscription of %s failed, reconnecting in %s: %v", s.c.target, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > samplerMaxBackoff {
+			backoff = samplerMaxBackoff
+		}
+	}
+}
+
+// subscribe opens the websocket connection and subscribes to the signals. The
+// latest values are sampled at the interval until the connection fails, or
+// nil is returned when the sampler is idle.
+func (s *signalSampler) subscribe(interval time.Duration) error {
+	target, err := moonraker.ParseTarget(s.c.target)
+	if err != nil {
+		return err
+	}
+	url := target.WebsocketURL()
+	// the upgrade request has the same headers as the HTTP API requests
+	req, err := s.c.newRequest(context.Background(), "GET", url, nil, s.c.apiKey)
+	if err != nil {
+		return err
+	}
+	dialer := websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: samplerHandshakeTimeout}
+	if s.c.opts.ProxyURL != nil {
+		dialer.Proxy = http.ProxyURL(s.c.opts.ProxyURL)
+	}
+	conn, res, err := dialer.Dial(url, req.Header)
+	if err != nil {
+		if res != nil {
+			return fmt.Errorf("%s returned HTTP status %s", url, res.Status)
+		}
+		return err
+	}
+	defer func() {
+		conn.Close()
+		s.clear()
+	}()
+	log.Debugf("Subscribed to %v on %s", s.signals, url)
+
+	readTimeout := 2 * samplerPingInterval
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	resubscribe := make(chan struct{}, 1)
+	// buffered so the receiver exits when the connection is closed
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.receive(conn, readTimeout, resubscribe)
+	}()
+	if err := s.sendSubscribe(conn); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ping := time.NewTicker(samplerPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-resubscribe:
+			if err := s.sendSubscribe(conn); err != nil {
+				return err
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(samplerHandshakeTimeout)); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if s.idle() {
+				return nil
+			}
+			s.sample()
+		}
+	}
+}
+
+// sendSubscribe sends the printer.objects.subscribe request of the signals.
+func (s *signalSampler) sendSubscribe(conn *websocket.Conn) error {
+	objects := make(map[string][]string)
+	for _, signal := range s.signals {
+		objects[signal.Object] = append(objects[signal.Object], signal.Attribute)
+	}
+	conn.SetWriteDeadline(time.Now().Add(samplerHandshakeTimeout))
+	return conn.WriteJSON(jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "printer.objects.subscribe",
+		Params:  map[string]interface{}{"objects": objects},
+		ID:      samplerSubscribeID,
+	})
+}
+
+// receive reads the subscription response and the status updates until the
+// connection fails. The signals are subscribed to again when Klippy is ready
+// after a restart, as the subscriptions do not survive the restart.
+func (s *signalSampler) receive(conn *websocket.Conn, readTimeout time.Duration, resubscribe chan<- struct{}) error {
+	for {
+		var msg samplerMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		switch {
+		case msg.Method == "" && msg.ID == samplerSubscribeID:
+			if msg.Error != nil {
+				// Klippy is not ready, subscribed again once it is
+				log.Warnf("Unable to subscribe to %v on %s: %s", s.signals, s.c.target, msg.Error.Message)
+				continue
+			}
+			var result struct {
+				Status samplerStatus `json:"status"`
+			}
+			if err := json.Unmarshal(msg.Result, &result); err != nil {
+				return err
+			}
+			s.update(result.Status)
+		case msg.Method == "notify_status_update":
+			var params []json.RawMessage
+			var status samplerStatus
+			if err := json.Unmarshal(msg.Params, &params); err != nil || len(params) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(params[0], &status); err != nil {
+				continue
+			}
+			s.update(status)
+		case msg.Method == "notify_klippy_ready":
+			select {
+			case resubscribe <- struct{}{}:
+			default:
+			}
+		case msg.Method == "notify_klippy_shutdown" || msg.Method == "notify_klippy_disconnected":
+			s.clear()
+		}
+	}
+}
+
+// update sets the latest values of the signals in the status, which only
+// includes the attributes that changed, and adds them to the minimum and
+// maximum of the windows.
+func (s *signalSampler) update(status samplerStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, signal := range s.signals {
+		value, ok := status[signal.Object][signal.Attribute].(float64)
+		if !ok {
+			continue
+		}
+		s.values[signal] = value
+		for _, windows := range s.windows {
+			windows[signal].observe(value)
+		}
+	}
+}
+
+// clear forgets the latest values, so no samples are added while the values
+// are not updated.
+func (s *signalSampler) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = make(map[SampledSignal]float64)
+}
+
+// sample adds the latest values of the signals to the windows.
+func (s *signalSampler) sample() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for signal, value := range s.values {
+		for _, windows := range s.windows {
+			windows[signal].add(value)
+		}
+	}
+}
+
+// idle forgets the scrapers that have not read their windows for the
+// samplerIdleTimeout, and stops the sampler once there are none left.
+func (s *signalSampler) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for scraper, lastRead := range s.lastRead {
+		if time.Since(lastRead) > samplerIdleTimeout {
+			delete(s.lastRead, scraper)
+			delete(s.windows, scraper)
+		}
+	}
+	if len(s.lastRead) == 0 {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+// read returns the windows of the signals since the previous read of the
+// scraper, or nil on its first read, and starts new windows with the latest
+// values.
+func (s *signalSampler) read(scraper string) map[SampledSignal]*signalWindow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	windows := s.windows[scraper]
+	fresh := make(map[SampledSignal]*signalWindow)
+	for _, signal := range s.signals {
+		fresh[signal] = &signalWindow{}
+		if value, ok := s.values[signal]; ok {
+			fresh[signal].observe(value)
+		}
+	}
+	s.windows[scraper] = fresh
+	s.lastRead[scraper] = time.Now()
+	return windows
+}
+
+// collectSampledSignals exports the minimum, maximum, and average of the
+// signals sampled since the previous scrape of the scraper, starting the
+// sampler of the target on the first scrape. Nothing is reported until the
+// second scrape of each scraper.
+func (c Collector) collectSampledSignals(ch chan<- prometheus.Metric) {
+	if c.opts.SamplingInterval <= 0 || len(c.opts.SampledSignals) == 0 {
+		return
+	}
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	sampler := state.sampler
+	if sampler != nil {
+		sampler.mu.Lock()
+		if sampler.stopped {
+			sampler = nil
+		}
+		sampler.mu.Unlock()
+	}
+	if sampler == nil {
+		// the subscription runs in the background, independent of the scrape
+		background := New(context.Background(), c.target, nil, c.apiKey, c.opts)
+		sampler = newSignalSampler(*background, c.opts.SampledSignals)
+		sampler.read(c.opts.Scraper)
+		state.sampler = sampler
+		state.mu.Unlock()
+		go sampler.run(c.opts.SamplingInterval)
+		return
+	}
+	state.mu.Unlock()
+
+	labels := []string{"object", "attribute"}
+	minDesc := prometheus.NewDesc("klipper_sampled_min", "Minimum of the signal sampled since the previous scrape.", labels, nil)
+	maxDesc := prometheus.NewDesc("klipper_sampled_max", "Maximum of the signal sampled since the previous scrape.", labels, nil)
+	avgDesc := prometheus.NewDesc("klipper_sampled_avg", "Average of the signal sampled since the previous scrape.", labels, nil)
+	samplesDesc := prometheus.NewDesc("klipper_sampled_samples", "Number of samples of the signal since the previous scrape.", labels, nil)
+	for signal, window := range sampler.read(c.opts.Scraper) {
+		sendConstMetric(ch, samplesDesc, prometheus.GaugeValue, float64(window.count), signal.Object, signal.Attribute)
+		if window.observed {
+			sendConstMetric(ch, minDesc, prometheus.GaugeValue, window.min, signal.Object, signal.Attribute)
+			sendConstMetric(ch, maxDesc, prometheus.GaugeValue, window.max, signal.Object, signal.Attribute)
+		}
+		if window.count > 0 {
+			sendConstMetric(ch, avgDesc, prometheus.GaugeValue, window.sum/float64(window.count), signal.Object, signal.Attribute)
+		}
+	}
+}
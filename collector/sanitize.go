@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// ValueRange is the range of plausible values of a metric. Values outside of
+// the range are clamped to it if Clamp is set, or else dropped.
+type ValueRange struct {
+	Min   float64
+	Max   float64
+	Clamp bool
+}
+
+// DefaultValueRanges are the ranges of the metrics Moonraker is known to
+// occasionally report bogus values for, e.g. a negative progress after a
+// print is cancelled, or a bandwidth spike when a network counter wraps.
+var DefaultValueRanges = map[string]ValueRange{
+	"klipper_print_file_progress":  {Min: 0, Max: 1, Clamp: true},
+	"klipper_print_gcode_progress": {Min: 0, Max: 1, Clamp: true},
+	"klipper_fan_speed":            {Min: 0, Max: 1, Clamp: true},
+	"klipper_extruder_power":       {Min: 0, Max: 1, Clamp: true},
+	"klipper_heater_bed_power":     {Min: 0, Max: 1, Clamp: true},
+	"klipper_print_filament_used":  {Min: 0, Max: math.Inf(1)},
+	"klipper_print_total_duration": {Min: 0, Max: math.Inf(1)},
+	"klipper_printing_time":        {Min: 0, Max: math.Inf(1)},
+	"klipper_fan_rpm":              {Min: 0, Max: math.Inf(1)},
+	// 10 Gbit/s, faster than the network interface of any printer host
+	"klipper_network_bandwidth": {Min: 0, Max: 1.25e9},
+}
+
+// ParseValueRanges parses `metric=min:max` value range rules, with a `:clamp`
+// suffix to clamp instead of drop the values that are out of range, added to
+// the default ranges. Either of the bounds can be left empty to leave the
+// range unbounded, and an empty range, `metric=`, removes the default range of
+// the metric.
+func ParseValueRanges(rules []string) (map[string]ValueRange, error) {
+	ranges := make(map[string]ValueRange, len(DefaultValueRanges))
+	for name, r := range DefaultValueRanges {
+		ranges[name] = r
+	}
+	for _, rule := range rules {
+		name, value, ok := strings.Cut(rule, "=")
+		if !ok || !model.IsValidMetricName(model.LabelValue(name)) {
+			return nil, fmt.Errorf("invalid value range '%s', must be metric=min:max", rule)
+		}
+		if value == "" {
+			delete(ranges, name)
+			continue
+		}
+		bounds := strings.Split(value, ":")
+		if len(bounds) == 3 && bounds[2] == "clamp" {
+			bounds = bounds[:2]
+			ranges[name] = ValueRange{Clamp: true}
+		} else {
+			ranges[name] = ValueRange{}
+		}
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid value range '%s', must be metric=min:max", rule)
+		}
+		r := ranges[name]
+		var err error
+		r.Min, r.Max = math.Inf(-1), math.Inf(1)
+		if bounds[0] != "" {
+			if r.Min, err = strconv.ParseFloat(bounds[0], 64); err != nil {
+				return nil, fmt.Errorf("invalid value range '%s': %v", rule, err)
+			}
+		}
+		if bounds[1] != "" {
+			if r.Max, err = strconv.ParseFloat(bounds[1], 64); err != nil {
+				return nil, fmt.Errorf("invalid value range '%s': %v", rule, err)
+			}
+		}
+		if r.Min > r.Max {
+			return nil, fmt.Errorf("invalid value range '%s', min must not be greater than max", rule)
+		}
+		ranges[name] = r
+	}
+	return ranges, nil
+}
+
+// valuesSanitizedTotal counts the values that were out of their plausible
+// range. Reported from the exporter's own `/metrics` endpoint.
+var valuesSanitizedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_values_sanitized_total",
+		Help: "Number of values outside of the plausible range of the metric that were clamped or dropped.",
+	},
+	[]string{"target", "metric", "action"},
+)
+
+// clampedMetric replaces the value of a gauge, counter, or untyped metric.
+type clampedMetric struct {
+	prometheus.Metric
+	value float64
+}
+
+func (m clampedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	switch {
+	case out.Gauge != nil:
+		out.Gauge.Value = &m.value
+	case out.Counter != nil:
+		out.Counter.Value = &m.value
+	case out.Untyped != nil:
+		out.Untyped.Value = &m.value
+	}
+	return nil
+}
+
+// metricName returns the name of the metric from its description.
+func metricName(desc *prometheus.Desc) string {
+	name := strings.TrimPrefix(desc.String(), `Desc{fqName: "`)
+	if i := strings.Index(name, `"`); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// metricValue returns the value of a gauge, counter, or untyped metric.
+func metricValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+// sanitizeValues forwards the metrics to ch, clamping or dropping the values
+// that are outside of the range of the metric. The returned channel must be
+// closed once collection is complete, and done is closed after the last
+// metric has been forwarded.
+func (c Collector) sanitizeValues(ch chan<- prometheus.Metric, ranges map[string]ValueRange) (sanitized chan prometheus.Metric, done chan struct{}) {
+	sanitized = make(chan prometheus.Metric)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range sanitized {
+			name := metricName(m.Desc())
+			r, ok := ranges[name]
+			if !ok {
+				ch <- m
+				continue
+			}
+			metric := &dto.Metric{}
+			if err := m.Write(metric); err != nil {
+				ch <- m
+				continue
+			}
+			value, ok := metricValue(metric)
+			if !ok || (value >= r.Min && value <= r.Max) || math.IsNaN(value) {
+				ch <- m
+				continue
+			}
+			if !r.Clamp {
+				log.Debugf("Dropped %s value %g of %s, outside of the range %g to %g", name, value, c.target, r.Min, r.Max)
+				valuesSanitizedTotal.WithLabelValues(c.target, name, "dropped").Inc()
+				continue
+			}
+			log.Debugf("Clamped %s value %g of %s to the range %g to %g", name, value, c.target, r.Min, r.Max)
+			valuesSanitizedTotal.WithLabelValues(c.target, name, "clamped").Inc()
+			ch <- clampedMetric{Metric: m, value: math.Max(r.Min, math.Min(r.Max, value))}
+		}
+	}()
+	return sanitized, done
+}
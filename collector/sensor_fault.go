@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"math"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Temperatures outside of this range are not physically plausible for a
+// printer and are the readings reported for an open or shorted thermistor,
+// where the ADC value is out of range.
+const (
+	minValidTemperature = -100
+	maxValidTemperature = 1000
+)
+
+// temperatureFault returns true if the temperature reading is NaN, exactly 0,
+// or out of the valid range, indicating a sensor or wiring fault rather than a
+// real temperature.
+func temperatureFault(temperature float64) bool {
+	return math.IsNaN(temperature) || math.IsInf(temperature, 0) || temperature == 0 ||
+		temperature < minValidTemperature || temperature > maxValidTemperature
+}
+
+// temperatureReadings returns the temperature of each of the heaters and
+// sensors keyed by sensor name.
+func temperatureReadings(status moonraker.PrinterObjectStatus) map[string]float64 {
+	temperatures := map[string]float64{
+		"extruder":   status.Extruder.Temperature,
+		"heater_bed": status.HeaterBed.Temperature,
+	}
+	if status.ZThermalAdjust != nil {
+		temperatures["z_thermal_adjust"] = status.ZThermalAdjust.Temperature
+	}
+	for name, sensor := range status.TemperatureSensors {
+		temperatures[getValidLabelName(name)] = sensor.Temperature
+	}
+	for name, fan := range status.TemperatureFans {
+		temperatures[getValidLabelName(name)] = fan.Temperature
+	}
+	return temperatures
+}
+
+// collectSensorFaults exports a fault gauge for each temperature reading of the
+// heaters and sensors. The temperature metrics of faulty sensors are omitted.
+func (c Collector) collectSensorFaults(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	faultDesc := prometheus.NewDesc("klipper_temperature_fault", "Set to 1 if the temperature reading of the sensor is NaN, 0, or out of range, indicating a sensor or wiring fault.", []string{"sensor"}, nil)
+	for name, temperature := range temperatureReadings(status) {
+		sendConstMetric(ch,
+			faultDesc,
+			prometheus.GaugeValue,
+			boolToFloat64(temperatureFault(temperature)),
+			name)
+	}
+}
+
+// nonFiniteNumber matches the NaN and Infinity values that Python's json
+// module writes for non finite floats, which are not valid JSON.
+var nonFiniteNumber = regexp.MustCompile(`([:\[,]\s*)-?(NaN|Infinity)(\s*[,\]}])`)
+
+// replaceNonFiniteNumbers replaces NaN and Infinity values in data with null so
+// the response can be decoded. A null reading decodes as 0, which is reported
+// as a temperature fault.
+func replaceNonFiniteNumbers(data []byte) []byte {
+	for nonFiniteNumber.Match(data) {
+		data = nonFiniteNumber.ReplaceAll(data, []byte("${1}null${3}"))
+	}
+	return data
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// seriesDroppedTotal counts the series that were not exposed for a target
+// because the MaxSeries limit was reached. Reported from the exporter's own
+// `/metrics` endpoint.
+var seriesDroppedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_series_dropped_total",
+		Help: "Number of series dropped because the per target series limit was exceeded.",
+	},
+	[]string{"target"},
+)
+
+// limitSeries forwards at most limit metrics to ch, counting and discarding any
+// remaining metrics. The returned channel must be closed once collection is
+// complete, and done is closed after the last metric has been forwarded.
+//
+// The modules are collected concurrently, so the metrics are buffered until
+// the collection is complete and the series to keep are chosen independent of
+// the order they arrived in. The metric families with the fewest series are
+// kept first, so the families of dynamically discovered objects such as
+// sensors and macros are dropped before the fixed metrics, and the series of a
+// family are kept in label order. The same series are kept on every scrape as
+// long as the printer's objects do not change.
+func (c Collector) limitSeries(ch chan<- prometheus.Metric, limit int) (limited chan prometheus.Metric, done chan struct{}) {
+	limited = make(chan prometheus.Metric)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		families := make(map[string][]limitedSeries)
+		count := 0
+		for m := range limited {
+			count++
+			name := metricName(m.Desc())
+			families[name] = append(families[name], limitedSeries{metric: m, labels: seriesLabels(m)})
+		}
+
+		names := make([]string, 0, len(families))
+		for name := range families {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if len(families[names[i]]) != len(families[names[j]]) {
+				return len(families[names[i]]) < len(families[names[j]])
+			}
+			return names[i] < names[j]
+		})
+		sent := 0
+		for _, name := range names {
+			series := families[name]
+			sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+			for _, s := range series {
+				if sent == limit {
+					break
+				}
+				ch <- s.metric
+				sent++
+			}
+		}
+
+		if dropped := count - sent; dropped > 0 {
+			log.Warnf("Dropped %d of %d series for %s, exceeds the limit of %d series", dropped, count, c.target, limit)
+			seriesDroppedTotal.WithLabelValues(c.target).Add(float64(dropped))
+		}
+	}()
+	return limited, done
+}
+
+// limitedSeries is a metric buffered by limitSeries.
+type limitedSeries struct {
+	metric prometheus.Metric
+	labels string
+}
+
+// seriesLabels returns the label pairs of the metric, sorted by label name, to
+// order the series of a metric family by.
+func seriesLabels(m prometheus.Metric) string {
+	metric := &dto.Metric{}
+	if err := m.Write(metric); err != nil {
+		return ""
+	}
+	var labels strings.Builder
+	for _, label := range metric.Label {
+		labels.WriteString(label.GetName())
+		labels.WriteString("=")
+		labels.WriteString(strconv.Quote(label.GetValue()))
+		labels.WriteString(",")
+	}
+	return labels.String()
+}
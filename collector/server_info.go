@@ -0,0 +1,11 @@
+package collector
+
+// https://moonraker.readthedocs.io/en/latest/web_api/#query-server-info
+
+import "github.com/scross01/prometheus-klipper-exporter/moonraker"
+
+// ServerInfo queries the Moonraker server information for the target, used to
+// check that the target is reachable.
+func (c Collector) ServerInfo() (*moonraker.ServerInfoResponse, error) {
+	return c.api("server_info").ServerInfo()
+}
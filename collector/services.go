@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectServices exports the systemd state of the services Moonraker manages,
+// e.g. klipper, moonraker, crowsnest, and KlipperScreen, to alert when a
+// service stops or crashes. Only the services listed in the Moonraker
+// `moonraker.asvc` file are reported.
+func (c Collector) collectServices(ch chan<- prometheus.Metric, services map[string]moonraker.ServiceState) {
+	activeDesc := prometheus.NewDesc("klipper_service_active", "Set to 1 if the systemd active state of the service is active.", []string{"service"}, nil)
+	subStateDesc := prometheus.NewDesc("klipper_service_sub_state", "The systemd active and sub state of the service, e.g. active and running.", []string{"service", "active_state", "sub_state"}, nil)
+	for name, service := range services {
+		sendConstMetric(ch, activeDesc, prometheus.GaugeValue, boolToFloat64(service.ActiveState == "active"), name)
+		sendConstMetric(ch, subStateDesc, prometheus.GaugeValue, 1, name, service.ActiveState, service.SubState)
+	}
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// servoSignalPeriod is the period of the Klipper servo PWM signal in seconds.
+// The `servo` object reports the duty cycle of the last commanded pulse.
+const servoSignalPeriod = 0.020
+
+// collectServos exports the last commanded pulse width and angle of each
+// servo, e.g. for servo driven probes, nozzle wipers, and tool changers. The
+// angle is calculated from the pulse width range and maximum angle configured
+// in printer.cfg, and is not reported while the servo signal is off.
+func (c Collector) collectServos(ch chan<- prometheus.Metric, module string, servos map[string]moonraker.PrinterObjectServo) {
+	if len(servos) == 0 {
+		return
+	}
+	config := c.printerConfig(module)
+	servoLabels := []string{"servo"}
+	for name, servo := range servos {
+		servoName := getValidLabelName(name)
+		width := servo.Value * servoSignalPeriod
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_servo_pulse_width_seconds", "The last commanded pulse width of the servo, 0 when the servo signal is off.", servoLabels, nil),
+			prometheus.GaugeValue,
+			width,
+			servoName)
+
+		settings := config["servo "+strings.ToLower(name)]
+		minWidth := settingFloat64(settings["minimum_pulse_width"], 0.001)
+		maxWidth := settingFloat64(settings["maximum_pulse_width"], 0.002)
+		maxAngle := settingFloat64(settings["maximum_servo_angle"], 180)
+		if width == 0 || maxWidth <= minWidth {
+			continue
+		}
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_servo_angle_degrees", "The last commanded angle of the servo.", servoLabels, nil),
+			prometheus.GaugeValue,
+			(width-minWidth)/(maxWidth-minWidth)*maxAngle,
+			servoName)
+	}
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// coalescedRequestsTotal counts the requests that were not sent to Moonraker
+// because the same request was already in progress for another scrape.
+var coalescedRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "klipper_exporter_moonraker_coalesced_requests_total",
+		Help: "Number of Moonraker requests shared with a concurrent scrape of the target instead of being sent again.",
+	},
+	[]string{"target", "module"},
+)
+
+// moonrakerResponse is the response to a Moonraker request, read in full so
+// it can be shared by concurrent scrapes.
+type moonrakerResponse struct {
+	url        string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// inflightRequest is a Moonraker request in progress. Scrapes that make the
+// same request while it is in progress wait for and share its response.
+type inflightRequest struct {
+	done chan struct{}
+	res  *moonrakerResponse
+	err  error
+}
+
+var inflightRequests = struct {
+	sync.Mutex
+	requests map[string]*inflightRequest
+}{requests: make(map[string]*inflightRequest)}
+
+// coalesce calls request unless a request with the same key is already in
+// progress, in which case it waits for and returns the response of the first
+// request, e.g. when several Prometheus servers scrape the same target at the
+// same time. shared is set if the response of another request was returned.
+// The request is made with the context of the first scrape, so all waiting
+// scrapes fail if the first scrape is cancelled, and fetch makes the request
+// again for the scrapes that were not cancelled.
+func coalesce(key string, request func() (*moonrakerResponse, error)) (res *moonrakerResponse, shared bool, err error) {
+	inflightRequests.Lock()
+	if r, ok := inflightRequests.requests[key]; ok {
+		inflightRequests.Unlock()
+		<-r.done
+		return r.res, true, r.err
+	}
+	r := &inflightRequest{done: make(chan struct{})}
+	inflightRequests.requests[key] = r
+	inflightRequests.Unlock()
+
+	defer func() {
+		inflightRequests.Lock()
+		delete(inflightRequests.requests, key)
+		inflightRequests.Unlock()
+		close(r.done)
+	}()
+	r.res, r.err = request()
+	return r.res, false, r.err
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// collectSmoothedTemperatures exports an exponential moving average of the
+// temperature of the selected sensors alongside the raw temperature, to reduce
+// the noise of analog thermistors before it reaches alert rules. The smoothing
+// weight of each sample depends on the time since the previous scrape, so the
+// response does not change with the scrape interval. Faulty readings are not
+// included in the average.
+func (c Collector) collectSmoothedTemperatures(ch chan<- prometheus.Metric, status moonraker.PrinterObjectStatus) {
+	if len(c.opts.SmoothedSensors) == 0 {
+		return
+	}
+
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	weight := 1.0
+	if !state.smoothedLastTime.IsZero() && c.opts.SmoothingTimeConstant > 0 {
+		weight = 1 - math.Exp(-now.Sub(state.smoothedLastTime).Seconds()/c.opts.SmoothingTimeConstant.Seconds())
+	}
+	state.smoothedLastTime = now
+
+	smoothedDesc := prometheus.NewDesc("klipper_temperature_smoothed", "Exponentially smoothed temperature of the sensor.", []string{"sensor"}, nil)
+	for name, temperature := range temperatureReadings(status) {
+		if !slices.Contains(c.opts.SmoothedSensors, name) || temperatureFault(temperature) {
+			continue
+		}
+		smoothed, ok := state.smoothedTemperatures[name]
+		if ok {
+			smoothed += weight * (temperature - smoothed)
+		} else {
+			smoothed = temperature
+		}
+		state.smoothedTemperatures[name] = smoothed
+		sendConstMetric(ch,
+			smoothedDesc,
+			prometheus.GaugeValue,
+			smoothed,
+			name)
+	}
+}
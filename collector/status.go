@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"sort"
+	"time"
+)
+
+// TargetStatus summarizes the recent collections of a target for the status
+// page.
+type TargetStatus struct {
+	Target string
+	// LastScrape is the time the last collection of the target started.
+	LastScrape time.Time
+	// LastSuccess is the time of the last successful request to the target.
+	LastSuccess time.Time
+	// DisabledModules are the modules that have been automatically disabled.
+	DisabledModules []string
+	// RateLimited is set while requests to the target are paused after a
+	// HTTP 429 response.
+	RateLimited bool
+}
+
+// Succeeded returns true if a request to the target succeeded during the last
+// collection.
+func (s TargetStatus) Succeeded() bool {
+	return !s.LastSuccess.IsZero() && !s.LastSuccess.Before(s.LastScrape)
+}
+
+// recordScrape records the start of a collection of the target.
+func (c Collector) recordScrape() {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.lastScrape = time.Now()
+}
+
+// TargetStatuses returns the status of each target that has been collected
+// within the target state idle timeout, sorted by target.
+func TargetStatuses() []TargetStatus {
+	targetStatesMutex.Lock()
+	targets := make([]string, 0, len(targetStates))
+	states := make(map[string]*targetState, len(targetStates))
+	for target, state := range targetStates {
+		targets = append(targets, target)
+		states[target] = state
+	}
+	targetStatesMutex.Unlock()
+	sort.Strings(targets)
+
+	statuses := []TargetStatus{}
+	for _, target := range targets {
+		state := states[target]
+		state.mu.Lock()
+		status := TargetStatus{
+			Target:      target,
+			LastScrape:  state.lastScrape,
+			RateLimited: time.Now().Before(state.rateLimitedUntil),
+		}
+		for _, lastSuccess := range state.lastSuccess {
+			if lastSuccess.After(status.LastSuccess) {
+				status.LastSuccess = lastSuccess
+			}
+		}
+		for module := range state.disabled {
+			status.DisabledModules = append(status.DisabledModules, module)
+		}
+		state.mu.Unlock()
+		sort.Strings(status.DisabledModules)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -3,56 +3,53 @@ package collector
 // https://moonraker.readthedocs.io/en/latest/web_api/#get-system-info
 
 import (
-	"encoding/json"
-	log "github.com/sirupsen/logrus"
-	"io"
-	"net/http"
-)
+	"strconv"
+	"strings"
 
-type MoonrakerSystemInfoQueryResponse struct {
-	Result struct {
-		SystemInfo struct {
-			CpuInfo struct {
-				CpuCount    int    `json:"cpu_count"`
-				TotalMemory int    `json:"total_memory"`
-				MemoryUnits string `json:"memory_units"`
-			} `json:"cpu_info"`
-		} `json:"system_info"`
-	} `json:"result"`
-}
+	"github.com/prometheus/client_golang/prometheus"
 
-func (c Collector) fetchMoonrakerSystemInfo(klipperHost string, apiKey string) (*MoonrakerSystemInfoQueryResponse, error) {
-	var url = "http://" + klipperHost + "/machine/system_info"
-	log.Debug("Collecting metrics from " + url)
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	if apiKey != "" {
-		req.Header.Set("X-API-KEY", apiKey)
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// collectSystemDetails exports the host operating system distribution, Python
+// version, CPU, and virtualization as info metrics, e.g. for an inventory of
+// the printer hosts.
+func (c Collector) collectSystemDetails(ch chan<- prometheus.Metric, result *moonraker.SystemInfoQueryResponse) {
+	info := result.Result.SystemInfo
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_system_distribution_info", "The operating system distribution and kernel version of the host.", []string{"name", "id", "version", "codename", "kernel_version"}, nil),
+		prometheus.GaugeValue,
+		1,
+		info.Distribution.Name, info.Distribution.ID, info.Distribution.Version, info.Distribution.Codename, info.Distribution.KernelVersion)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_system_cpu_info", "The CPU and hardware model of the host.", []string{"processor", "cpu_desc", "hardware_desc", "model", "bits"}, nil),
+		prometheus.GaugeValue,
+		1,
+		info.CpuInfo.Processor, info.CpuInfo.CpuDesc, info.CpuInfo.HardwareDesc, info.CpuInfo.Model, info.CpuInfo.Bits)
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_system_virtualization_info", "The virtualization the host runs in, e.g. none, docker, or kvm.", []string{"virt_type", "virt_identifier"}, nil),
+		prometheus.GaugeValue,
+		1,
+		info.Virtualization.VirtType, info.Virtualization.VirtIdentifier)
+	if version := pythonVersion(info.Python.Version); version != "" {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_system_python_info", "The version of Python Moonraker runs with.", []string{"version"}, nil),
+			prometheus.GaugeValue,
+			1,
+			version)
 	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	var response MoonrakerSystemInfoQueryResponse
+}
 
-	err = json.Unmarshal(data, &response)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// pythonVersion returns the major, minor, and micro version from the Python
+// version info, e.g. `3.9.2` for `[3, 9, 2, "final", 0]`.
+func pythonVersion(versionInfo []interface{}) string {
+	parts := []string{}
+	for _, part := range versionInfo {
+		number, ok := part.(float64)
+		if !ok || len(parts) == 3 {
+			break
+		}
+		parts = append(parts, strconv.Itoa(int(number)))
 	}
-
-	return &response, nil
+	return strings.Join(parts, ".")
 }
@@ -0,0 +1,341 @@
+package collector
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// targetState holds information about a Klipper target that is carried over
+// between scrapes.
+type targetState struct {
+	mu sync.Mutex
+	// time the state was last used, guarded by targetStatesMutex
+	lastUsed time.Time
+	// time the last collection of the target started
+	lastScrape time.Time
+	// number of consecutive HTTP 404 responses per module
+	notFound map[string]int
+	// time each module was automatically disabled
+	disabled map[string]time.Time
+	// time of the last successful request for each module
+	lastSuccess map[string]time.Time
+	// requests are skipped until this time after a HTTP 429 response
+	rateLimitedUntil time.Time
+	// time the heater bed came within the heat soak tolerance of its target
+	bedSoakStart time.Time
+	// print_stats filament used at the previous scrape
+	lastFilamentUsed     float64
+	lastFilamentUsedTime time.Time
+	// virtual_sdcard file position at the previous scrape
+	lastFilePosition     float64
+	lastFilePositionTime time.Time
+	// rolling window of extrusion samples per filament motion sensor
+	filamentMotion map[string][]filamentMotionSample
+	// time of the newest gcode store entry seen, the time of the previous
+	// gcode store scrape, and the command and macro execution counts
+	gcodeStoreLastTime   float64
+	gcodeStoreLastScrape time.Time
+	gcodeCommands        int
+	macroExecutions      map[string]int
+	// klippy state at the previous scrape, the number of emergency stops, and
+	// the unix time of the last emergency stop
+	lastKlippyState   string
+	emergencyStops    int
+	lastEmergencyStop float64
+	// print_stats state at the previous scrape
+	lastPrintState string
+	// background sampler of the fast changing signals
+	sampler *signalSampler
+	// the time each door switch has been open during the current print
+	doorOpen        map[string]bool
+	doorOpenSeconds map[string]float64
+	doorLastTime    time.Time
+	// number of pauses and resumes during the current print, and the reason
+	// and sensor of the last pause
+	printPauses     int
+	printResumes    int
+	lastPauseReason string
+	lastPauseSensor string
+	// highest live velocity, extruder velocity, and acceleration limit seen
+	// while printing during the current print
+	printMaxVelocity         float64
+	printMaxExtruderVelocity float64
+	printMaxAccel            float64
+	// speed and extrude factors at the previous scrape, and the number of
+	// times each has changed during the current print
+	factorsSeen          bool
+	lastSpeedFactor      float64
+	lastExtrudeFactor    float64
+	speedFactorChanges   int
+	extrudeFactorChanges int
+	// whether the heaters were heating at the previous scrape, and the time
+	// spent heating in total and during the current print
+	heatingActive       bool
+	heatingLastTime     time.Time
+	heatingSeconds      float64
+	printHeatingSeconds float64
+	// Moonraker temperature store size, fetched once
+	temperatureStoreSize int
+	// printer.cfg settings and Klippy host information, fetched again after
+	// Klippy is restarted, and the mcu send sequence at the previous scrape
+	printerConfig map[string]map[string]interface{}
+	printerInfo   *moonraker.PrinterInfoResponse
+	mcuSendSeq    float64
+	// end time of the last completed job in the print history
+	lastSuccessTime float64
+	// printer name from the web client settings, fetched once
+	printerName        string
+	printerNameFetched bool
+	// toolhead position at the previous scrape, and the estimated travel of
+	// each axis
+	lastToolheadPosition []float64
+	axisTravel           map[string]float64
+	// printer state at the previous scrape used for maintenance tracking
+	maintenanceSample *maintenanceSample
+	// filament used at the previous scrape for the per material totals
+	filamentMaterialSample *filamentMaterialSample
+	// set if the target does not support JSON-RPC over HTTP
+	jsonRPCUnsupported bool
+	// cached responses of the modules with a cache TTL, keyed by request
+	responses map[string]cachedResponse
+	// slicer metadata of the file loaded for printing
+	fileMetadata *moonraker.FileMetadataResponse
+	// metadata of the queued job files keyed by filename
+	queueMetadata map[string]*moonraker.FileMetadataResponse
+	// time of the last failed metadata lookup keyed by filename
+	fileMetadataFailures map[string]time.Time
+	// probed matrix of the bed mesh at the previous scrape, and the unix time
+	// the mesh was last calibrated
+	bedMeshMatrix     string
+	bedMeshCalibrated float64
+	// exponentially smoothed temperature of each smoothed sensor, and the time
+	// of the previous sample
+	smoothedTemperatures map[string]float64
+	smoothedLastTime     time.Time
+	// learned RPM per PWM ratio of each fan, and the time of the previous sample
+	fanRpmRatio    map[string]float64
+	fanRpmLastTime time.Time
+}
+
+// targetStateIdleTimeout is how long the state of a target is kept after it was
+// last used. Targets are set by the `target` probe parameter, so the state of
+// targets that are no longer collected is evicted instead of growing the
+// exporter's memory until it is restarted.
+const targetStateIdleTimeout = time.Hour
+
+var (
+	targetStatesMutex sync.Mutex
+	targetStates      = make(map[string]*targetState)
+	// time the idle target states were last evicted
+	targetStatesEvicted time.Time
+)
+
+// targetMetrics are the exporter's own metrics with a `target` label, the
+// series of a target are deleted when its state is evicted.
+var targetMetrics = []*prometheus.CounterVec{
+	seriesDroppedTotal,
+	coalescedRequestsTotal,
+	responseBytesTotal,
+	cacheHitsTotal,
+	valuesSanitizedTotal,
+	rateLimitedTotal,
+	jsonRPCBatchesTotal,
+	jsonRPCBatchedRequestsTotal,
+}
+
+// getTargetState returns the state for the klipperHost, creating it on first use.
+func getTargetState(klipperHost string) *targetState {
+	targetStatesMutex.Lock()
+	defer targetStatesMutex.Unlock()
+	now := time.Now()
+	if now.Sub(targetStatesEvicted) > time.Minute {
+		evictIdleTargetStates(now)
+	}
+	state, ok := targetStates[klipperHost]
+	if !ok {
+		state = &targetState{
+			notFound:             make(map[string]int),
+			disabled:             make(map[string]time.Time),
+			lastSuccess:          make(map[string]time.Time),
+			filamentMotion:       make(map[string][]filamentMotionSample),
+			macroExecutions:      make(map[string]int),
+			doorOpen:             make(map[string]bool),
+			doorOpenSeconds:      make(map[string]float64),
+			axisTravel:           make(map[string]float64),
+			smoothedTemperatures: make(map[string]float64),
+			fanRpmRatio:          make(map[string]float64),
+		}
+		targetStates[klipperHost] = state
+	}
+	state.lastUsed = now
+	return state
+}
+
+// evictIdleTargetStates removes the state and the exporter metrics of the
+// targets that have not been used for targetStateIdleTimeout. Must be called
+// with targetStatesMutex held.
+func evictIdleTargetStates(now time.Time) {
+	targetStatesEvicted = now
+	for target, state := range targetStates {
+		if now.Sub(state.lastUsed) < targetStateIdleTimeout {
+			continue
+		}
+		log.Infof("Evicting the state of %s, not collected for %s", target, targetStateIdleTimeout)
+		delete(targetStates, target)
+		for _, metric := range targetMetrics {
+			metric.DeletePartialMatch(prometheus.Labels{"target": target})
+		}
+	}
+}
+
+// enabled returns true if the module was requested and has not been
+// automatically disabled for the target.
+func (c Collector) enabled(module string) bool {
+	return slices.Contains(c.modules, module) && !c.moduleDisabled(moduleStateKey(module))
+}
+
+// moduleDisabled returns true if the module has been automatically disabled for
+// the target because the Moonraker endpoint it uses is not available. Disabled
+// modules are retried once the AutoDisableRetry interval has passed.
+func (c Collector) moduleDisabled(module string) bool {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	disabledAt, ok := state.disabled[module]
+	if !ok {
+		return false
+	}
+	if c.opts.AutoDisableRetry > 0 && time.Since(disabledAt) > c.opts.AutoDisableRetry {
+		log.Infof("Retrying automatically disabled module %s for %s", module, c.target)
+		delete(state.disabled, module)
+		state.notFound[module] = c.opts.AutoDisableAfter - 1
+		return false
+	}
+	return true
+}
+
+// recordModuleStatus tracks the HTTP status returned for a module's request,
+// recording the time of successful requests, and disables the module for the
+// target after AutoDisableAfter consecutive 404s.
+func (c Collector) recordModuleStatus(module string, statusCode int) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if statusCode >= 200 && statusCode <= 299 {
+		state.lastSuccess[module] = time.Now()
+	}
+	if statusCode != http.StatusNotFound {
+		state.notFound[module] = 0
+		return
+	}
+	state.notFound[module]++
+	if c.opts.AutoDisableAfter > 0 && state.notFound[module] >= c.opts.AutoDisableAfter {
+		log.Warnf("Disabling module %s for %s after %d not found responses", module, c.target, state.notFound[module])
+		state.disabled[module] = time.Now()
+	}
+}
+
+// moduleStateKey returns the module name the state of the module is recorded
+// under. `network_stats` shares the process stats request with `process_stats`.
+func moduleStateKey(module string) string {
+	if module == "network_stats" {
+		return "process_stats"
+	}
+	return module
+}
+
+// moduleResults records the modules with a failed request or a panic, and the
+// time spent on the requests of each module, during a collection.
+type moduleResults struct {
+	mu        sync.Mutex
+	failed    map[string]bool
+	durations map[string]time.Duration
+}
+
+func (r *moduleResults) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = make(map[string]bool)
+	r.durations = make(map[string]time.Duration)
+}
+
+func (r *moduleResults) fail(module string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed == nil {
+		r.failed = make(map[string]bool)
+	}
+	r.failed[moduleStateKey(module)] = true
+}
+
+func (r *moduleResults) addDuration(module string, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.durations == nil {
+		r.durations = make(map[string]time.Duration)
+	}
+	r.durations[moduleStateKey(module)] += duration
+}
+
+func (r *moduleResults) succeeded(module string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.failed[moduleStateKey(module)]
+}
+
+func (r *moduleResults) duration(module string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.durations[moduleStateKey(module)]
+}
+
+// collectModuleStatus reports whether each of the requested modules succeeded
+// in this collection and the time its requests took, the time each last
+// succeeded, and which have been automatically disabled for the target.
+func (c Collector) collectModuleStatus(ch chan<- prometheus.Metric) {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	moduleLabels := []string{"module"}
+	moduleLastSuccess := prometheus.NewDesc("klipper_module_last_success_timestamp_seconds", "Unix timestamp of the last successful collection of the module.", moduleLabels, nil)
+	moduleDisabled := prometheus.NewDesc("klipper_module_disabled", "Set to 1 if the module has been automatically disabled because the target does not support it.", moduleLabels, nil)
+	moduleSuccess := prometheus.NewDesc("klipper_module_scrape_success", "Set to 1 if all of the requests of the module succeeded in this collection.", moduleLabels, nil)
+	moduleDuration := prometheus.NewDesc("klipper_module_scrape_duration_seconds", "Time in seconds the requests of the module took in this collection.", moduleLabels, nil)
+	for _, module := range c.modules {
+		// automatically disabled modules are not collected
+		if _, disabled := state.disabled[moduleStateKey(module)]; !disabled {
+			sendConstMetric(ch,
+				moduleSuccess,
+				prometheus.GaugeValue,
+				boolToFloat64(c.results.succeeded(module)),
+				module)
+			sendConstMetric(ch,
+				moduleDuration,
+				prometheus.GaugeValue,
+				c.results.duration(module).Seconds(),
+				module)
+		}
+		if lastSuccess, ok := state.lastSuccess[moduleStateKey(module)]; ok {
+			sendConstMetric(ch,
+				moduleLastSuccess,
+				prometheus.GaugeValue,
+				float64(lastSuccess.UnixNano())/1e9,
+				module)
+		}
+		if c.opts.AutoDisableAfter > 0 {
+			_, disabled := state.disabled[moduleStateKey(module)]
+			sendConstMetric(ch,
+				moduleDisabled,
+				prometheus.GaugeValue,
+				boolToFloat64(disabled),
+				module)
+		}
+	}
+}
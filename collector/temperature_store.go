@@ -3,48 +3,133 @@ package collector
 // https://moonraker.readthedocs.io/en/latest/web_api/#request-cached-temperature-data
 
 import (
-	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
-	"io"
-	"net/http"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
 )
 
-type TemperatureDataQueryResponse struct {
-	Result map[string]interface{} `json:"result"`
-}
+// temperatureStoreInterval is the fixed interval Moonraker adds a sample to
+// the temperature store at.
+const temperatureStoreInterval = time.Second
 
-func (c Collector) fetchTemperatureData(klipperHost string, apiKey string) (*TemperatureDataQueryResponse, error) {
-	var url = "http://" + klipperHost + "/server/temperature_store"
-	log.Debug("Collecting metrics from " + url)
+// temperatureStoreFamilies maps the temperature store attributes to the
+// labeled metric families.
+var temperatureStoreFamilies = map[string]struct {
+	name string
+	help string
+}{
+	"temperatures": {"klipper_temperature_celsius", "The temperature of the heater or sensor in degrees celsius."},
+	"targets":      {"klipper_temperature_target_celsius", "The target temperature of the heater or temperature fan in degrees celsius."},
+	"powers":       {"klipper_temperature_power", "The power of the heater."},
+	"speeds":       {"klipper_temperature_speed", "The speed of the temperature fan."},
+}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// collectLabeledTemperature exports the latest temperature store values of all
+// of the heaters, temperature fans, and sensors as a single family per
+// attribute with a `sensor` label, so the sensors can be templated and
+// aggregated in queries. The type of custom objects is removed from the sensor
+// name, e.g. `temperature_sensor chamber` is reported as `chamber`.
+func (c Collector) collectLabeledTemperature(ch chan<- prometheus.Metric, result *moonraker.TemperatureDataQueryResponse) {
+	for k, v := range result.Result {
+		sensor := temperatureStoreSensor(k)
+		attributes, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for attribute, values := range attributes {
+			family, ok := temperatureStoreFamilies[attribute]
+			if !ok {
+				continue
+			}
+			samples, ok := values.([]interface{})
+			if !ok || len(samples) == 0 {
+				continue
+			}
+			value, ok := samples[len(samples)-1].(float64)
+			if !ok {
+				continue
+			}
+			sendConstMetric(ch,
+				prometheus.NewDesc(family.name, family.help, []string{"sensor"}, nil),
+				prometheus.GaugeValue,
+				value,
+				sensor)
+		}
 	}
-	if apiKey != "" {
-		req.Header.Set("X-API-KEY", apiKey)
+}
+
+// temperatureStoreSensor returns the sensor label of a temperature store
+// object with the type of custom objects removed, e.g. `chamber` for
+// `temperature_sensor chamber`.
+func temperatureStoreSensor(object string) string {
+	sensor := object
+	if i := strings.Index(object, " "); i >= 0 {
+		sensor = object[i+1:]
 	}
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+	return getValidLabelName(sensor)
+}
+
+// temperatureStoreSize returns the number of samples the temperature store
+// keeps for each sensor from the Moonraker configuration, or 0 if it could not
+// be fetched. The configuration only changes when Moonraker is restarted so
+// it is fetched once per target.
+func (c Collector) temperatureStoreSize() int {
+	state := getTargetState(c.target)
+	state.mu.Lock()
+	size := state.temperatureStoreSize
+	state.mu.Unlock()
+	if size > 0 {
+		return size
 	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
+
+	response, err := c.api("temperature").ServerConfig()
 	if err != nil {
 		log.Error(err)
-		return nil, err
+		return 0
 	}
+	size = response.Result.Config.DataStore.TemperatureStoreSize
 
-	var response TemperatureDataQueryResponse
+	state.mu.Lock()
+	state.temperatureStoreSize = size
+	state.mu.Unlock()
+	return size
+}
 
-	err = json.Unmarshal(data, &response)
-	if err != nil {
-		log.Error(err)
-		return nil, err
+// collectTemperatureStoreWindow exports the number of samples and the time
+// span the temperature store currently holds for each sensor, and the
+// configured size of the store, so metrics derived from the stored samples can
+// be interpreted, and a store that is too small for them can be detected.
+func (c Collector) collectTemperatureStoreWindow(ch chan<- prometheus.Metric, result *moonraker.TemperatureDataQueryResponse) {
+	samplesDesc := prometheus.NewDesc("klipper_temperature_store_samples", "The number of samples the temperature store holds for the sensor.", []string{"sensor"}, nil)
+	windowDesc := prometheus.NewDesc("klipper_temperature_store_window_seconds", "The time span in seconds of the samples the temperature store holds for the sensor.", []string{"sensor"}, nil)
+	for k, v := range result.Result {
+		attributes, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// all of the attributes of a sensor are sampled together
+		samples := 0
+		for _, values := range attributes {
+			if values, ok := values.([]interface{}); ok && len(values) > samples {
+				samples = len(values)
+			}
+		}
+		sensor := temperatureStoreSensor(k)
+		sendConstMetric(ch, samplesDesc, prometheus.GaugeValue, float64(samples), sensor)
+		sendConstMetric(ch, windowDesc, prometheus.GaugeValue, float64(samples)*temperatureStoreInterval.Seconds(), sensor)
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_temperature_store_sample_interval_seconds", "The interval in seconds at which samples are added to the temperature store.", nil, nil),
+		prometheus.GaugeValue,
+		temperatureStoreInterval.Seconds())
+	if size := c.temperatureStoreSize(); size > 0 {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_temperature_store_size", "The maximum number of samples the temperature store holds for each sensor, from the Moonraker data_store configuration.", nil, nil),
+			prometheus.GaugeValue,
+			float64(size))
 	}
-
-	return &response, nil
 }
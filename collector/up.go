@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectUp reports whether Moonraker is reachable and Klippy is connected to
+// Moonraker on every scrape, regardless of the modules, so an unreachable
+// printer can be alerted on without relying on absent(). An error response,
+// e.g. unauthorized or rate limited, is counted as reachable as Moonraker, or a
+// proxy in front of it, did respond, but the Klippy state is not known.
+func (c Collector) collectUp(ch chan<- prometheus.Metric) {
+	var moonrakerUp, klippyUp bool
+	info, err := c.api("up").ServerInfo()
+	var rateLimited *rateLimitedError
+	var statusError *moonrakerStatusError
+	if err == nil {
+		moonrakerUp = true
+		klippyUp = info.Result.KlippyConnected
+	} else if errors.As(err, &rateLimited) || errors.As(err, &statusError) {
+		moonrakerUp = true
+	}
+
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_moonraker_up", "Set to 1 if the Moonraker API of the target is reachable.", nil, nil),
+		prometheus.GaugeValue,
+		boolToFloat64(moonrakerUp))
+	if err == nil || !moonrakerUp {
+		sendConstMetric(ch,
+			prometheus.NewDesc("klipper_klippy_up", "Set to 1 if Klippy is connected to Moonraker.", nil, nil),
+			prometheus.GaugeValue,
+			boolToFloat64(klippyUp))
+	}
+	if err == nil {
+		c.collectKlippyState(ch, info.Result.KlippyState, klippyUp)
+	}
+}
+
+// klippyStates are the Klippy states reported by Moonraker.
+var klippyStates = []string{"ready", "startup", "shutdown", "error", "disconnected"}
+
+// collectKlippyState exports the Klippy state as an enum, the state message
+// explaining why the printer is not ready, e.g. the printer.cfg error, which is
+// only available while Klippy is connected, and the number of emergency stops.
+func (c Collector) collectKlippyState(ch chan<- prometheus.Metric, state string, connected bool) {
+	stateDesc := prometheus.NewDesc("klipper_klippy_state", "Set to 1 for the current state of Klippy.", []string{"state"}, nil)
+	for _, s := range klippyStates {
+		sendConstMetric(ch, stateDesc, prometheus.GaugeValue, boolToFloat64(s == state), s)
+	}
+	message := ""
+	if connected {
+		if info, err := c.api("up").PrinterInfo(); err == nil {
+			message = info.Result.StateMessage
+			sendConstMetric(ch,
+				prometheus.NewDesc("klipper_klippy_state_info", "The Klippy state and the message describing it.", []string{"state", "state_message"}, nil),
+				prometheus.GaugeValue,
+				1,
+				info.Result.State, strings.TrimSpace(info.Result.StateMessage))
+		}
+	}
+	sendConstMetric(ch,
+		prometheus.NewDesc("klipper_emergency_stop_total", "The number of emergency stops observed, from Klippy shutting down due to M112 or an emergency stop request.", nil, nil),
+		prometheus.CounterValue,
+		float64(c.recordKlippyState(state, message)))
+}
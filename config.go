@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print the effective configuration",
+	Long: `Print the effective value of each option and where it was set from, either
+the command line flag, the environment variable, or the default value.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		defer w.Flush()
+		fmt.Fprintln(w, "OPTION\tVALUE\tSOURCE\tENVIRONMENT")
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if f.Name == "help" || f.Deprecated != "" {
+				return
+			}
+			value := f.Value.String()
+			if f.Name == "moonraker.apikey" && value != "" {
+				value = "<secret>"
+			}
+			if u, err := url.Parse(value); f.Name == "events.url" && err == nil {
+				value = u.Redacted()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, value, flagSources[f.Name], envName(f.Name))
+		})
+		return nil
+	},
+}
+
+func init() {
+	addServeFlags(configCmd.Flags())
+	rootCmd.AddCommand(configCmd)
+}
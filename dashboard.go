@@ -0,0 +1,28 @@
+package main
+
+import (
+	_ "embed"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed example/grafana-dashboard.json
+var grafanaDashboard []byte
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Print the example Grafana dashboard JSON",
+	Long: `Print the example Grafana dashboard JSON, which can be imported into Grafana
+to visualize the collected metrics.`,
+	Example: "  prometheus-klipper-exporter dashboard > klipper-dashboard.json",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(grafanaDashboard)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var describeTarget string
+
+var describeCmd = &cobra.Command{
+	Use:   "describe [module...]",
+	Short: "Describe the available modules and the metrics they provide",
+	Long: `Describe the available modules. When a target is specified the metrics
+reported by each module for that target are listed, including the metrics for
+dynamically discovered objects such as temperature sensors.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		defer w.Flush()
+
+		if describeTarget == "" {
+			fmt.Fprintln(w, "MODULE\tDEFAULT\tDESCRIPTION")
+			for _, m := range collector.Modules {
+				if len(args) > 0 && !contains(args, m.Name) {
+					continue
+				}
+				isDefault := ""
+				if m.Default {
+					isDefault = "yes"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, isDefault, m.Description)
+			}
+			return nil
+		}
+
+		modules := args
+		if len(modules) == 0 {
+			for _, m := range collector.Modules {
+				modules = append(modules, m.Name)
+			}
+		}
+		fmt.Fprintln(w, "MODULE\tMETRIC\tTYPE\tHELP")
+		for _, module := range modules {
+			mfs, err := gather(cmd.Context(), describeTarget, []string{module})
+			if err != nil {
+				return err
+			}
+			for _, mf := range mfs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", module, mf.GetName(), mf.GetType(), mf.GetHelp())
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	describeCmd.Flags().StringVar(&describeTarget, "target", "", "Klipper host to describe the metrics for, e.g. klipper.local:7125")
+	rootCmd.AddCommand(describeCmd)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var (
+	diffTarget    string
+	diffModules   []string
+	diffOldBinary string
+	diffSnapshot  string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the series of a target with a previous exporter version",
+	Long: `Compare the series collected from a target with the series collected by a
+previous version of the exporter, either by running the previous binary, or
+from a snapshot saved with the collect command or from the /probe endpoint. A
+previous binary without the collect command, e.g. v0.10.2, is started as an
+exporter server on a free local port and its /probe endpoint is collected
+instead. Added, removed, and renamed series are reported so upgrades can be
+audited before they are rolled out. A removed series is reported as renamed
+when it has the same type and labels as exactly one added series, or when the
+added series name extends the removed name, e.g. with a unit suffix.
+
+Exits with a non zero status if there are any differences.`,
+	Example: `  prometheus-klipper-exporter diff --target klipper.local:7125 --old-binary ./prometheus-klipper-exporter-0.10.2
+  prometheus-klipper-exporter collect --target klipper.local:7125 > before.prom
+  prometheus-klipper-exporter diff --target klipper.local:7125 --snapshot before.prom`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (diffOldBinary == "") == (diffSnapshot == "") {
+			return errors.New("one of --old-binary or --snapshot must be set")
+		}
+		old, err := previousSeries()
+		if err != nil {
+			return err
+		}
+		mfs, err := gather(cmd.Context(), diffTarget, diffModules)
+		if err != nil {
+			return err
+		}
+		added, removed, renamed := diffSeries(old, familySeries(mfs))
+		for _, s := range removed {
+			fmt.Printf("- %s\n", s)
+		}
+		for _, s := range added {
+			fmt.Printf("+ %s\n", s)
+		}
+		for _, r := range renamed {
+			fmt.Printf("~ %s -> %s\n", r[0], r[1])
+		}
+		if len(added)+len(removed)+len(renamed) > 0 {
+			return fmt.Errorf("%d series added, %d removed, %d renamed", len(added), len(removed), len(renamed))
+		}
+		fmt.Println("No differences")
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffTarget, "target", "", "Klipper host to collect from, e.g. klipper.local:7125")
+	diffCmd.Flags().StringSliceVar(&diffModules, "modules", collector.DefaultModules(), "Modules to collect.")
+	diffCmd.Flags().StringVar(&diffOldBinary, "old-binary", "", "Previous exporter binary to collect the series to compare with.")
+	diffCmd.Flags().StringVar(&diffSnapshot, "snapshot", "", "File in the Prometheus text format with the series to compare with.")
+	diffCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(diffCmd)
+}
+
+// previousSeries returns the series from the snapshot file, or collected from
+// the target by the collect command of the previous binary. Releases of the
+// previous binary without the collect command are started as an exporter
+// server and the series are read from its /probe endpoint instead.
+func previousSeries() (map[string]series, error) {
+	var r io.Reader
+	if diffSnapshot != "" {
+		f, err := os.Open(diffSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	} else if hasCollectCommand(diffOldBinary) {
+		var stdout, stderr bytes.Buffer
+		args := []string{"collect", "--target", diffTarget, "--modules", strings.Join(diffModules, ",")}
+		if klipperApiKey != "" {
+			args = append(args, "--moonraker.apikey", klipperApiKey)
+		}
+		cmd := exec.Command(diffOldBinary, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("unable to collect with %s: %v%s", diffOldBinary, err, stderrOutput(&stderr))
+		}
+		r = &stdout
+	} else {
+		probe, err := probeWithBinary(diffOldBinary)
+		if err != nil {
+			return nil, err
+		}
+		r = probe
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series to compare with: %v", err)
+	}
+	mfs := []*dto.MetricFamily{}
+	for _, mf := range families {
+		// snapshots of the /probe endpoint also include the status metrics
+		if !isStatusMetric(mf.GetName()) {
+			mfs = append(mfs, mf)
+		}
+	}
+	return familySeries(mfs), nil
+}
+
+// hasCollectCommand reports whether the binary has the collect command, which
+// is listed in its help. Releases before the command line was split into
+// commands print the usage of their flags instead.
+func hasCollectCommand(binary string) bool {
+	out, _ := exec.Command(binary, "--help").CombinedOutput()
+	return strings.Contains(string(out), "\n  collect ")
+}
+
+// oldBinaryStartTimeout is the maximum time to wait for the previous binary
+// to start serving the /probe endpoint.
+const oldBinaryStartTimeout = 10 * time.Second
+
+// probeWithBinary starts the binary as an exporter server listening on a free
+// local port, and returns the response of its /probe endpoint for the target
+// and modules. The server is stopped before returning.
+func probeWithBinary(binary string) (io.Reader, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	// flags are passed with two dashes and `=`, which are accepted by both the
+	// flag package of the earlier releases and the current command line
+	args := []string{"--web.listen-address=" + address}
+	if klipperApiKey != "" {
+		args = append(args, "--moonraker.apikey="+klipperApiKey)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start %s: %v", binary, err)
+	}
+	exited := make(chan struct{})
+	var exitErr error
+	go func() {
+		exitErr = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		cmd.Process.Kill()
+		<-exited
+	}()
+
+	query := url.Values{"target": {diffTarget}, "modules": diffModules}
+	probeURL := "http://" + address + "/probe?" + query.Encode()
+	deadline := time.Now().Add(oldBinaryStartTimeout)
+	for {
+		select {
+		case <-exited:
+			return nil, fmt.Errorf("%s exited before serving /probe: %v%s", binary, exitErr, stderrOutput(&stderr))
+		default:
+		}
+		resp, err := http.Get(probeURL)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("%s did not start serving /probe on %s: %v", binary, address, err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read /probe of %s: %v", binary, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unable to collect with %s: /probe returned %s: %s", binary, resp.Status, strings.TrimSpace(body.String()))
+		}
+		return &body, nil
+	}
+}
+
+// stderrOutput returns the stderr output of a command on a new line, or an
+// empty string if there is none.
+func stderrOutput(stderr *bytes.Buffer) string {
+	if output := strings.TrimSpace(stderr.String()); output != "" {
+		return "\n" + output
+	}
+	return ""
+}
+
+// series is a single series identified by its name and labels.
+type series struct {
+	name       string
+	metricType dto.MetricType
+	// labels formatted as name="value" pairs sorted by name
+	labels string
+}
+
+func (s series) String() string {
+	if s.labels == "" {
+		return s.name
+	}
+	return s.name + "{" + s.labels + "}"
+}
+
+// familySeries returns the series of the metric families keyed by the series
+// name and labels.
+func familySeries(mfs []*dto.MetricFamily) map[string]series {
+	all := make(map[string]series)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			pairs := make([]string, 0, len(m.Label))
+			for _, label := range m.Label {
+				pairs = append(pairs, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			sort.Strings(pairs)
+			s := series{name: mf.GetName(), metricType: mf.GetType(), labels: strings.Join(pairs, ",")}
+			all[s.String()] = s
+		}
+	}
+	return all
+}
+
+// renameOf returns the series of the candidates that s was most likely renamed
+// to or from. Candidates must have the same type and labels as s. If there is
+// more than one, e.g. for series without labels, only a candidate whose name
+// starts with the name of s, or is the start of it, such as a unit suffix
+// added to the name, is used.
+func renameOf(s series, candidates []series) (series, bool) {
+	matches := []series{}
+	for _, c := range candidates {
+		if c.metricType == s.metricType && c.labels == s.labels {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) > 1 {
+		prefixed := []series{}
+		for _, c := range matches {
+			if strings.HasPrefix(c.name, s.name) || strings.HasPrefix(s.name, c.name) {
+				prefixed = append(prefixed, c)
+			}
+		}
+		matches = prefixed
+	}
+	if len(matches) != 1 {
+		return series{}, false
+	}
+	return matches[0], true
+}
+
+// diffSeries returns the sorted added and removed series, and the renamed
+// series as old and new pairs.
+func diffSeries(old map[string]series, current map[string]series) (added []string, removed []string, renamed [][2]string) {
+	addedSeries := []series{}
+	for key, s := range current {
+		if _, ok := old[key]; !ok {
+			addedSeries = append(addedSeries, s)
+		}
+	}
+	removedSeries := []series{}
+	for key, s := range old {
+		if _, ok := current[key]; !ok {
+			removedSeries = append(removedSeries, s)
+		}
+	}
+
+	renamedTo := make(map[string]bool)
+	renamedFrom := make(map[string]bool)
+	for _, r := range removedSeries {
+		a, ok := renameOf(r, addedSeries)
+		// the match must be unique in both directions
+		if b, _ := renameOf(a, removedSeries); !ok || b.String() != r.String() {
+			continue
+		}
+		renamed = append(renamed, [2]string{r.String(), a.String()})
+		renamedFrom[r.String()] = true
+		renamedTo[a.String()] = true
+	}
+
+	for _, a := range addedSeries {
+		if !renamedTo[a.String()] {
+			added = append(added, a.String())
+		}
+	}
+	for _, r := range removedSeries {
+		if !renamedFrom[r.String()] {
+			removed = append(removed, r.String())
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Slice(renamed, func(i, j int) bool { return renamed[i][0] < renamed[j][0] })
+	return added, removed, renamed
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// helpOverrides replaces the help text of metrics, keyed by metric name, e.g.
+// for localized dashboards or shared naming conventions.
+var helpOverrides map[string]string
+
+// loadHelpOverrides reads the help text overrides from the YAML mapping file
+// of metric names to help text, e.g.
+//
+//	klipper_extruder_temperature: Température de la buse en °C.
+func loadHelpOverrides(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]string)
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("invalid help file %s: %v", path, err)
+	}
+	for name := range overrides {
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			return fmt.Errorf("invalid help file %s: '%s' is not a valid metric name", path, name)
+		}
+	}
+	helpOverrides = overrides
+	return nil
+}
+
+// helpGatherer applies the help text overrides to the gathered metric families.
+type helpGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g helpGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	for _, mf := range mfs {
+		if help, ok := helpOverrides[mf.GetName()]; ok {
+			mf.Help = proto.String(help)
+		}
+	}
+	return mfs, err
+}
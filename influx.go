@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// influxHandler collects the target with the same parameters as the /probe
+// endpoint and writes the metrics in the InfluxDB line protocol, e.g. for the
+// Telegraf `inputs.http` plugin with `data_format = "influx"`. Each metric is
+// written as a measurement with the labels and the `target` as tags.
+func influxHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := probeCollector(r.Context(), w, r)
+	if !ok {
+		return
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	mfs, err := helpGatherer{registry}.Gather()
+	if err != nil {
+		log.Errorf("Collection of %s failed: %v", r.URL.Query().Get("target"), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	tags := map[string]string{"target": r.URL.Query().Get("target")}
+	now := time.Now()
+	for _, mf := range mfs {
+		writeInfluxFamily(out, mf, tags, now)
+	}
+}
+
+// writeInfluxFamily writes a line for each series of the metric family. The
+// value of counters, gauges, and untyped metrics is written to the `value`
+// field, and histograms and summaries to the `count` and `sum` fields and a
+// field for each bucket or quantile. Values that are not finite are left out
+// as they cannot be represented in the line protocol.
+func writeInfluxFamily(out *bufio.Writer, mf *dto.MetricFamily, tags map[string]string, now time.Time) {
+	for _, m := range mf.Metric {
+		fields := map[string]float64{}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			fields["value"] = m.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			fields["value"] = m.GetGauge().GetValue()
+		case dto.MetricType_UNTYPED:
+			fields["value"] = m.GetUntyped().GetValue()
+		case dto.MetricType_HISTOGRAM:
+			fields["count"] = float64(m.GetHistogram().GetSampleCount())
+			fields["sum"] = m.GetHistogram().GetSampleSum()
+			for _, b := range m.GetHistogram().GetBucket() {
+				fields[strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)] = float64(b.GetCumulativeCount())
+			}
+		case dto.MetricType_SUMMARY:
+			fields["count"] = float64(m.GetSummary().GetSampleCount())
+			fields["sum"] = m.GetSummary().GetSampleSum()
+			for _, q := range m.GetSummary().GetQuantile() {
+				fields[strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)] = q.GetValue()
+			}
+		}
+
+		names := make([]string, 0, len(fields))
+		for name, value := range fields {
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		seriesTags := make(map[string]string, len(tags)+len(m.Label))
+		for name, value := range tags {
+			seriesTags[name] = value
+		}
+		for _, label := range m.Label {
+			seriesTags[label.GetName()] = label.GetValue()
+		}
+		tagNames := make([]string, 0, len(seriesTags))
+		for name, value := range seriesTags {
+			// empty tag values are not allowed in the line protocol
+			if value != "" {
+				tagNames = append(tagNames, name)
+			}
+		}
+		sort.Strings(tagNames)
+
+		out.WriteString(influxEscape(mf.GetName(), ", "))
+		for _, name := range tagNames {
+			out.WriteString("," + influxEscape(name, ",= ") + "=" + influxEscape(seriesTags[name], ",= "))
+		}
+		for i, name := range names {
+			separator := ","
+			if i == 0 {
+				separator = " "
+			}
+			out.WriteString(separator + influxEscape(name, ",= ") + "=" + strconv.FormatFloat(fields[name], 'g', -1, 64))
+		}
+		out.WriteString(" " + strconv.FormatInt(now.UnixNano(), 10) + "\n")
+	}
+}
+
+// influxEscape escapes the special characters of a measurement, tag, or field
+// name or tag value with a backslash. Newlines cannot be escaped in the line
+// protocol and are replaced with `\n`.
+func influxEscape(s string, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		if r == '\n' {
+			b.WriteString("\\n")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,422 @@
+//go:build integration
+
+// Package integration tests the modules against the Klipper and Moonraker of
+// the virtual printer in docker-compose.yml, so a change of the Moonraker API
+// that the exporter no longer decodes is detected. Run with
+// `make integration`, or with `go test -tags integration ./integration/`
+// against the printer in INTEGRATION_TARGET.
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// defaultTarget is the Moonraker of the docker-compose.yml virtual printer.
+const defaultTarget = "localhost:7125"
+
+func TestMain(m *testing.M) {
+	// only report the errors of the collector
+	log.SetLevel(log.WarnLevel)
+	os.Exit(m.Run())
+}
+
+func target() string {
+	if target := os.Getenv("INTEGRATION_TARGET"); target != "" {
+		return target
+	}
+	return defaultTarget
+}
+
+// getJSON requests the Moonraker API path and returns the response body, and
+// the response decoded without a schema for checking the fields are present.
+func getJSON(t *testing.T, path string) ([]byte, map[string]interface{}) {
+	t.Helper()
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get("http://" + target() + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: HTTP status %s: %s", path, res.Status, body)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatalf("GET %s: invalid JSON: %v", path, err)
+	}
+	return body, raw
+}
+
+// decode decodes the response body into the response type of the module.
+func decode(t *testing.T, path string, body []byte, response interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(body, response); err != nil {
+		t.Fatalf("GET %s: unable to decode into %T: %v", path, response, err)
+	}
+}
+
+// requireFields checks the fields the exporter decodes are in the response,
+// given as dot separated paths, e.g. `result.disk_usage.total`. A number
+// selects an element of a list.
+func requireFields(t *testing.T, path string, raw map[string]interface{}, fields ...string) {
+	t.Helper()
+	for _, field := range fields {
+		var value interface{} = raw
+		for _, key := range strings.Split(field, ".") {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value = v[key]
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i >= len(v) {
+					value = nil
+				} else {
+					value = v[i]
+				}
+			default:
+				value = nil
+			}
+			if value == nil {
+				break
+			}
+		}
+		if value == nil {
+			t.Errorf("GET %s: field %s is missing", path, field)
+		}
+	}
+}
+
+// gather collects the module from the target and returns the metric families
+// keyed by name.
+func gather(t *testing.T, module string) map[string]*dto.MetricFamily {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	c := collector.New(ctx, target(), []string{module}, "", collector.Options{
+		RequestTimeout:    10 * time.Second,
+		ModuleConcurrency: 4,
+		UserAgent:         "prometheus-klipper-exporter/integration",
+	})
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("collecting %s: %v", module, err)
+	}
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	// every request of the module must succeed
+	success := false
+	if mf, ok := families["klipper_module_scrape_success"]; ok {
+		for _, m := range mf.Metric {
+			if labelValue(m, "module") == module {
+				success = m.GetGauge().GetValue() == 1
+			}
+		}
+	}
+	if !success {
+		t.Errorf("klipper_module_scrape_success{module=%q} is not 1", module)
+	}
+	return families
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.Label {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+func value(m *dto.Metric) float64 {
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	}
+	return m.GetUntyped().GetValue()
+}
+
+// series is a check of the value of a key series of a module.
+type series struct {
+	name string
+	// labels the series must have, e.g. `interface=eth0`
+	labels map[string]string
+	check  func(float64) bool
+	want   string
+}
+
+func present() func(float64) bool { return func(float64) bool { return true } }
+
+func positive() func(float64) bool { return func(v float64) bool { return v > 0 } }
+
+func equal(want float64) func(float64) bool {
+	return func(v float64) bool { return v == want }
+}
+
+// requireSeries checks each key series was collected with the expected value.
+func requireSeries(t *testing.T, families map[string]*dto.MetricFamily, checks ...series) {
+	t.Helper()
+	for _, check := range checks {
+		mf, ok := families[check.name]
+		if !ok {
+			t.Errorf("%s was not collected", check.name)
+			continue
+		}
+		found := false
+		for _, m := range mf.Metric {
+			matched := true
+			for name, want := range check.labels {
+				if labelValue(m, name) != want {
+					matched = false
+				}
+			}
+			if !matched {
+				continue
+			}
+			found = true
+			if v := value(m); !check.check(v) {
+				t.Errorf("%s%v = %v, want %s", check.name, check.labels, v, check.want)
+			}
+		}
+		if !found {
+			t.Errorf("%s%v was not collected", check.name, check.labels)
+		}
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	path := "/server/info"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.klippy_connected",
+		"result.klippy_state",
+		"result.moonraker_version",
+		"result.api_version_string",
+		"result.components",
+		"result.failed_components",
+		"result.warnings")
+	var info moonraker.ServerInfoResponse
+	decode(t, path, body, &info)
+	if !info.Result.KlippyConnected || info.Result.KlippyState != "ready" {
+		t.Fatalf("Klippy is not ready, connected: %t, state: %s", info.Result.KlippyConnected, info.Result.KlippyState)
+	}
+	if info.Result.MoonrakerVersion == "" {
+		t.Error("moonraker_version is empty")
+	}
+
+	families := gather(t, "server_info")
+	requireSeries(t, families,
+		series{"klipper_moonraker_version_info", map[string]string{"version": info.Result.MoonrakerVersion}, equal(1), "1"},
+		series{"klipper_moonraker_component_info", map[string]string{"component": "history"}, equal(1), "1"},
+		series{"klipper_moonraker_warnings", nil, present(), "present"})
+}
+
+func TestProcessStats(t *testing.T) {
+	path := "/machine/proc_stats"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.moonraker_stats.0.cpu_usage",
+		"result.moonraker_stats.0.memory",
+		"result.moonraker_stats.0.mem_units",
+		"result.system_cpu_usage.cpu",
+		"result.system_memory.total",
+		"result.system_memory.available",
+		"result.system_memory.used",
+		"result.system_uptime",
+		"result.websocket_connections")
+	var stats moonraker.ProcessStatsQueryResponse
+	decode(t, path, body, &stats)
+	if len(stats.Result.MoonrakerStats) == 0 || stats.Result.MoonrakerStats[0].Memory <= 0 {
+		t.Errorf("moonraker_stats memory is not decoded: %+v", stats.Result.MoonrakerStats)
+	}
+	if stats.Result.SystemMemory.Total <= 0 {
+		t.Errorf("system_memory total is not decoded: %+v", stats.Result.SystemMemory)
+	}
+
+	families := gather(t, "process_stats")
+	requireSeries(t, families,
+		series{"klipper_moonraker_memory_kb", nil, positive(), "> 0"},
+		series{"klipper_moonraker_cpu_usage", nil, present(), "present"},
+		series{"klipper_system_cpu", nil, present(), "present"},
+		series{"klipper_system_memory_total", nil, equal(float64(stats.Result.SystemMemory.Total)), strconv.Itoa(stats.Result.SystemMemory.Total)},
+		series{"klipper_system_memory_used", nil, positive(), "> 0"},
+		series{"klipper_system_uptime", nil, positive(), "> 0"})
+}
+
+func TestNetworkStats(t *testing.T) {
+	path := "/machine/proc_stats"
+	body, raw := getJSON(t, path)
+	var stats moonraker.ProcessStatsQueryResponse
+	decode(t, path, body, &stats)
+	if len(stats.Result.Network) == 0 {
+		t.Fatal("network is not decoded")
+	}
+	for name := range stats.Result.Network {
+		prefix := "result.network." + name + "."
+		requireFields(t, path, raw,
+			prefix+"rx_bytes", prefix+"tx_bytes",
+			prefix+"rx_packets", prefix+"tx_packets",
+			prefix+"rx_errs", prefix+"tx_errs",
+			prefix+"rx_drop", prefix+"tx_drop",
+			prefix+"bandwidth")
+	}
+
+	families := gather(t, "network_stats")
+	for name := range stats.Result.Network {
+		labels := map[string]string{"interface": name}
+		requireSeries(t, families,
+			series{"klipper_network_rx_bytes", labels, present(), "present"},
+			series{"klipper_network_tx_bytes", labels, present(), "present"},
+			series{"klipper_network_bandwidth", labels, present(), "present"})
+	}
+}
+
+func TestSystemInfo(t *testing.T) {
+	path := "/machine/system_info"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.system_info.cpu_info.cpu_count",
+		"result.system_info.cpu_info.total_memory",
+		"result.system_info.cpu_info.memory_units",
+		"result.system_info.cpu_info.bits",
+		"result.system_info.distribution.name",
+		"result.system_info.distribution.id",
+		"result.system_info.distribution.kernel_version",
+		"result.system_info.python.version")
+	var info moonraker.SystemInfoQueryResponse
+	decode(t, path, body, &info)
+	if info.Result.SystemInfo.CpuInfo.CpuCount <= 0 {
+		t.Errorf("cpu_count is not decoded: %+v", info.Result.SystemInfo.CpuInfo)
+	}
+
+	families := gather(t, "system_info")
+	requireSeries(t, families,
+		series{"klipper_system_cpu_count", nil, equal(float64(info.Result.SystemInfo.CpuInfo.CpuCount)), strconv.Itoa(info.Result.SystemInfo.CpuInfo.CpuCount)})
+}
+
+func TestDirectoryInfo(t *testing.T) {
+	path := "/server/files/directory?path=gcodes&extended=false"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.disk_usage.total",
+		"result.disk_usage.used",
+		"result.disk_usage.free")
+	var info moonraker.DirectoryInfoResponse
+	decode(t, path, body, &info)
+	if info.Result.DiskUsage.Total <= 0 {
+		t.Errorf("disk_usage is not decoded: %+v", info.Result.DiskUsage)
+	}
+
+	families := gather(t, "directory_info")
+	requireSeries(t, families,
+		series{"klipper_disk_usage_total", nil, positive(), "> 0"},
+		series{"klipper_disk_usage_used", nil, present(), "present"},
+		series{"klipper_disk_usage_available", nil, positive(), "> 0"})
+}
+
+func TestJobQueue(t *testing.T) {
+	path := "/server/job_queue/status"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.queued_jobs",
+		"result.queue_state")
+	var queue moonraker.JobQueueResponse
+	decode(t, path, body, &queue)
+	if queue.Result.QueueState == "" {
+		t.Error("queue_state is not decoded")
+	}
+
+	families := gather(t, "job_queue")
+	requireSeries(t, families,
+		series{"klipper_job_queue_length", nil, equal(float64(len(queue.Result.QueuedJobs))), fmt.Sprint(len(queue.Result.QueuedJobs))})
+}
+
+func TestHistory(t *testing.T) {
+	path := "/server/history/totals"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.job_totals.total_jobs",
+		"result.job_totals.total_time",
+		"result.job_totals.total_print_time",
+		"result.job_totals.total_filament_used",
+		"result.job_totals.longest_job",
+		"result.job_totals.longest_print")
+	var history moonraker.HistoryResponse
+	decode(t, path, body, &history)
+
+	path = "/server/history/list?limit=1&start=0&since=1&order=desc"
+	_, raw = getJSON(t, path)
+	requireFields(t, path, raw, "result.count", "result.jobs")
+
+	families := gather(t, "history")
+	requireSeries(t, families,
+		series{"klipper_total_jobs", nil, equal(history.Result.JobTotals.Jobs), fmt.Sprint(history.Result.JobTotals.Jobs)})
+}
+
+func TestPrinterObjects(t *testing.T) {
+	path := "/printer/objects/query?toolhead&mcu&print_stats&idle_timeout"
+	body, raw := getJSON(t, path)
+	requireFields(t, path, raw,
+		"result.status.toolhead.max_velocity",
+		"result.status.toolhead.max_accel",
+		"result.status.toolhead.square_corner_velocity",
+		"result.status.toolhead.print_time",
+		"result.status.toolhead.estimated_print_time",
+		"result.status.mcu.mcu_version",
+		"result.status.mcu.last_stats.freq",
+		"result.status.mcu.last_stats.send_seq",
+		"result.status.mcu.last_stats.bytes_write",
+		"result.status.print_stats.state",
+		"result.status.print_stats.filename",
+		"result.status.print_stats.total_duration",
+		"result.status.print_stats.filament_used",
+		"result.status.idle_timeout.printing_time")
+	var objects moonraker.PrinterObjectResponse
+	decode(t, path, body, &objects)
+	status := objects.Result.Status
+	if status.Mcu.McuVersion == "" || status.Mcu.LastStats.Freq <= 0 {
+		t.Errorf("mcu is not decoded: %+v", status.Mcu)
+	}
+	if status.PrintStats.State == "" {
+		t.Error("print_stats state is not decoded")
+	}
+
+	// the values of the printer section of klipper/printer.cfg
+	families := gather(t, "printer_objects")
+	requireSeries(t, families,
+		series{"klipper_toolhead_max_velocity", nil, equal(300), "300"},
+		series{"klipper_toolhead_max_accel", nil, equal(3000), "3000"},
+		series{"klipper_mcu_clock_frequency", nil, positive(), "> 0"},
+		series{"klipper_mcu_version_info", map[string]string{"mcu": "mcu", "version": status.Mcu.McuVersion}, equal(1), "1"},
+		series{"klipper_printing_time", nil, present(), "present"},
+		series{"klipper_printer_objects_failed", nil, equal(0), "0"})
+}
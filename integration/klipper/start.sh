@@ -0,0 +1,7 @@
+#!/bin/bash
+# Starts the host MCU, Klipper, and Moonraker, and exits if any of them stops.
+set -e
+klipper_mcu &
+python /opt/klipper/klippy/klippy.py /data/config/printer.cfg -a /tmp/klippy_uds -l /data/logs/klippy.log &
+python /opt/moonraker/moonraker/moonraker.py -d /data -l /data/logs/moonraker.log &
+wait -n
@@ -1,90 +1,425 @@
 package main
 
 import (
-	"flag"
-	"net/http"
+	"fmt"
+	"net/url"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/scross01/prometheus-klipper-exporter/collector"
 )
 
 // Command line configuration options
 var (
-	loggingLevel  = flag.String("logging.level", "Info", "Logging output level. Set to one of Trace, Debug, Info, Warning, Error, Fatal, or Panic")
-	klipperApiKey = flag.String("moonraker.apikey", "", "API Key to authenticate with the Klipper APIs.")
-	listenAddress = flag.String("web.listen-address", ":9101", "Address on which to expose metrics and web interface.")
+	loggingLevel         string
+	klipperApiKey        string
+	listenAddress        string
+	enableOpenMetrics    bool
+	disableCompression   bool
+	scrapeTimeoutOffset  time.Duration
+	enableInflux         bool
+	enableLifecycle      bool
+	adminToken           string
+	pauseTTL             time.Duration
+	proxyURL             string
+	moonrakerProxy       *url.URL
+	configFile           string
+	configWatchInterval  time.Duration
+	alertsInterval       time.Duration
+	configConcurrency    int
+	configTimeout        time.Duration
+	autoDisable          int
+	autoDisableRetry     time.Duration
+	cacheTTLs            map[string]string
+	cacheTTL             map[string]time.Duration
+	heaterWattages       map[string]string
+	heaterWattage        map[string]float64
+	psuCapacity          float64
+	emptySpoolWeights    map[string]string
+	emptySpoolWeight     map[string]float64
+	historyJobs          int
+	durationBuckets      []float64
+	filamentBuckets      []float64
+	dialTimeout          time.Duration
+	responseTimeout      time.Duration
+	jsonRPCBatch         bool
+	maxSeries            int
+	heatSoakTolerance    float64
+	heatSoakDuration     time.Duration
+	filamentMotionWindow time.Duration
+	gcodeStoreMacros     []string
+	dualEmit             bool
+	requestTag           string
+	doorButtons          []string
+	doorOpenState        string
+	smoothedSensors      []string
+	smoothingTime        time.Duration
+	fanRpmLearningTime   time.Duration
+	heaterSaturationTime time.Duration
+	samplingInterval     time.Duration
+	sampledSignalNames   []string
+	// sampledSignals are parsed from sampledSignalNames
+	sampledSignals    []collector.SampledSignal
+	helpFile          string
+	temperatureLabels bool
+	printerNameLabel  bool
+	mcuLabels         bool
+	denyRules         []string
+	valueRangeRules   []string
+	// valueRanges are parsed from valueRangeRules
+	valueRanges       map[string]collector.ValueRange
+	metricsPrefix     string
+	moduleConcurrency int
+	requestTimeout    time.Duration
+	// deniedLabels are parsed from denyRules
+	deniedLabels         []collector.DeniedLabel
+	eventsURL            string
+	eventsTopic          string
+	maintenanceStateFile string
+	filamentStateFile    string
+	// eventPublisher is created by the serve command when eventsURL is set
+	eventPublisher collector.EventPublisher
+	// filamentTotals is created by the serve command
+	filamentTotals *collector.FilamentTotals
 	// TODO deprecated, to be removed.
-	debug   = flag.Bool("debug", false, "(Deprecated) Enable debug logging. Use -logging.level instead.")
-	verbose = flag.Bool("verbose", false, "(Deprecated) Enable verbose trace level logging. Use -logging.level instead.")
+	debug   bool
+	verbose bool
 )
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-
-	target := query.Get("target")
-	if len(query["target"]) != 1 || target == "" {
-		http.Error(w, "'target' parameter must be specified once", 400)
-		return
-	}
-
-	// Set default modules
-	modules := []string{"process_stats", "job_queue", "system_info"}
-	// get `modules` configuration passed from the prometheus.yml
-	if len(query["modules"]) > 0 {
-		modules = query["modules"]
-	}
-	log.Infof("Starting metrics collection of %s for %s", modules, target)
-
-	// set api key. prometheus.yml > command line arg > environment variable
-	apiKey := ""
-	auth := r.Header.Get("Authorization")
-	if auth != "" && strings.HasPrefix(auth, "APIKEY") {
-		apiKey = strings.Replace(auth, "APIKEY ", "", 1)
-		log.Debug("Using API key from prometheus.yml authorization configuration")
-	} else if *klipperApiKey != "" {
-		apiKey = *klipperApiKey
-		log.Debug("Using API key from -moonraker.apikey command line argument")
-	} else if apiKey = os.Getenv("MOONRAKER_APIKEY"); apiKey != "" {
-		log.Debug("Using API key from MOONRAKER_APIKEY environment variable")
-	} else {
-		log.Debug("API key not set")
-	}
-
-	registry := prometheus.NewRegistry()
-	c := collector.New(r.Context(), target, modules, apiKey)
-	registry.MustRegister(c)
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
+// version and revision are set at build time, e.g.
+// `-ldflags "-X main.version=v0.11.0 -X main.revision=$(git rev-parse --short HEAD)"`
+var (
+	version  = "dev"
+	revision = "unknown"
+)
+
+var buildInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "klipper_exporter_build_info",
+	Help: "A metric with a constant '1' value labeled by the version and revision the exporter was built from, and the Go version used to build it.",
+}, []string{"version", "revision", "goversion"})
+
+func init() {
+	buildInfo.WithLabelValues(version, revision, runtime.Version()).Set(1)
 }
 
-func main() {
-	flag.Parse()
+// envPrefix is prepended to the upper cased flag name to get the environment
+// variable that can be used to set the flag, e.g. `KLIPPER_EXPORTER_LOGGING_LEVEL`
+const envPrefix = "KLIPPER_EXPORTER_"
+
+// flagSources records where the value of each flag was set from, one of
+// `flag`, `env`, or `default`.
+var flagSources = make(map[string]string)
+
+var rootCmd = &cobra.Command{
+	Use:   "prometheus-klipper-exporter",
+	Short: "Prometheus exporter for Klipper",
+	Long: `Prometheus exporter for Klipper to capture operational metrics from
+one or more Klipper hosts using the Moonraker APIs.
+
+Running without a command starts the exporter server.
+
+Options can be set using command line flags, or environment variables named
+using the ` + envPrefix + ` prefix and the upper cased flag name, e.g.
+` + envPrefix + `WEB_LISTEN_ADDRESS. Command line flags take precedence over
+environment variables.`,
+	SilenceUsage:      true,
+	PersistentPreRunE: setup,
+	RunE:              runServe,
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&loggingLevel, "logging.level", "Info", "Logging output level. Set to one of Trace, Debug, Info, Warning, Error, Fatal, or Panic")
+	flags.StringVar(&klipperApiKey, "moonraker.apikey", "", "API Key to authenticate with the Klipper APIs.")
+	flags.StringVar(&proxyURL, "moonraker.proxy-url", "", "HTTP proxy the Moonraker requests are sent through, e.g. http://proxy.local:3128. Can be overridden for each target in the configuration file.")
+	flags.DurationVar(&requestTimeout, "moonraker.request-timeout", 10*time.Second, "Maximum time of each request to Moonraker. Requests are also cancelled when the scrape is cancelled.")
+	flags.DurationVar(&dialTimeout, "moonraker.dial-timeout", 5*time.Second, "Maximum time to connect to Moonraker. Connections are kept alive and reused across scrapes.")
+	flags.DurationVar(&responseTimeout, "moonraker.response-header-timeout", 0, "Maximum time to wait for the Moonraker response headers after sending a request. Set to 0 to only limit the wait by the request timeout.")
+	flags.BoolVar(&jsonRPCBatch, "moonraker.jsonrpc-batch", false, "Send the requests of the modules collected in parallel to Moonraker in JSON-RPC batches to reduce the scrape latency over slow networks.")
+	flags.IntVar(&moduleConcurrency, "modules.concurrency", 4, "Maximum number of modules of a target collected in parallel.")
+	flags.IntVar(&autoDisable, "modules.auto-disable-after", 3, "Stop querying a module for a target after this many consecutive HTTP 404 responses. Set to 0 to never disable modules.")
+	flags.DurationVar(&autoDisableRetry, "modules.auto-disable-retry", time.Hour, "How long an automatically disabled module is skipped before it is queried again. Set to 0 to keep it disabled until restart.")
+	flags.StringToStringVar(&cacheTTLs, "modules.cache-ttl", defaultCacheTTLs(), "How long the responses of a module are reused before they are requested again, as module=duration, e.g. system_info=5m. Set to 0 to request the module on every scrape.")
+	flags.StringToStringVar(&heaterWattages, "heaters.wattage", map[string]string{}, "Rated power in watts of the heaters to estimate the power drawn, as heater=watts, e.g. heater_bed=400,extruder=60.")
+	flags.StringToStringVar(&emptySpoolWeights, "load-cell.empty-spool-grams", map[string]string{}, "Weight in grams of the empty spool on load_cell spool scales to report the filament remaining, as sensor=grams, e.g. spool_scale=250.")
+	flags.Float64Var(&psuCapacity, "psu.capacity-watts", 0, "Power in watts the PSU can supply, to report the estimated heater load as a ratio of the capacity. Set to 0 to not report the PSU load.")
+	flags.IntVar(&historyJobs, "history.jobs", 100, "Number of the most recent jobs in the print history the print duration and filament used histograms are built from. Set to 0 to not report the histograms.")
+	flags.Float64SliceVar(&durationBuckets, "history.duration-buckets", collector.DefaultDurationBuckets, "Upper bounds in seconds of the print duration histogram buckets.")
+	flags.Float64SliceVar(&filamentBuckets, "history.filament-buckets", collector.DefaultFilamentBuckets, "Upper bounds in millimeters of the filament used histogram buckets.")
+	flags.IntVar(&maxSeries, "metrics.max-series", 0, "Maximum number of series exposed for a single target. Set to 0 for no limit.")
+	flags.Float64Var(&heatSoakTolerance, "heat-soak.tolerance", 2, "Maximum difference in degrees celsius between the bed temperature and target for the bed to be heat soaking.")
+	flags.DurationVar(&heatSoakDuration, "heat-soak.duration", 10*time.Minute, "How long the bed must be within the heat soak tolerance of the target to be reported as heat soaked.")
+	flags.DurationVar(&filamentMotionWindow, "filament-motion.window", 5*time.Minute, "Rolling window over which the filament motion sensor extrusion ratio is calculated.")
+	flags.StringSliceVar(&gcodeStoreMacros, "gcode-store.macros", []string{"PAUSE", "RESUME", "CANCEL_PRINT", "M600"}, "Macros to count the executions of from the gcode store.")
+	flags.StringVar(&requestTag, "moonraker.request-tag", "", "Value of the X-Exporter-Tag header sent with all Moonraker requests. Can be overridden for each target with the `tag` probe parameter.")
+	flags.StringSliceVar(&doorButtons, "door.buttons", []string{"door"}, "Names of the gcode_button door or enclosure switches to report the open time of.")
+	flags.StringVar(&doorOpenState, "door.open-state", "RELEASED", "State of the door gcode_button while the door is open, PRESSED or RELEASED.")
+	flags.StringSliceVar(&smoothedSensors, "smoothing.sensors", []string{}, "Names of the heaters and temperature sensors to also export an exponentially smoothed temperature for, e.g. `extruder,chamber`.")
+	flags.DurationVar(&smoothingTime, "smoothing.time-constant", 30*time.Second, "Time constant of the exponential smoothing. Longer times smooth more but respond slower to real changes.")
+	flags.DurationVar(&fanRpmLearningTime, "fan-rpm.learning-time", 24*time.Hour, "Time over which the expected RPM per PWM ratio of fans with a tachometer is learned. Set to 0 to disable the fan RPM residual metrics.")
+	flags.DurationVar(&heaterSaturationTime, "heater-saturation.window", 5*time.Minute, "Time span of the recent temperature store samples the fraction of time each heater is at maximum power while below its target is calculated over. Set to 0 to disable.")
+	flags.DurationVar(&samplingInterval, "sampling.interval", 0, "Interval the sampled signals are sampled at between scrapes to report their minimum, maximum, and average for each scrape interval, e.g. 1s. Set to 0 to disable the sampling.")
+	flags.StringSliceVar(&sampledSignalNames, "sampling.signals", collector.DefaultSampledSignals, "Printer object attributes to sample, as object.attribute, e.g. fan.rpm or temperature_fan exhaust.rpm.")
+	flags.StringVar(&helpFile, "metrics.help-file", "", "YAML file mapping metric names to help text that replaces the built in help text.")
+	flags.BoolVar(&temperatureLabels, "temperature.labeled", false, "Expose the temperature module metrics as single families with a sensor label, e.g. klipper_temperature_celsius{sensor=\"extruder\"}.")
+	flags.BoolVar(&mcuLabels, "mcu.labeled", false, "Expose the mcu statistics of every mcu, e.g. CAN toolhead boards, with an mcu label, e.g. klipper_mcu_awake{mcu=\"EBBCan\"}, instead of only the primary mcu.")
+	flags.BoolVar(&printerNameLabel, "metrics.printer-name-label", false, "Add the printer name set in the Mainsail or Fluidd settings saved in Moonraker as a printer_name label to the metrics of each target.")
+	flags.StringSliceVar(&denyRules, "metrics.deny", []string{}, "Drop all series with the label value, as label=value, e.g. sensor=ambient_outdoor. Can be repeated.")
+	flags.StringSliceVar(&valueRangeRules, "metrics.value-range", []string{}, "Plausible range of a metric, as metric=min:max, to drop the values outside of, or metric=min:max:clamp to clamp them to the range. Either bound can be empty, and metric= removes a default range. Can be repeated.")
+	flags.StringVar(&metricsPrefix, "metrics.prefix", collector.DefaultMetricsPrefix, "Namespace prefix of the Klipper metric names, e.g. printer for printer_extruder_temperature.")
+	flags.BoolVar(&dualEmit, "metrics.dual-emit", false, "Also expose the pre v0.7.0 per entity metric names alongside the labeled metrics.")
+	flags.BoolVar(&debug, "debug", false, "(Deprecated) Enable debug logging. Use --logging.level instead.")
+	flags.BoolVar(&verbose, "verbose", false, "(Deprecated) Enable verbose trace level logging. Use --logging.level instead.")
+	flags.MarkDeprecated("debug", "use '--logging.level debug' instead")
+	flags.MarkDeprecated("verbose", "use '--logging.level trace' instead")
 
-	level, err := log.ParseLevel(strings.ToLower(*loggingLevel))
+	// serve is the default command, so the server flags are also accepted
+	// without a command for existing deployments.
+	addServeFlags(rootCmd.Flags())
+}
+
+// setup applies environment variables for flags that were not set on the
+// command line, then configures logging.
+func setup(cmd *cobra.Command, args []string) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			flagSources[f.Name] = "flag"
+			return
+		}
+		flagSources[f.Name] = "default"
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := f.Value.Set(value); setErr != nil && err == nil {
+				err = fmt.Errorf("invalid value '%s' for %s: %v", value, envName(f.Name), setErr)
+			}
+			flagSources[f.Name] = "env"
+		}
+	})
 	if err != nil {
-		log.Fatalf("Invalid logging level '%s'", *loggingLevel)
+		return err
+	}
+
+	level, err := log.ParseLevel(strings.ToLower(loggingLevel))
+	if err != nil {
+		return fmt.Errorf("invalid logging level '%s'", loggingLevel)
 	}
 	log.SetLevel(level)
 
-	// TODO remove when -debug and -verbose options are removed
-	if *debug {
-		log.Warn("-debug option is deprecated, change to using '-logging.level debug'")
+	// TODO remove when --debug and --verbose options are removed
+	if debug {
 		log.SetLevel(log.DebugLevel)
 	}
-	if *verbose {
-		log.Warn("-verbose option is deprecated, change to using '-logging.level trace'")
+	if verbose {
 		log.SetLevel(log.TraceLevel)
 	}
 
-	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
-		handler(w, r)
-	})
-	log.Infof("Beginning to serve on port %s", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	if deniedLabels, err = collector.ParseDeniedLabels(denyRules); err != nil {
+		return err
+	}
+
+	if valueRanges, err = collector.ParseValueRanges(valueRangeRules); err != nil {
+		return err
+	}
+
+	if sampledSignals, err = collector.ParseSampledSignals(sampledSignalNames); err != nil {
+		return err
+	}
+
+	if !model.IsValidMetricName(model.LabelValue(metricsPrefix)) {
+		return fmt.Errorf("invalid metrics prefix '%s'", metricsPrefix)
+	}
+
+	if cacheTTL, err = parseCacheTTLs(cacheTTLs); err != nil {
+		return err
+	}
+
+	if heaterWattage, err = parseHeaterWattages(heaterWattages); err != nil {
+		return err
+	}
+
+	if emptySpoolWeight, err = parseEmptySpoolWeights(emptySpoolWeights); err != nil {
+		return err
+	}
+
+	if moonrakerProxy, err = parseProxyURL(proxyURL); err != nil {
+		return err
+	}
+
+	if err := validateBuckets("history.duration-buckets", durationBuckets); err != nil {
+		return err
+	}
+	if err := validateBuckets("history.filament-buckets", filamentBuckets); err != nil {
+		return err
+	}
+
+	if helpFile != "" {
+		if err := loadHelpOverrides(helpFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultCacheTTLs returns the default module cache TTLs as flag values.
+func defaultCacheTTLs() map[string]string {
+	ttls := make(map[string]string)
+	for module, ttl := range collector.DefaultCacheTTL {
+		ttls[module] = ttl.String()
+	}
+	return ttls
+}
+
+// parseCacheTTLs parses and validates the module cache TTLs.
+func parseCacheTTLs(values map[string]string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for module, value := range values {
+		known := false
+		for _, m := range collector.Modules {
+			known = known || m.Name == module
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid cache TTL for unknown module '%s'", module)
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid cache TTL '%s' for module %s", value, module)
+		}
+		ttls[module] = ttl
+	}
+	return ttls, nil
+}
+
+// parseHeaterWattages parses and validates the heater wattages.
+func parseHeaterWattages(values map[string]string) (map[string]float64, error) {
+	wattages := make(map[string]float64)
+	for heater, value := range values {
+		watts, err := strconv.ParseFloat(value, 64)
+		if err != nil || watts <= 0 {
+			return nil, fmt.Errorf("invalid wattage '%s' for heater %s", value, heater)
+		}
+		wattages[heater] = watts
+	}
+	return wattages, nil
+}
+
+// parseEmptySpoolWeights parses and validates the empty spool weights.
+func parseEmptySpoolWeights(values map[string]string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for sensor, value := range values {
+		grams, err := strconv.ParseFloat(value, 64)
+		if err != nil || grams < 0 {
+			return nil, fmt.Errorf("invalid empty spool weight '%s' for load cell %s", value, sensor)
+		}
+		weights[sensor] = grams
+	}
+	return weights, nil
+}
+
+// validateBuckets checks the histogram bucket upper bounds are increasing.
+func validateBuckets(flag string, buckets []float64) error {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("invalid --%s, bucket upper bounds must be in increasing order", flag)
+		}
+	}
+	return nil
+}
+
+// parseProxyURL parses and validates a proxy URL, returning nil if not set.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL '%s'", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy URL scheme '%s', must be http, https, or socks5", u.Scheme)
+	}
+	return u, nil
+}
+
+// envName returns the environment variable name for the flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flag))
+}
+
+// collectorOptions returns the collector options set from the command line.
+func collectorOptions() collector.Options {
+	return collector.Options{
+		DualEmit:               dualEmit,
+		MetricsPrefix:          metricsPrefix,
+		ValueRanges:            valueRanges,
+		DeniedLabels:           append(append([]collector.DeniedLabel{}, deniedLabels...), currentConfig().deniedLabels...),
+		ProxyURL:               moonrakerProxy,
+		DialTimeout:            dialTimeout,
+		ResponseHeaderTimeout:  responseTimeout,
+		JSONRPCBatch:           jsonRPCBatch,
+		RequestTimeout:         requestTimeout,
+		ModuleConcurrency:      moduleConcurrency,
+		AutoDisableAfter:       autoDisable,
+		AutoDisableRetry:       autoDisableRetry,
+		CacheTTL:               cacheTTL,
+		HeaterWattage:          heaterWattage,
+		PSUCapacity:            psuCapacity,
+		EmptySpoolWeight:       emptySpoolWeight,
+		HistoryJobs:            historyJobs,
+		DurationBuckets:        durationBuckets,
+		FilamentBuckets:        filamentBuckets,
+		MaxSeries:              maxSeries,
+		HeatSoakTolerance:      heatSoakTolerance,
+		HeatSoakDuration:       heatSoakDuration,
+		FilamentMotionWindow:   filamentMotionWindow,
+		GcodeStoreMacros:       gcodeStoreMacros,
+		UserAgent:              "prometheus-klipper-exporter/" + version,
+		RequestTag:             requestTag,
+		DoorButtons:            doorButtons,
+		DoorOpenState:          doorOpenState,
+		SmoothedSensors:        smoothedSensors,
+		SmoothingTimeConstant:  smoothingTime,
+		FanRpmLearningTime:     fanRpmLearningTime,
+		HeaterSaturationWindow: heaterSaturationTime,
+		SamplingInterval:       samplingInterval,
+		SampledSignals:         sampledSignals,
+		TemperatureLabels:      temperatureLabels,
+		PrinterNameLabel:       printerNameLabel,
+		McuLabels:              mcuLabels,
+		Events:                 eventPublisher,
+		Maintenance:            currentMaintenance(),
+		FilamentTotals:         filamentTotals,
+	}
+}
+
+// normalizeArgs converts single dash long options, e.g. `-logging.level`, to
+// the double dash form for compatibility with earlier releases.
+func normalizeArgs(args []string) []string {
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		name := strings.SplitN(strings.TrimPrefix(arg, "-"), "=", 2)[0]
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && len(name) > 1 {
+			arg = "-" + arg
+		}
+		normalized[i] = arg
+	}
+	return normalized
+}
+
+func main() {
+	rootCmd.SetArgs(normalizeArgs(os.Args[1:]))
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,64 @@
+// Package moonraker is a client of the Moonraker API, with a typed method for
+// each of the endpoints the exporter collects the metrics from.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/
+package moonraker
+
+//go:generate mockgen -source=client.go -destination=mock_moonraker/mock_moonraker.go -package=mock_moonraker
+
+// Requester sends the requests of the API paths, e.g. `/server/info`, to
+// Moonraker and decodes the JSON responses. The collector implements it with
+// the response cache, rate limiting, request coalescing, and JSON-RPC
+// batching of the Moonraker requests.
+type Requester interface {
+	// Get requests the path and decodes the response into the response.
+	Get(path string, response interface{}) error
+	// Lookup is Get for the items that may not exist, e.g. the metadata of a
+	// file that was deleted. A HTTP 404 response is returned as an error, but
+	// is not a failure of the Moonraker API.
+	Lookup(path string, response interface{}) error
+}
+
+// API is the Moonraker API endpoints the metrics are collected from.
+type API interface {
+	// Server
+	ServerInfo() (*ServerInfoResponse, error)
+	ServerConfig() (*ServerConfigResponse, error)
+	Extensions() (*ExtensionsResponse, error)
+	Announcements() (*AnnouncementsResponse, error)
+	Webcams() (*WebcamsResponse, error)
+	TemperatureStore() (*TemperatureDataQueryResponse, error)
+	GcodeStore(count int) (*GcodeStoreResponse, error)
+	DatabaseItem(namespace string, key string) (*DatabaseItemResponse, error)
+	JobQueue() (*JobQueueResponse, error)
+	// History
+	HistoryTotals() (*HistoryResponse, error)
+	HistoryCurrent() (*HistoryCurrentPrintResponse, error)
+	HistoryJobs(limit int) (*HistoryCurrentPrintResponse, error)
+	// Files
+	DirectoryInfo() (*DirectoryInfoResponse, error)
+	LogFiles() (*FileListResponse, error)
+	FileMetadata(filename string) (*FileMetadataResponse, error)
+	// Machine
+	ProcessStats() (*ProcessStatsQueryResponse, error)
+	SystemInfo() (*SystemInfoQueryResponse, error)
+	UpdateStatus() (*UpdateStatusResponse, error)
+	PowerDevices() (*PowerDevicesResponse, error)
+	// Printer
+	PrinterInfo() (*PrinterInfoResponse, error)
+	PrinterObjectsList() (*PrinterObjectsList, error)
+	CustomObjects() (map[string][]string, error)
+	PrinterObjects(customObjects map[string][]string) (*PrinterObjectResponse, error)
+	ConfigFile() (*ConfigFileResponse, error)
+}
+
+// Client implements the API by sending the requests with the Requester.
+type Client struct {
+	requester Requester
+}
+
+// NewClient returns the client of the Moonraker API that sends the requests
+// with the requester.
+func NewClient(requester Requester) *Client {
+	return &Client{requester: requester}
+}
@@ -0,0 +1,36 @@
+package moonraker_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker/mock_moonraker"
+)
+
+func TestClientRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	requester := mock_moonraker.NewMockRequester(ctrl)
+	api := moonraker.NewClient(requester)
+
+	gomock.InOrder(
+		requester.EXPECT().Get("/server/gcode_store?count=100", gomock.Any()),
+		requester.EXPECT().Get("/server/database/item?namespace=mainsail&key=general.printername", gomock.Any()),
+		requester.EXPECT().Get("/server/history/list?limit=2&start=0&order=desc", gomock.Any()),
+		requester.EXPECT().Lookup("/server/files/metadata?filename=benchy+v2%2Fbenchy%26co.gcode", gomock.Any()),
+	)
+
+	if _, err := api.GcodeStore(100); err != nil {
+		t.Errorf("GcodeStore() error: %v", err)
+	}
+	if _, err := api.DatabaseItem("mainsail", "general.printername"); err != nil {
+		t.Errorf("DatabaseItem() error: %v", err)
+	}
+	if _, err := api.HistoryJobs(2); err != nil {
+		t.Errorf("HistoryJobs() error: %v", err)
+	}
+	if _, err := api.FileMetadata("benchy v2/benchy&co.gcode"); err != nil {
+		t.Errorf("FileMetadata() error: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package moonraker
+
+import (
+	"net/url"
+)
+
+type DirectoryInfoResponse struct {
+	Result struct {
+		DiskUsage struct {
+			Total int64 `json:"total"`
+			Used  int64 `json:"used"`
+			Free  int64 `json:"free"`
+		} `json:"disk_usage"`
+	} `json:"result"`
+}
+
+type FileListResponse struct {
+	Result []File `json:"result"`
+}
+
+type File struct {
+	Path     string  `json:"path"`
+	Modified float64 `json:"modified"`
+	Size     int64   `json:"size"`
+}
+
+type FileMetadataResponse struct {
+	Result struct {
+		Filename      string  `json:"filename"`
+		Size          int     `json:"size"`
+		Slicer        string  `json:"slicer"`
+		EstimatedTime float64 `json:"estimated_time"`
+		FilamentTotal float64 `json:"filament_total"`
+		FilamentType  string  `json:"filament_type"`
+	} `json:"result"`
+}
+
+// DirectoryInfo returns the disk usage of the gcodes directory.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-directory-information
+func (c *Client) DirectoryInfo() (*DirectoryInfoResponse, error) {
+	var response DirectoryInfoResponse
+	err := c.requester.Get("/server/files/directory?path=gcodes&extended=false", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// LogFiles returns the files in the logs root.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-available-files
+func (c *Client) LogFiles() (*FileListResponse, error) {
+	var response FileListResponse
+	err := c.requester.Get("/server/files/list?root=logs", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// FileMetadata returns the metadata of the gcode file. The file may have been
+// deleted since it was printed or queued, so the request is a Lookup.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-gcode-metadata
+func (c *Client) FileMetadata(filename string) (*FileMetadataResponse, error) {
+	var response FileMetadataResponse
+	err := c.requester.Lookup("/server/files/metadata?filename="+url.QueryEscape(filename), &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
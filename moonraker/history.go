@@ -1,15 +1,10 @@
-package collector
-
-// https://moonraker.readthedocs.io/en/latest/web_api/#history-apis
+package moonraker
 
 import (
-	"encoding/json"
-	log "github.com/sirupsen/logrus"
-	"io"
-	"net/http"
+	"fmt"
 )
 
-type MoonrakerHistoryResponse struct {
+type HistoryResponse struct {
 	Result struct {
 		JobTotals struct {
 			Jobs         float64 `json:"total_jobs"`
@@ -22,7 +17,7 @@ type MoonrakerHistoryResponse struct {
 	} `json:"result"`
 }
 
-type MoonrakerHistoryCurrentPrintResponse struct {
+type HistoryCurrentPrintResponse struct {
 	Result struct {
 		Count int `json:"count"`
 		Jobs  []struct {
@@ -60,73 +55,40 @@ type MoonrakerHistoryCurrentPrintResponse struct {
 	} `json:"result"`
 }
 
-func (c Collector) fetchMoonrakerHistory(klipperHost string, apiKey string) (*MoonrakerHistoryResponse, error) {
-	var url = "http://" + klipperHost + "/server/history/totals"
-	log.Debug("Collecting metrics from " + url)
-
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	if apiKey != "" {
-		req.Header.Set("X-API-KEY", apiKey)
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	var response MoonrakerHistoryResponse
-
-	err = json.Unmarshal(data, &response)
+// HistoryTotals returns the totals of the jobs in the print history.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-job-totals
+func (c *Client) HistoryTotals() (*HistoryResponse, error) {
+	var response HistoryResponse
+	err := c.requester.Get("/server/history/totals", &response)
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
-
 	return &response, nil
 }
-func (c Collector) fetchMoonrakerHistoryCurrent(klipperHost string, apiKey string) (*MoonrakerHistoryCurrentPrintResponse, error) {
-	var url = "http://" + klipperHost + "/server/history/list?limit=1&start=0&since=1&order=desc"
-	log.Debug("Collecting metrics from " + url)
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+// HistoryCurrent returns the most recent job in the print history, if it was
+// started since the exporter started.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-job-list
+func (c *Client) HistoryCurrent() (*HistoryCurrentPrintResponse, error) {
+	var response HistoryCurrentPrintResponse
+	err := c.requester.Get("/server/history/list?limit=1&start=0&since=1&order=desc", &response)
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
-	if apiKey != "" {
-		req.Header.Set("X-API-KEY", apiKey)
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	var response MoonrakerHistoryCurrentPrintResponse
+	return &response, nil
+}
 
-	err = json.Unmarshal(data, &response)
+// HistoryJobs returns the most recent limit jobs in the print history, newest
+// first.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-job-list
+func (c *Client) HistoryJobs(limit int) (*HistoryCurrentPrintResponse, error) {
+	var response HistoryCurrentPrintResponse
+	err := c.requester.Get(fmt.Sprintf("/server/history/list?limit=%d&start=0&order=desc", limit), &response)
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
-
 	return &response, nil
 }
@@ -0,0 +1,26 @@
+package moonraker
+
+type JobQueueResponse struct {
+	Result struct {
+		QueuedJobs []QueuedJob `json:"queued_jobs"`
+		QueueState string      `json:"queue_state"`
+	} `json:"result"`
+}
+
+type QueuedJob struct {
+	Filename    string  `json:"filename"`
+	JobID       string  `json:"job_id"`
+	TimeInQueue float64 `json:"time_in_queue"`
+}
+
+// JobQueue returns the jobs in the job queue.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#retrieve-the-job-queue-status
+func (c *Client) JobQueue() (*JobQueueResponse, error) {
+	var response JobQueueResponse
+	err := c.requester.Get("/server/job_queue/status", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,175 @@
+package moonraker
+
+type ProcessStatsQueryResponse struct {
+	Result struct {
+		MoonrakerStats       []ProcStats             `json:"moonraker_stats"`
+		CpuTemp              float64                 `json:"cpu_temp"`
+		Network              map[string]NetworkStats `json:"network"`
+		SystemCpuUsage       SystemCpuUsage          `json:"system_cpu_usage"`
+		SystemMemory         SystemMemory            `json:"system_memory"`
+		SystemUptime         float64                 `json:"system_uptime"`
+		WebsocketConnections int                     `json:"websocket_connectsions"`
+	} `json:"result"`
+}
+
+type ProcStats struct {
+	Time     float64 `json:"time"`
+	CpuUsage float64 `json:"cpu_usage"`
+	Memory   int     `json:"memory"`
+	MemUnits string  `json:"mem_units"`
+}
+
+type NetworkStats struct {
+	RxBytes   int64   `json:"rx_bytes"`
+	TxBytes   int64   `json:"tx_bytes"`
+	RxPackets int64   `json:"rx_packets"`
+	TxPackets int64   `json:"tx_packets"`
+	RxErrs    int     `json:"rx_errs"`
+	TxErrs    int     `json:"tx_errs"`
+	RxDrop    int     `json:"rx_drop"`
+	TxDrop    int     `json:"tx_drop"`
+	Bandwidth float64 `json:"bandwidth"`
+}
+
+type SystemCpuUsage struct {
+	Cpu  float64 `json:"cpu"`
+	Cpu0 float64 `json:"cpu0"`
+	Cpu1 float64 `json:"cpu1"`
+	Cpu2 float64 `json:"cpu2"`
+	Cpu3 float64 `json:"cpu3"`
+}
+
+type SystemMemory struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+	Used      int `json:"used"`
+}
+
+type SystemInfoQueryResponse struct {
+	Result struct {
+		SystemInfo struct {
+			CpuInfo struct {
+				CpuCount     int    `json:"cpu_count"`
+				TotalMemory  int    `json:"total_memory"`
+				MemoryUnits  string `json:"memory_units"`
+				Bits         string `json:"bits"`
+				Processor    string `json:"processor"`
+				CpuDesc      string `json:"cpu_desc"`
+				HardwareDesc string `json:"hardware_desc"`
+				Model        string `json:"model"`
+			} `json:"cpu_info"`
+			Distribution struct {
+				Name          string `json:"name"`
+				ID            string `json:"id"`
+				Version       string `json:"version"`
+				Codename      string `json:"codename"`
+				KernelVersion string `json:"kernel_version"`
+			} `json:"distribution"`
+			Virtualization struct {
+				VirtType       string `json:"virt_type"`
+				VirtIdentifier string `json:"virt_identifier"`
+			} `json:"virtualization"`
+			Python struct {
+				// e.g. [3, 9, 2, "final", 0]
+				Version []interface{} `json:"version"`
+			} `json:"python"`
+			// state of the services Moonraker is allowed to manage, keyed
+			// by service name
+			ServiceState map[string]ServiceState `json:"service_state"`
+		} `json:"system_info"`
+	} `json:"result"`
+}
+
+// ServiceState is the systemd state of a service, e.g. `active` and
+// `running`.
+type ServiceState struct {
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+}
+
+type UpdateStatusResponse struct {
+	Result struct {
+		Busy                    bool                       `json:"busy"`
+		GithubRequestsRemaining float64                    `json:"github_requests_remaining"`
+		VersionInfo             map[string]UpdateComponent `json:"version_info"`
+	} `json:"result"`
+}
+
+// UpdateComponent is the update status of a component managed by the
+// update_manager, e.g. klipper, moonraker, or a web client. The system
+// component only reports the number of system packages that can be updated.
+type UpdateComponent struct {
+	Version       string `json:"version"`
+	RemoteVersion string `json:"remote_version"`
+	// not reported by the system component
+	IsValid       *bool `json:"is_valid"`
+	IsDirty       bool  `json:"is_dirty"`
+	CommitsBehind []struct {
+		Sha string `json:"sha"`
+	} `json:"commits_behind"`
+	// reported by recent Moonraker versions, which may only list some of
+	// the commits in commits_behind
+	CommitsBehindCount *int `json:"commits_behind_count"`
+	PackageCount       *int `json:"package_count"`
+}
+
+type PowerDevicesResponse struct {
+	Result struct {
+		Devices []struct {
+			Device              string `json:"device"`
+			Status              string `json:"status"`
+			LockedWhilePrinting bool   `json:"locked_while_printing"`
+			Type                string `json:"type"`
+		} `json:"devices"`
+	} `json:"result"`
+}
+
+// ProcessStats returns the Moonraker process and host system statistics.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-moonraker-process-stats
+func (c *Client) ProcessStats() (*ProcessStatsQueryResponse, error) {
+	var response ProcessStatsQueryResponse
+	err := c.requester.Get("/machine/proc_stats", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SystemInfo returns the host system information.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-system-info
+func (c *Client) SystemInfo() (*SystemInfoQueryResponse, error) {
+	var response SystemInfoQueryResponse
+	err := c.requester.Get("/machine/system_info", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateStatus returns the update status last refreshed by the update_manager,
+// without requesting a refresh.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-update-status
+func (c *Client) UpdateStatus() (*UpdateStatusResponse, error) {
+	var response UpdateStatusResponse
+	err := c.requester.Get("/machine/update/status", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// PowerDevices returns the power devices configured in the Moonraker `[power]`
+// sections.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-device-list
+func (c *Client) PowerDevices() (*PowerDevicesResponse, error) {
+	var response PowerDevicesResponse
+	err := c.requester.Get("/machine/device_power/devices", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
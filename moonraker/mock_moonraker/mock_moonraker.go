@@ -0,0 +1,446 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: client.go
+
+// Package mock_moonraker is a generated GoMock package.
+package mock_moonraker
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	moonraker "github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// MockRequester is a mock of Requester interface.
+type MockRequester struct {
+	ctrl     *gomock.Controller
+	recorder *MockRequesterMockRecorder
+}
+
+// MockRequesterMockRecorder is the mock recorder for MockRequester.
+type MockRequesterMockRecorder struct {
+	mock *MockRequester
+}
+
+// NewMockRequester creates a new mock instance.
+func NewMockRequester(ctrl *gomock.Controller) *MockRequester {
+	mock := &MockRequester{ctrl: ctrl}
+	mock.recorder = &MockRequesterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRequester) EXPECT() *MockRequesterMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockRequester) Get(path string, response interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", path, response)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockRequesterMockRecorder) Get(path, response interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRequester)(nil).Get), path, response)
+}
+
+// Lookup mocks base method.
+func (m *MockRequester) Lookup(path string, response interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lookup", path, response)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lookup indicates an expected call of Lookup.
+func (mr *MockRequesterMockRecorder) Lookup(path, response interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lookup", reflect.TypeOf((*MockRequester)(nil).Lookup), path, response)
+}
+
+// MockAPI is a mock of API interface.
+type MockAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIMockRecorder
+}
+
+// MockAPIMockRecorder is the mock recorder for MockAPI.
+type MockAPIMockRecorder struct {
+	mock *MockAPI
+}
+
+// NewMockAPI creates a new mock instance.
+func NewMockAPI(ctrl *gomock.Controller) *MockAPI {
+	mock := &MockAPI{ctrl: ctrl}
+	mock.recorder = &MockAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPI) EXPECT() *MockAPIMockRecorder {
+	return m.recorder
+}
+
+// Announcements mocks base method.
+func (m *MockAPI) Announcements() (*moonraker.AnnouncementsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Announcements")
+	ret0, _ := ret[0].(*moonraker.AnnouncementsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Announcements indicates an expected call of Announcements.
+func (mr *MockAPIMockRecorder) Announcements() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Announcements", reflect.TypeOf((*MockAPI)(nil).Announcements))
+}
+
+// ConfigFile mocks base method.
+func (m *MockAPI) ConfigFile() (*moonraker.ConfigFileResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigFile")
+	ret0, _ := ret[0].(*moonraker.ConfigFileResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfigFile indicates an expected call of ConfigFile.
+func (mr *MockAPIMockRecorder) ConfigFile() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigFile", reflect.TypeOf((*MockAPI)(nil).ConfigFile))
+}
+
+// CustomObjects mocks base method.
+func (m *MockAPI) CustomObjects() (map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CustomObjects")
+	ret0, _ := ret[0].(map[string][]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CustomObjects indicates an expected call of CustomObjects.
+func (mr *MockAPIMockRecorder) CustomObjects() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CustomObjects", reflect.TypeOf((*MockAPI)(nil).CustomObjects))
+}
+
+// DatabaseItem mocks base method.
+func (m *MockAPI) DatabaseItem(namespace, key string) (*moonraker.DatabaseItemResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DatabaseItem", namespace, key)
+	ret0, _ := ret[0].(*moonraker.DatabaseItemResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DatabaseItem indicates an expected call of DatabaseItem.
+func (mr *MockAPIMockRecorder) DatabaseItem(namespace, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DatabaseItem", reflect.TypeOf((*MockAPI)(nil).DatabaseItem), namespace, key)
+}
+
+// DirectoryInfo mocks base method.
+func (m *MockAPI) DirectoryInfo() (*moonraker.DirectoryInfoResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DirectoryInfo")
+	ret0, _ := ret[0].(*moonraker.DirectoryInfoResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DirectoryInfo indicates an expected call of DirectoryInfo.
+func (mr *MockAPIMockRecorder) DirectoryInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DirectoryInfo", reflect.TypeOf((*MockAPI)(nil).DirectoryInfo))
+}
+
+// Extensions mocks base method.
+func (m *MockAPI) Extensions() (*moonraker.ExtensionsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Extensions")
+	ret0, _ := ret[0].(*moonraker.ExtensionsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Extensions indicates an expected call of Extensions.
+func (mr *MockAPIMockRecorder) Extensions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Extensions", reflect.TypeOf((*MockAPI)(nil).Extensions))
+}
+
+// FileMetadata mocks base method.
+func (m *MockAPI) FileMetadata(filename string) (*moonraker.FileMetadataResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FileMetadata", filename)
+	ret0, _ := ret[0].(*moonraker.FileMetadataResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FileMetadata indicates an expected call of FileMetadata.
+func (mr *MockAPIMockRecorder) FileMetadata(filename interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileMetadata", reflect.TypeOf((*MockAPI)(nil).FileMetadata), filename)
+}
+
+// GcodeStore mocks base method.
+func (m *MockAPI) GcodeStore(count int) (*moonraker.GcodeStoreResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GcodeStore", count)
+	ret0, _ := ret[0].(*moonraker.GcodeStoreResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GcodeStore indicates an expected call of GcodeStore.
+func (mr *MockAPIMockRecorder) GcodeStore(count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GcodeStore", reflect.TypeOf((*MockAPI)(nil).GcodeStore), count)
+}
+
+// HistoryCurrent mocks base method.
+func (m *MockAPI) HistoryCurrent() (*moonraker.HistoryCurrentPrintResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryCurrent")
+	ret0, _ := ret[0].(*moonraker.HistoryCurrentPrintResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryCurrent indicates an expected call of HistoryCurrent.
+func (mr *MockAPIMockRecorder) HistoryCurrent() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryCurrent", reflect.TypeOf((*MockAPI)(nil).HistoryCurrent))
+}
+
+// HistoryJobs mocks base method.
+func (m *MockAPI) HistoryJobs(limit int) (*moonraker.HistoryCurrentPrintResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryJobs", limit)
+	ret0, _ := ret[0].(*moonraker.HistoryCurrentPrintResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryJobs indicates an expected call of HistoryJobs.
+func (mr *MockAPIMockRecorder) HistoryJobs(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryJobs", reflect.TypeOf((*MockAPI)(nil).HistoryJobs), limit)
+}
+
+// HistoryTotals mocks base method.
+func (m *MockAPI) HistoryTotals() (*moonraker.HistoryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryTotals")
+	ret0, _ := ret[0].(*moonraker.HistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryTotals indicates an expected call of HistoryTotals.
+func (mr *MockAPIMockRecorder) HistoryTotals() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryTotals", reflect.TypeOf((*MockAPI)(nil).HistoryTotals))
+}
+
+// JobQueue mocks base method.
+func (m *MockAPI) JobQueue() (*moonraker.JobQueueResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JobQueue")
+	ret0, _ := ret[0].(*moonraker.JobQueueResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JobQueue indicates an expected call of JobQueue.
+func (mr *MockAPIMockRecorder) JobQueue() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JobQueue", reflect.TypeOf((*MockAPI)(nil).JobQueue))
+}
+
+// LogFiles mocks base method.
+func (m *MockAPI) LogFiles() (*moonraker.FileListResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogFiles")
+	ret0, _ := ret[0].(*moonraker.FileListResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LogFiles indicates an expected call of LogFiles.
+func (mr *MockAPIMockRecorder) LogFiles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogFiles", reflect.TypeOf((*MockAPI)(nil).LogFiles))
+}
+
+// PowerDevices mocks base method.
+func (m *MockAPI) PowerDevices() (*moonraker.PowerDevicesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PowerDevices")
+	ret0, _ := ret[0].(*moonraker.PowerDevicesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PowerDevices indicates an expected call of PowerDevices.
+func (mr *MockAPIMockRecorder) PowerDevices() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PowerDevices", reflect.TypeOf((*MockAPI)(nil).PowerDevices))
+}
+
+// PrinterInfo mocks base method.
+func (m *MockAPI) PrinterInfo() (*moonraker.PrinterInfoResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrinterInfo")
+	ret0, _ := ret[0].(*moonraker.PrinterInfoResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrinterInfo indicates an expected call of PrinterInfo.
+func (mr *MockAPIMockRecorder) PrinterInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrinterInfo", reflect.TypeOf((*MockAPI)(nil).PrinterInfo))
+}
+
+// PrinterObjects mocks base method.
+func (m *MockAPI) PrinterObjects(customObjects map[string][]string) (*moonraker.PrinterObjectResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrinterObjects", customObjects)
+	ret0, _ := ret[0].(*moonraker.PrinterObjectResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrinterObjects indicates an expected call of PrinterObjects.
+func (mr *MockAPIMockRecorder) PrinterObjects(customObjects interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrinterObjects", reflect.TypeOf((*MockAPI)(nil).PrinterObjects), customObjects)
+}
+
+// PrinterObjectsList mocks base method.
+func (m *MockAPI) PrinterObjectsList() (*moonraker.PrinterObjectsList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrinterObjectsList")
+	ret0, _ := ret[0].(*moonraker.PrinterObjectsList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrinterObjectsList indicates an expected call of PrinterObjectsList.
+func (mr *MockAPIMockRecorder) PrinterObjectsList() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrinterObjectsList", reflect.TypeOf((*MockAPI)(nil).PrinterObjectsList))
+}
+
+// ProcessStats mocks base method.
+func (m *MockAPI) ProcessStats() (*moonraker.ProcessStatsQueryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessStats")
+	ret0, _ := ret[0].(*moonraker.ProcessStatsQueryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessStats indicates an expected call of ProcessStats.
+func (mr *MockAPIMockRecorder) ProcessStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessStats", reflect.TypeOf((*MockAPI)(nil).ProcessStats))
+}
+
+// ServerConfig mocks base method.
+func (m *MockAPI) ServerConfig() (*moonraker.ServerConfigResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerConfig")
+	ret0, _ := ret[0].(*moonraker.ServerConfigResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerConfig indicates an expected call of ServerConfig.
+func (mr *MockAPIMockRecorder) ServerConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerConfig", reflect.TypeOf((*MockAPI)(nil).ServerConfig))
+}
+
+// ServerInfo mocks base method.
+func (m *MockAPI) ServerInfo() (*moonraker.ServerInfoResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerInfo")
+	ret0, _ := ret[0].(*moonraker.ServerInfoResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerInfo indicates an expected call of ServerInfo.
+func (mr *MockAPIMockRecorder) ServerInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerInfo", reflect.TypeOf((*MockAPI)(nil).ServerInfo))
+}
+
+// SystemInfo mocks base method.
+func (m *MockAPI) SystemInfo() (*moonraker.SystemInfoQueryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SystemInfo")
+	ret0, _ := ret[0].(*moonraker.SystemInfoQueryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SystemInfo indicates an expected call of SystemInfo.
+func (mr *MockAPIMockRecorder) SystemInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SystemInfo", reflect.TypeOf((*MockAPI)(nil).SystemInfo))
+}
+
+// TemperatureStore mocks base method.
+func (m *MockAPI) TemperatureStore() (*moonraker.TemperatureDataQueryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TemperatureStore")
+	ret0, _ := ret[0].(*moonraker.TemperatureDataQueryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TemperatureStore indicates an expected call of TemperatureStore.
+func (mr *MockAPIMockRecorder) TemperatureStore() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TemperatureStore", reflect.TypeOf((*MockAPI)(nil).TemperatureStore))
+}
+
+// UpdateStatus mocks base method.
+func (m *MockAPI) UpdateStatus() (*moonraker.UpdateStatusResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus")
+	ret0, _ := ret[0].(*moonraker.UpdateStatusResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockAPIMockRecorder) UpdateStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockAPI)(nil).UpdateStatus))
+}
+
+// Webcams mocks base method.
+func (m *MockAPI) Webcams() (*moonraker.WebcamsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webcams")
+	ret0, _ := ret[0].(*moonraker.WebcamsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Webcams indicates an expected call of Webcams.
+func (mr *MockAPIMockRecorder) Webcams() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webcams", reflect.TypeOf((*MockAPI)(nil).Webcams))
+}
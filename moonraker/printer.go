@@ -0,0 +1,64 @@
+package moonraker
+
+type PrinterInfoResponse struct {
+	Result struct {
+		State           string `json:"state"`
+		StateMessage    string `json:"state_message"`
+		Hostname        string `json:"hostname"`
+		SoftwareVersion string `json:"software_version"`
+	} `json:"result"`
+}
+
+// ConfigFileResponse is the `configfile` printer object with the
+// settings parsed from printer.cfg, keyed by lower case section name.
+type ConfigFileResponse struct {
+	Result struct {
+		Status struct {
+			ConfigFile struct {
+				Settings map[string]map[string]interface{} `json:"settings"`
+			} `json:"configfile"`
+		} `json:"status"`
+	} `json:"result"`
+}
+
+type PrinterObjectsList struct {
+	Result struct {
+		Objects []string `json:"objects"`
+	} `json:"result"`
+}
+
+// PrinterInfo returns the Klippy state and the message describing it.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-klippy-host-information
+func (c *Client) PrinterInfo() (*PrinterInfoResponse, error) {
+	var response PrinterInfoResponse
+	err := c.requester.Get("/printer/info", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// PrinterObjectsList returns the names of all of the printer objects.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-available-printer-objects
+func (c *Client) PrinterObjectsList() (*PrinterObjectsList, error) {
+	var response PrinterObjectsList
+	err := c.requester.Get("/printer/objects/list", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ConfigFile returns the settings of the printer.cfg.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#query-printer-object-status
+func (c *Client) ConfigFile() (*ConfigFileResponse, error) {
+	var response ConfigFileResponse
+	err := c.requester.Get("/printer/objects/query?configfile=settings", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
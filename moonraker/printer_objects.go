@@ -0,0 +1,484 @@
+package moonraker
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+)
+
+type PrinterObjectResponse struct {
+	Result struct {
+		Status PrinterObjectStatus `json:"status"`
+	} `json:"result"`
+}
+
+// PrinterObjectStatus contains the printer objects that are queried. The
+// object names and attributes requested are taken from the json tags, see
+// printerObjectsQuery.
+type PrinterObjectStatus struct {
+	GcodeMove     PrinterObjectGcodeMove     `json:"gcode_move"`
+	Toolhead      PrinterObjectToolhead      `json:"toolhead"`
+	Extruder      PrinterObjectExtruder      `json:"extruder"`
+	HeaterBed     PrinterObjectHeaterBed     `json:"heater_bed"`
+	Fan           PrinterObjectFan           `json:"fan"`
+	IdleTimeout   PrinterObjectIdleTimeout   `json:"idle_timeout"`
+	VirtualSdCard PrinterObjectVirtualSdCard `json:"virtual_sdcard"`
+	PrintStats    PrinterObjectPrintStats    `json:"print_stats"`
+	DisplayStatus PrinterObjectDisplayStatus `json:"display_status"`
+	Mcu           PrinterObjectMcu           `json:"mcu"`
+	ExcludeObject PrinterObjectExcludeObject `json:"exclude_object"`
+	MotionReport  PrinterObjectMotionReport  `json:"motion_report"`
+	// optional objects that are only reported if configured
+	ZThermalAdjust *PrinterObjectZThermalAdjust `json:"z_thermal_adjust"`
+	BedMesh        *PrinterObjectBedMesh        `json:"bed_mesh"`
+	Beacon         *PrinterObjectBeacon         `json:"beacon"`
+	LoadCell       *PrinterObjectLoadCell       `json:"load_cell"`
+	// dynamic sensor attributes populated using custom unmarsaling
+	// from the objects listed in `customObjectTypes`
+	TemperatureSensors map[string]PrinterObjectTemperatureSensor
+	TemperatureFans    map[string]PrinterObjectTemperatureFan
+	OutputPins         map[string]PrinterObjectOutputPin
+	FilamentMotion     map[string]PrinterObjectFilamentMotionSensor
+	FilamentSwitch     map[string]PrinterObjectFilamentSwitchSensor
+	Mcus               map[string]PrinterObjectMcu
+	GcodeButtons       map[string]PrinterObjectGcodeButton
+	TemperatureProbes  map[string]PrinterObjectTemperatureProbe
+	EddyProbes         map[string]PrinterObjectEddyProbe
+	Servos             map[string]PrinterObjectServo
+	HeaterGenerics     map[string]PrinterObjectHeaterGeneric
+	Angles             map[string]PrinterObjectAngle
+	LoadCells          map[string]PrinterObjectLoadCell
+	CanbusStats        map[string]PrinterObjectCanbusStats
+	// FailedObjects are the names of the objects that could not be decoded
+	FailedObjects []string `json:"-"`
+}
+
+// PrinterObjectMcu is the status of the primary `mcu` object, and of each
+// additional `mcu <name>` object, e.g. a CAN toolhead board.
+type PrinterObjectMcu struct {
+	McuVersion string `json:"mcu_version" mapstructure:"mcu_version"`
+	LastStats  struct {
+		McuAwake        float64 `json:"mcu_awake" mapstructure:"mcu_awake"`
+		McuTaskAvg      float64 `json:"mcu_task_avg" mapstructure:"mcu_task_avg"`
+		McuTaskStddev   float64 `json:"mcu_task_stddev" mapstructure:"mcu_task_stddev"`
+		BytesWrite      float64 `json:"bytes_write" mapstructure:"bytes_write"`
+		BytesRead       float64 `json:"bytes_read" mapstructure:"bytes_read"`
+		BytesRetransmit float64 `json:"bytes_retransmit" mapstructure:"bytes_retransmit"`
+		BytesInvalid    float64 `json:"bytes_invalid" mapstructure:"bytes_invalid"`
+		SendSeq         float64 `json:"send_seq" mapstructure:"send_seq"`
+		ReceiveSeq      float64 `json:"receive_seq" mapstructure:"receive_seq"`
+		RetransmitSeq   float64 `json:"retransmit_seq" mapstructure:"retransmit_seq"`
+		Srtt            float64 `json:"srtt" mapstructure:"srtt"`
+		Rttvar          float64 `json:"rttvar" mapstructure:"rttvar"`
+		Rto             float64 `json:"rto" mapstructure:"rto"`
+		ReadyBytes      float64 `json:"ready_bytes" mapstructure:"ready_bytes"`
+		StalledBytes    float64 `json:"stalled_bytes" mapstructure:"stalled_bytes"`
+		Freq            float64 `json:"freq" mapstructure:"freq"`
+		Adj             float64 `json:"adj" mapstructure:"adj"`
+	} `json:"last_stats" mapstructure:"last_stats"`
+}
+
+type PrinterObjectGcodeMove struct {
+	SpeedFactor   float64   `json:"speed_factor"`
+	Speed         float64   `json:"speed"`
+	ExtrudeFactor float64   `json:"extrude_factor"`
+	GcodePosition []float64 `json:"gcode_position"`
+}
+
+type PrinterObjectToolhead struct {
+	PrintTime            float64   `json:"print_time"`
+	EstimatedPrintTime   float64   `json:"estimated_print_time"`
+	MaxVelocity          float64   `json:"max_velocity"`
+	MaxAccel             float64   `json:"max_accel"`
+	MaxAccelToDecel      float64   `json:"max_accel_to_decel"`
+	SquareCornerVelocity float64   `json:"square_corner_velocity"`
+	Position             []float64 `json:"position"`
+}
+
+type PrinterObjectMotionReport struct {
+	LiveVelocity         float64 `json:"live_velocity"`
+	LiveExtruderVelocity float64 `json:"live_extruder_velocity"`
+}
+
+type PrinterObjectExtruder struct {
+	Temperature     float64 `json:"temperature"`
+	Target          float64 `json:"target"`
+	Power           float64 `json:"power"`
+	PressureAdvance float64 `json:"pressure_advance"`
+	SmoothTime      float64 `json:"smooth_time"`
+}
+
+type PrinterObjectHeaterBed struct {
+	Temperature float64 `json:"temperature"`
+	Target      float64 `json:"target"`
+	Power       float64 `json:"power"`
+}
+
+type PrinterObjectFan struct {
+	Speed float64 `json:"speed"`
+	Rpm   float64 `json:"rpm"`
+}
+
+type PrinterObjectIdleTimeout struct {
+	State        string  `json:"state"`
+	PrintingTime float64 `json:"printing_time"`
+}
+
+type PrinterObjectVirtualSdCard struct {
+	Progress     float64 `json:"progress"`
+	IsActive     bool    `json:"is_active"`
+	FilePosition float64 `json:"file_position"`
+}
+
+type PrinterObjectPrintStats struct {
+	State         string  `json:"state"`
+	Filename      string  `json:"filename"`
+	TotalDuration float64 `json:"total_duration"`
+	PrintDuration float64 `json:"print_duration"`
+	FilamentUsed  float64 `json:"filament_used"`
+	Message       string  `json:"message"`
+}
+
+type PrinterObjectDisplayStatus struct {
+	Progress float64 `json:"progress"`
+}
+
+type PrinterObjectExcludeObject struct {
+	Objects []struct {
+		Name string `json:"name"`
+	} `json:"objects"`
+	ExcludedObjects []string `json:"excluded_objects"`
+	CurrentObject   string   `json:"current_object"`
+}
+
+type PrinterObjectZThermalAdjust struct {
+	Temperature           float64 `json:"temperature"`
+	CurrentZAdjust        float64 `json:"current_z_adjust"`
+	ZAdjustRefTemperature float64 `json:"z_adjust_ref_temperature"`
+	Enabled               bool    `json:"enabled"`
+}
+
+type PrinterObjectBedMesh struct {
+	ProfileName  string      `json:"profile_name"`
+	ProbedMatrix [][]float64 `json:"probed_matrix"`
+}
+
+type PrinterObjectTemperatureSensor struct {
+	Temperature     float64 `mapstructure:"temperature"`
+	MeasuredMinTemp float64 `mapstructure:"measured_min_temp"`
+	MeasuredMaxTemp float64 `mapstructure:"measured_max_temp"`
+}
+
+type PrinterObjectTemperatureFan struct {
+	Speed       float64 `mapstructure:"speed"`
+	Rpm         float64 `mapstructure:"rpm"`
+	Temperature float64 `mapstructure:"temperature"`
+	Target      float64 `mapstructure:"target"`
+}
+
+type PrinterObjectOutputPin struct {
+	Value float64 `mapstructure:"value"`
+}
+
+type PrinterObjectFilamentMotionSensor struct {
+	FilamentDetected bool `mapstructure:"filament_detected"`
+	Enabled          bool `mapstructure:"enabled"`
+}
+
+type PrinterObjectFilamentSwitchSensor struct {
+	FilamentDetected bool `mapstructure:"filament_detected"`
+	Enabled          bool `mapstructure:"enabled"`
+}
+
+type PrinterObjectGcodeButton struct {
+	State string `mapstructure:"state"`
+}
+
+// PrinterObjectTemperatureProbe is the status of a `temperature_probe <name>`
+// object, the coil temperature used to compensate the thermal drift of an eddy
+// current probe.
+type PrinterObjectTemperatureProbe struct {
+	Temperature         float64 `mapstructure:"temperature"`
+	EstimatedExpansion  float64 `mapstructure:"estimated_expansion"`
+	CompensationEnabled bool    `mapstructure:"compensation_enabled"`
+}
+
+// PrinterObjectEddyProbe is the status of a `probe_eddy_current <name>`
+// object, e.g. a BTT Eddy.
+type PrinterObjectEddyProbe struct {
+	LastZResult float64 `mapstructure:"last_z_result"`
+}
+
+// PrinterObjectBeacon is the status of a Beacon probe. The sample values are
+// null until the probe has reported a sample, or while it is out of range.
+type PrinterObjectBeacon struct {
+	LastSample *struct {
+		Temp *float64 `json:"temp"`
+		Dist *float64 `json:"dist"`
+		Freq *float64 `json:"freq"`
+	} `json:"last_sample"`
+	LastZResult *float64 `json:"last_z_result"`
+}
+
+// PrinterObjectHeaterGeneric is the status of a `heater_generic <name>`
+// object, e.g. a chamber heater.
+type PrinterObjectHeaterGeneric struct {
+	Temperature float64 `mapstructure:"temperature"`
+	Target      float64 `mapstructure:"target"`
+	Power       float64 `mapstructure:"power"`
+}
+
+// PrinterObjectServo is the status of a `servo <name>` object.
+type PrinterObjectServo struct {
+	Value float64 `mapstructure:"value"`
+}
+
+// PrinterObjectAngle is the status of an `angle <name>` magnetic encoder
+// object. The temperature is only reported by sensors with a temperature
+// sensor, e.g. the tle5012b.
+type PrinterObjectAngle struct {
+	Temperature *float64 `mapstructure:"temperature"`
+}
+
+// PrinterObjectCanbusStats is the status of a `canbus_stats <mcu>` object,
+// available for each mcu connected over CAN bus since Klipper v0.12.0.
+type PrinterObjectCanbusStats struct {
+	RxError   *float64 `mapstructure:"rx_error"`
+	TxError   *float64 `mapstructure:"tx_error"`
+	TxRetries *float64 `mapstructure:"tx_retries"`
+	BusState  string   `mapstructure:"bus_state"`
+}
+
+// PrinterObjectLoadCell is the status of a `load_cell` or `load_cell <name>`
+// object, e.g. a load cell probe or a spool scale. The force is only reported
+// once the load cell is calibrated.
+type PrinterObjectLoadCell struct {
+	IsCalibrated bool     `json:"is_calibrated" mapstructure:"is_calibrated"`
+	ForceG       *float64 `json:"force_g" mapstructure:"force_g"`
+}
+
+// UnmarshalJSON decodes each printer object separately, so an object that
+// cannot be decoded, e.g. while Klippy is still loading, does not prevent the
+// other objects from being reported. Objects that are missing from the status
+// are left unset, and the objects that fail to decode are listed in
+// FailedObjects.
+func (f *PrinterObjectStatus) UnmarshalJSON(bs []byte) (err error) {
+	objects := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(bs, &objects); err != nil {
+		return err
+	}
+	status := reflect.ValueOf(f).Elem()
+	for i := 0; i < status.NumField(); i++ {
+		object := tagName(status.Type().Field(i).Tag.Get("json"))
+		raw, ok := objects[object]
+		if object == "" || !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, status.Field(i).Addr().Interface()); err != nil {
+			log.Warnf("Unable to decode printer object %s: %v", object, err)
+			f.FailedObjects = append(f.FailedObjects, object)
+		}
+	}
+
+	m := make(map[string]interface{})
+
+	if err = json.Unmarshal(bs, &m); err == nil {
+		// find `temperature_sensor` `temperature_fan` `output_pin`
+		// `filament_motion_sensor` and `filament_switch_sensor` items and store
+		// in a map keyed by sensor name, `gcode_button` items keyed by button
+		// name, additional `mcu <name>` items keyed by mcu name, and
+		// `temperature_probe` and `probe_eddy_current` items keyed by probe
+		// name, `servo` items keyed by servo name, `heater_generic` items
+		// keyed by heater name, `angle` and `load_cell` items keyed by
+		// sensor name, and `canbus_stats` items keyed by mcu name
+		temperatureSensors := make(map[string]PrinterObjectTemperatureSensor)
+		temperatureFans := make(map[string]PrinterObjectTemperatureFan)
+		outputPins := make(map[string]PrinterObjectOutputPin)
+		filamentMotion := make(map[string]PrinterObjectFilamentMotionSensor)
+		filamentSwitch := make(map[string]PrinterObjectFilamentSwitchSensor)
+		mcus := make(map[string]PrinterObjectMcu)
+		gcodeButtons := make(map[string]PrinterObjectGcodeButton)
+		temperatureProbes := make(map[string]PrinterObjectTemperatureProbe)
+		eddyProbes := make(map[string]PrinterObjectEddyProbe)
+		servos := make(map[string]PrinterObjectServo)
+		heaterGenerics := make(map[string]PrinterObjectHeaterGeneric)
+		angles := make(map[string]PrinterObjectAngle)
+		loadCells := make(map[string]PrinterObjectLoadCell)
+		canbusStats := make(map[string]PrinterObjectCanbusStats)
+		for k, v := range m {
+			if strings.HasPrefix(k, "temperature_sensor") {
+				key := strings.Replace(k, "temperature_sensor ", "", 1)
+				value := PrinterObjectTemperatureSensor{}
+				f.decodeCustomObject(k, v, &value)
+				temperatureSensors[key] = value
+			}
+			if strings.HasPrefix(k, "temperature_fan") {
+				key := strings.Replace(k, "temperature_fan ", "", 1)
+				value := PrinterObjectTemperatureFan{}
+				f.decodeCustomObject(k, v, &value)
+				temperatureFans[key] = value
+			}
+			if strings.HasPrefix(k, "output_pin") {
+				key := strings.Replace(k, "output_pin ", "", 1)
+				value := PrinterObjectOutputPin{}
+				f.decodeCustomObject(k, v, &value)
+				outputPins[key] = value
+			}
+			if strings.HasPrefix(k, "filament_motion_sensor") {
+				key := strings.Replace(k, "filament_motion_sensor ", "", 1)
+				value := PrinterObjectFilamentMotionSensor{}
+				f.decodeCustomObject(k, v, &value)
+				filamentMotion[key] = value
+			}
+			if strings.HasPrefix(k, "filament_switch_sensor") {
+				key := strings.Replace(k, "filament_switch_sensor ", "", 1)
+				value := PrinterObjectFilamentSwitchSensor{}
+				f.decodeCustomObject(k, v, &value)
+				filamentSwitch[key] = value
+			}
+			if strings.HasPrefix(k, "mcu ") {
+				key := strings.Replace(k, "mcu ", "", 1)
+				value := PrinterObjectMcu{}
+				f.decodeCustomObject(k, v, &value)
+				mcus[key] = value
+			}
+			if strings.HasPrefix(k, "gcode_button") {
+				key := strings.Replace(k, "gcode_button ", "", 1)
+				value := PrinterObjectGcodeButton{}
+				f.decodeCustomObject(k, v, &value)
+				gcodeButtons[key] = value
+			}
+			if strings.HasPrefix(k, "temperature_probe ") {
+				key := strings.Replace(k, "temperature_probe ", "", 1)
+				value := PrinterObjectTemperatureProbe{}
+				f.decodeCustomObject(k, v, &value)
+				temperatureProbes[key] = value
+			}
+			if strings.HasPrefix(k, "probe_eddy_current ") {
+				key := strings.Replace(k, "probe_eddy_current ", "", 1)
+				value := PrinterObjectEddyProbe{}
+				f.decodeCustomObject(k, v, &value)
+				eddyProbes[key] = value
+			}
+			if strings.HasPrefix(k, "servo ") {
+				key := strings.Replace(k, "servo ", "", 1)
+				value := PrinterObjectServo{}
+				f.decodeCustomObject(k, v, &value)
+				servos[key] = value
+			}
+			if strings.HasPrefix(k, "heater_generic ") {
+				key := strings.Replace(k, "heater_generic ", "", 1)
+				value := PrinterObjectHeaterGeneric{}
+				f.decodeCustomObject(k, v, &value)
+				heaterGenerics[key] = value
+			}
+			if strings.HasPrefix(k, "angle ") {
+				key := strings.Replace(k, "angle ", "", 1)
+				value := PrinterObjectAngle{}
+				f.decodeCustomObject(k, v, &value)
+				angles[key] = value
+			}
+			if strings.HasPrefix(k, "load_cell ") {
+				key := strings.Replace(k, "load_cell ", "", 1)
+				value := PrinterObjectLoadCell{}
+				f.decodeCustomObject(k, v, &value)
+				loadCells[key] = value
+			}
+			if strings.HasPrefix(k, "canbus_stats ") {
+				key := strings.Replace(k, "canbus_stats ", "", 1)
+				value := PrinterObjectCanbusStats{}
+				f.decodeCustomObject(k, v, &value)
+				canbusStats[key] = value
+			}
+		}
+		f.TemperatureSensors = temperatureSensors
+		f.TemperatureFans = temperatureFans
+		f.OutputPins = outputPins
+		f.FilamentMotion = filamentMotion
+		f.FilamentSwitch = filamentSwitch
+		f.Mcus = mcus
+		f.GcodeButtons = gcodeButtons
+		f.TemperatureProbes = temperatureProbes
+		f.EddyProbes = eddyProbes
+		f.Servos = servos
+		f.HeaterGenerics = heaterGenerics
+		f.Angles = angles
+		f.LoadCells = loadCells
+		f.CanbusStats = canbusStats
+	}
+	return err
+}
+
+// decodeCustomObject decodes the status of a custom object, recording the
+// object in FailedObjects if it cannot be decoded.
+func (f *PrinterObjectStatus) decodeCustomObject(object string, status interface{}, value interface{}) {
+	if err := mapstructure.Decode(status, value); err != nil {
+		log.Warnf("Unable to decode printer object %s: %v", object, err)
+		f.FailedObjects = append(f.FailedObjects, object)
+	}
+}
+
+// customObjectType is a printer object type that is configured with a custom
+// name, e.g. `temperature_sensor chamber`, and the struct its status is
+// decoded into.
+type customObjectType struct {
+	name   string
+	status interface{}
+}
+
+var customObjectTypes = []customObjectType{
+	{"temperature_sensor", PrinterObjectTemperatureSensor{}},
+	{"temperature_fan", PrinterObjectTemperatureFan{}},
+	{"output_pin", PrinterObjectOutputPin{}},
+	{"filament_motion_sensor", PrinterObjectFilamentMotionSensor{}},
+	{"filament_switch_sensor", PrinterObjectFilamentSwitchSensor{}},
+	{"mcu", PrinterObjectMcu{}},
+	{"gcode_button", PrinterObjectGcodeButton{}},
+	{"temperature_probe", PrinterObjectTemperatureProbe{}},
+	{"probe_eddy_current", PrinterObjectEddyProbe{}},
+	{"servo", PrinterObjectServo{}},
+	{"heater_generic", PrinterObjectHeaterGeneric{}},
+	{"angle", PrinterObjectAngle{}},
+	{"load_cell", PrinterObjectLoadCell{}},
+	{"canbus_stats", PrinterObjectCanbusStats{}},
+}
+
+// CustomObjects returns the names of the `customObjectTypes` objects in the
+// list of printer objects, keyed by object type.
+func (c *Client) CustomObjects() (map[string][]string, error) {
+	response, err := c.PrinterObjectsList()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]string)
+	for _, objectType := range customObjectTypes {
+		objects[objectType.name] = []string{}
+	}
+	for _, object := range response.Result.Objects {
+		for _, objectType := range customObjectTypes {
+			if strings.HasPrefix(object, objectType.name+" ") {
+				objects[objectType.name] = append(objects[objectType.name], strings.Replace(object, objectType.name+" ", "", 1))
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// PrinterObjects queries the status of the objects in PrinterObjectStatus and
+// of the custom objects, keyed by object type, see CustomObjects.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#query-printer-object-status
+func (c *Client) PrinterObjects(customObjects map[string][]string) (*PrinterObjectResponse, error) {
+	var response PrinterObjectResponse
+	err := c.requester.Get("/printer/objects/query?"+printerObjectsQuery(customObjects), &response)
+	if err != nil {
+		return nil, err
+	}
+	log.Tracef("%+v", response)
+	return &response, nil
+}
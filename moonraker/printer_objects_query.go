@@ -0,0 +1,62 @@
+package moonraker
+
+import (
+	"reflect"
+	"strings"
+)
+
+// printerObjectsQuery builds the printer objects query string for all of the
+// objects in PrinterObjectStatus and the discovered custom objects, keyed by
+// object type.
+//
+// Moonraker returns every attribute of an object unless the attributes are
+// listed, e.g. `extruder=temperature,target`, so the attribute filter for each
+// object is built from the tags of the struct fields the object is decoded
+// into. Adding a field to one of the object structs automatically requests the
+// attribute, and attributes that are not exported are never fetched.
+func printerObjectsQuery(customObjects map[string][]string) string {
+	query := []string{}
+
+	statusType := reflect.TypeOf(PrinterObjectStatus{})
+	for i := 0; i < statusType.NumField(); i++ {
+		field := statusType.Field(i)
+		object := tagName(field.Tag.Get("json"))
+		if object == "" {
+			continue
+		}
+		query = append(query, object+"="+strings.Join(printerObjectAttributes(field.Type, "json"), ","))
+	}
+
+	for _, objectType := range customObjectTypes {
+		attributes := strings.Join(printerObjectAttributes(reflect.TypeOf(objectType.status), "mapstructure"), ",")
+		for _, name := range customObjects[objectType.name] {
+			query = append(query, objectType.name+"%20"+name+"="+attributes)
+		}
+	}
+
+	return strings.Join(query, "&")
+}
+
+// printerObjectAttributes returns the attribute names from the struct tags of
+// the fields of the printer object type t.
+func printerObjectAttributes(t reflect.Type, tag string) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	attributes := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if attribute := tagName(t.Field(i).Tag.Get(tag)); attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// tagName returns the name from a struct tag value, ignoring any options.
+func tagName(tag string) string {
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,190 @@
+package moonraker
+
+import (
+	"net/url"
+	"strconv"
+)
+
+type ServerInfoResponse struct {
+	Result struct {
+		KlippyConnected  bool     `json:"klippy_connected"`
+		KlippyState      string   `json:"klippy_state"`
+		MoonrakerVersion string   `json:"moonraker_version"`
+		APIVersionString string   `json:"api_version_string"`
+		Components       []string `json:"components"`
+		FailedComponents []string `json:"failed_components"`
+		Warnings         []string `json:"warnings"`
+	} `json:"result"`
+}
+
+// ServerConfigResponse is the Moonraker configuration, of which only
+// the data store settings are used.
+type ServerConfigResponse struct {
+	Result struct {
+		Config struct {
+			DataStore struct {
+				TemperatureStoreSize int `json:"temperature_store_size"`
+			} `json:"data_store"`
+		} `json:"config"`
+	} `json:"result"`
+}
+
+type ExtensionsResponse struct {
+	Result struct {
+		Agents []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Type    string `json:"type"`
+			URL     string `json:"url"`
+		} `json:"agents"`
+	} `json:"result"`
+}
+
+type AnnouncementsResponse struct {
+	Result struct {
+		Entries []struct {
+			EntryID   string `json:"entry_id"`
+			Title     string `json:"title"`
+			Priority  string `json:"priority"`
+			Dismissed bool   `json:"dismissed"`
+			Feed      string `json:"feed"`
+		} `json:"entries"`
+		Feeds []string `json:"feeds"`
+	} `json:"result"`
+}
+
+type WebcamsResponse struct {
+	Result struct {
+		Webcams []struct {
+			Name        string `json:"name"`
+			Location    string `json:"location"`
+			Service     string `json:"service"`
+			Enabled     bool   `json:"enabled"`
+			StreamURL   string `json:"stream_url"`
+			SnapshotURL string `json:"snapshot_url"`
+			Source      string `json:"source"`
+		} `json:"webcams"`
+	} `json:"result"`
+}
+
+type TemperatureDataQueryResponse struct {
+	Result map[string]interface{} `json:"result"`
+}
+
+type GcodeStoreResponse struct {
+	Result struct {
+		GcodeStore []GcodeStoreEntry `json:"gcode_store"`
+	} `json:"result"`
+}
+
+type GcodeStoreEntry struct {
+	Message string  `json:"message"`
+	Time    float64 `json:"time"`
+	Type    string  `json:"type"`
+}
+
+type DatabaseItemResponse struct {
+	Result struct {
+		Namespace string      `json:"namespace"`
+		Key       string      `json:"key"`
+		Value     interface{} `json:"value"`
+	} `json:"result"`
+}
+
+// ServerInfo returns the Moonraker version, the loaded components, and the
+// Klippy state.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#query-server-info
+func (c *Client) ServerInfo() (*ServerInfoResponse, error) {
+	var response ServerInfoResponse
+	err := c.requester.Get("/server/info", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ServerConfig returns the Moonraker configuration.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-server-configuration
+func (c *Client) ServerConfig() (*ServerConfigResponse, error) {
+	var response ServerConfigResponse
+	err := c.requester.Get("/server/config", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Extensions returns the agents connected to Moonraker.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-extensions
+func (c *Client) Extensions() (*ExtensionsResponse, error) {
+	var response ExtensionsResponse
+	err := c.requester.Get("/server/extensions/list", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Announcements returns the announcements of the subscribed feeds, including
+// the dismissed announcements.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-announcements
+func (c *Client) Announcements() (*AnnouncementsResponse, error) {
+	var response AnnouncementsResponse
+	err := c.requester.Get("/server/announcements/list?include_dismissed=true", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Webcams returns the webcams configured in Moonraker.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#list-webcams
+func (c *Client) Webcams() (*WebcamsResponse, error) {
+	var response WebcamsResponse
+	err := c.requester.Get("/server/webcams/list", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// TemperatureStore returns the cached temperatures of the sensors and heaters.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#request-cached-temperature-data
+func (c *Client) TemperatureStore() (*TemperatureDataQueryResponse, error) {
+	var response TemperatureDataQueryResponse
+	err := c.requester.Get("/server/temperature_store", &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GcodeStore returns the most recent count gcode commands and responses.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#request-cached-gcode-responses
+func (c *Client) GcodeStore(count int) (*GcodeStoreResponse, error) {
+	var response GcodeStoreResponse
+	err := c.requester.Get("/server/gcode_store?count="+strconv.Itoa(count), &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DatabaseItem returns the item of the key in the database namespace.
+//
+// https://moonraker.readthedocs.io/en/latest/web_api/#get-database-item
+func (c *Client) DatabaseItem(namespace string, key string) (*DatabaseItemResponse, error) {
+	var response DatabaseItemResponse
+	err := c.requester.Get("/server/database/item?namespace="+url.QueryEscape(namespace)+"&key="+url.QueryEscape(key), &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,66 @@
+package moonraker
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultPort is used for targets that are not given as a URL and do not
+// include a port.
+const defaultPort = "7125"
+
+// Target builds the URLs of the Moonraker API requests for a target.
+type Target struct {
+	baseURL url.URL
+}
+
+// ParseTarget parses the target, which can be a host and optional port, e.g.
+// `klipper.local` or `klipper.local:7125`, or a URL including the scheme and
+// an optional base path, e.g. `http://klipper.local:7125/` or
+// `https://printers.example.com/voron/`. Hosts without a scheme default to
+// http and port 7125, URLs default to the standard port of the scheme.
+func ParseTarget(target string) (*Target, error) {
+	raw := strings.TrimSpace(target)
+	schemeless := !strings.Contains(raw, "://")
+	if schemeless {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target '%s': %v", target, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid target '%s': scheme must be http or https", target)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid target '%s': no host", target)
+	}
+	if schemeless && u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	u.Path = strings.TrimRight(path.Clean("/"+u.Path), "/")
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return &Target{baseURL: *u}, nil
+}
+
+// URL returns the URL of the API path, e.g. `/server/info`, which can include
+// a query string.
+func (t *Target) URL(apiPath string) string {
+	return t.baseURL.String() + "/" + strings.TrimLeft(apiPath, "/")
+}
+
+// WebsocketURL returns the URL of the Moonraker websocket, e.g.
+// `ws://klipper.local:7125/websocket`.
+func (t *Target) WebsocketURL() string {
+	u := t.baseURL
+	u.Scheme = "ws"
+	if t.baseURL.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+	return u.String() + "/websocket"
+}
@@ -0,0 +1,87 @@
+package moonraker
+
+import "testing"
+
+func TestTargetURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		apiPath string
+		want    string
+	}{
+		{"host", "klipper.local", "/server/info", "http://klipper.local:7125/server/info"},
+		{"host with spaces", " klipper.local ", "/server/info", "http://klipper.local:7125/server/info"},
+		{"host and port", "klipper.local:7126", "/server/info", "http://klipper.local:7126/server/info"},
+		{"ip address", "192.168.1.10", "/server/info", "http://192.168.1.10:7125/server/info"},
+		{"http url", "http://klipper.local", "/server/info", "http://klipper.local/server/info"},
+		{"http url with trailing slash", "http://klipper.local/", "/server/info", "http://klipper.local/server/info"},
+		{"http url with port", "http://klipper.local:7125/", "/server/info", "http://klipper.local:7125/server/info"},
+		{"https url with port", "https://klipper.local:443/", "/server/info", "https://klipper.local:443/server/info"},
+		{"ipv6", "[::1]", "/server/info", "http://[::1]:7125/server/info"},
+		{"ipv6 and port", "[::1]:7126", "/server/info", "http://[::1]:7126/server/info"},
+		{"ipv6 url", "http://[::1]/", "/server/info", "http://[::1]/server/info"},
+		{"base path", "https://printers.example.com/voron", "/server/info", "https://printers.example.com/voron/server/info"},
+		{"base path with trailing slash", "https://printers.example.com/voron/", "/server/info", "https://printers.example.com/voron/server/info"},
+		{"base path with query", "https://printers.example.com/voron/?printer=1#status", "/server/info", "https://printers.example.com/voron/server/info"},
+		{"api path with query", "klipper.local", "/printer/objects/query?print_stats=state,filename", "http://klipper.local:7125/printer/objects/query?print_stats=state,filename"},
+		{"api path without leading slash", "klipper.local", "server/info", "http://klipper.local:7125/server/info"},
+		{"double slash in base path", "http://klipper.local//voron//", "/server/info", "http://klipper.local/voron/server/info"},
+		{"double slash in api path", "http://klipper.local/", "//server/info", "http://klipper.local/server/info"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ParseTarget(tt.target)
+			if err != nil {
+				t.Fatalf("ParseTarget(%q) error: %v", tt.target, err)
+			}
+			if got := target.URL(tt.apiPath); got != tt.want {
+				t.Errorf("ParseTarget(%q).URL(%q) = %q, want %q", tt.target, tt.apiPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetWebsocketURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"host", "klipper.local", "ws://klipper.local:7125/websocket"},
+		{"http url", "http://klipper.local/", "ws://klipper.local/websocket"},
+		{"https url with port", "https://klipper.local:443/", "wss://klipper.local:443/websocket"},
+		{"base path", "https://printers.example.com/voron/", "wss://printers.example.com/voron/websocket"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ParseTarget(tt.target)
+			if err != nil {
+				t.Fatalf("ParseTarget(%q) error: %v", tt.target, err)
+			}
+			if got := target.WebsocketURL(); got != tt.want {
+				t.Errorf("ParseTarget(%q).WebsocketURL() = %q, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargetInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"empty", ""},
+		{"unsupported scheme", "ftp://klipper.local"},
+		{"websocket scheme", "ws://klipper.local:7125/websocket"},
+		{"no host", "http://"},
+		{"only port", ":7125"},
+		{"invalid port", "klipper.local:port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if target, err := ParseTarget(tt.target); err == nil {
+				t.Errorf("ParseTarget(%q) = %v, want error", tt.target, target.baseURL.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector/modules"
+)
+
+// pausedTargets are the targets that are not collected until the time they
+// are resumed, e.g. while the MCU firmware is flashed.
+var pausedTargets = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+// pauseTarget stops collecting the target for the ttl.
+func pauseTarget(target string, ttl time.Duration) time.Time {
+	pausedTargets.Lock()
+	defer pausedTargets.Unlock()
+	until := time.Now().Add(ttl)
+	pausedTargets.until[target] = until
+	log.Infof("Paused collection of %s until %s", target, until.Format(time.RFC3339))
+	return until
+}
+
+// resumeTarget collects the target again, returning false if it was not paused.
+func resumeTarget(target string) bool {
+	pausedTargets.Lock()
+	defer pausedTargets.Unlock()
+	if _, ok := pausedTargets.until[target]; !ok {
+		return false
+	}
+	delete(pausedTargets.until, target)
+	log.Infof("Resumed collection of %s", target)
+	return true
+}
+
+// targetPaused returns the time the target is paused until, and true if it is
+// paused. Targets are resumed automatically once the pause has expired.
+func targetPaused(target string) (time.Time, bool) {
+	pausedTargets.Lock()
+	defer pausedTargets.Unlock()
+	until, ok := pausedTargets.until[target]
+	if ok && !time.Now().Before(until) {
+		delete(pausedTargets.until, target)
+		log.Infof("Pause of %s expired, resuming collection", target)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+var (
+	targetPausedDesc      = prometheus.NewDesc("klipper_target_paused", "Set to 1 while the collection of the target is paused.", nil, nil)
+	targetPauseExpiryDesc = prometheus.NewDesc("klipper_target_pause_expiry_timestamp_seconds", "Unix timestamp the collection of the paused target is resumed.", nil, nil)
+)
+
+// pausedCollector is collected instead of the target while it is paused, so
+// the scrape succeeds without any requests being sent to the printer, and
+// alerts can be silenced on the paused metric.
+type pausedCollector struct {
+	until time.Time
+}
+
+func (p pausedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetPausedDesc
+	ch <- targetPauseExpiryDesc
+}
+
+func (p pausedCollector) Collect(ch chan<- prometheus.Metric) {
+	modules.SendConstMetric(ch, targetPausedDesc, prometheus.GaugeValue, 1)
+	modules.SendConstMetric(ch, targetPauseExpiryDesc, prometheus.GaugeValue, float64(p.until.Unix()))
+}
+
+// pausedTargetsCollector reports the paused targets on the exporter's own
+// `/metrics` endpoint.
+type pausedTargetsCollector struct{}
+
+var exporterTargetPausedDesc = prometheus.NewDesc("klipper_exporter_target_paused_until_timestamp_seconds", "Unix timestamp the collection of each paused target is resumed.", []string{"target"}, nil)
+
+func (pausedTargetsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- exporterTargetPausedDesc
+}
+
+func (pausedTargetsCollector) Collect(ch chan<- prometheus.Metric) {
+	pausedTargets.Lock()
+	targets := make(map[string]time.Time, len(pausedTargets.until))
+	for target, until := range pausedTargets.until {
+		targets[target] = until
+	}
+	pausedTargets.Unlock()
+	for target, until := range targets {
+		// expired pauses are removed on the next collection of the target
+		if time.Now().Before(until) {
+			modules.SendConstMetric(ch, exporterTargetPausedDesc, prometheus.GaugeValue, float64(until.Unix()), target)
+		}
+	}
+}
+
+func init() {
+	prometheus.MustRegister(pausedTargetsCollector{})
+}
+
+// resolveTarget returns the target address of a configured printer name, or
+// the name itself if it is not a configured printer.
+func resolveTarget(name string) string {
+	for _, t := range currentConfig().Targets {
+		if t.Printer != "" && t.Printer == name {
+			return t.Target
+		}
+	}
+	return name
+}
+
+// targetsHandler pauses or resumes the collection of a target, e.g.
+// `curl -X POST -H "Authorization: Bearer $TOKEN" http://localhost:9101/targets/klipper.local:7125/pause?ttl=30m`
+// The target is the address or the configured printer name. Requests must
+// include the --web.admin-token as a bearer token.
+func targetsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizedAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "targets must be paused or resumed using POST", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/targets/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	target := resolveTarget(path[:i])
+	switch path[i+1:] {
+	case "pause":
+		ttl := pauseTTL
+		if value := r.URL.Query().Get("ttl"); value != "" {
+			var err error
+			if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+				http.Error(w, fmt.Sprintf("invalid 'ttl' parameter '%s'", value), http.StatusBadRequest)
+				return
+			}
+		}
+		until := pauseTarget(target, ttl)
+		fmt.Fprintf(w, "Paused collection of %s until %s\n", target, until.Format(time.RFC3339))
+	case "resume":
+		if !resumeTarget(target) {
+			http.Error(w, fmt.Sprintf("target %s is not paused", target), http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "Resumed collection of %s\n", target)
+	default:
+		http.NotFound(w, r)
+	}
+}
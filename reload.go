@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var (
+	configHash = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "klipper_exporter_config_hash",
+		Help: "Hash of the loaded configuration file.",
+	})
+	configReloadSuccessful = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "klipper_exporter_config_last_reload_successful",
+		Help: "Whether the last configuration file reload attempt was successful.",
+	})
+	configReloadSuccessTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "klipper_exporter_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful configuration file reload.",
+	})
+)
+
+var (
+	configMutex sync.RWMutex
+	// loadedConfig is the configuration file loaded by the serve command
+	loadedConfig = &Config{}
+	// maintenance is created when maintenance tasks are configured
+	maintenance *collector.Maintenance
+)
+
+func currentConfig() *Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return loadedConfig
+}
+
+func currentMaintenance() *collector.Maintenance {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return maintenance
+}
+
+// hashAsMetricValue returns the first 6 bytes of the md5 hash of the data as
+// a float64, which can be represented exactly, the same as Alertmanager.
+func hashAsMetricValue(data []byte) float64 {
+	sum := md5.Sum(data)
+	bytes := make([]byte, 8)
+	copy(bytes, sum[:6])
+	return float64(binary.LittleEndian.Uint64(bytes))
+}
+
+// reloadConfig loads the configuration file. If the file is invalid the
+// previously loaded configuration is kept.
+func reloadConfig() error {
+	err := loadConfigFile()
+	if err != nil {
+		configReloadSuccessful.Set(0)
+		log.Errorf("Failed to load config file %s: %v", configFile, err)
+		return err
+	}
+	configReloadSuccessful.Set(1)
+	configReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+func loadConfigFile() error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	newConfig, err := parseConfig(configFile, data)
+	if err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if len(newConfig.Maintenance) > 0 {
+		if maintenance == nil {
+			if maintenance, err = collector.NewMaintenance(newConfig.Maintenance, maintenanceStateFile); err != nil {
+				return err
+			}
+		} else if err := maintenance.SetTasks(newConfig.Maintenance); err != nil {
+			return err
+		}
+	} else if maintenance != nil {
+		// the usage would be lost if the tasks are configured again
+		if err := maintenance.Flush(); err != nil {
+			return err
+		}
+		maintenance = nil
+	}
+	loadedConfig = newConfig
+	configHash.Set(hashAsMetricValue(data))
+	log.Infof("Loaded %d targets and %d maintenance tasks from %s", len(newConfig.Targets), len(newConfig.Maintenance), configFile)
+	return nil
+}
+
+// reloadOnSignal reloads the configuration file on SIGHUP.
+func reloadOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Info("Received SIGHUP, reloading config file")
+		reloadConfig()
+	}
+}
+
+// flushOnShutdown saves the maintenance usage that has not been saved yet when
+// the exporter is stopped with SIGINT or SIGTERM.
+func flushOnShutdown() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sig := <-stop
+	log.Infof("Received %s, stopping", sig)
+	if m := currentMaintenance(); m != nil {
+		if err := m.Flush(); err != nil {
+			os.Exit(1)
+		}
+	}
+	os.Exit(0)
+}
+
+// watchConfig reloads the configuration file when its contents change. Mounted
+// Kubernetes ConfigMaps and Secrets are updated by replacing the symlink to the
+// data directory rather than writing the file, so the contents are compared at
+// each interval instead of watching the file for writes.
+func watchConfig(interval time.Duration) {
+	var last [md5.Size]byte
+	if data, err := os.ReadFile(configFile); err == nil {
+		last = md5.Sum(data)
+	}
+	log.Infof("Watching config file %s for changes every %s", configFile, interval)
+	for range time.Tick(interval) {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			// the file can be briefly missing while the volume is updated
+			log.Debugf("Unable to read config file %s: %v", configFile, err)
+			continue
+		}
+		sum := md5.Sum(data)
+		if sum == last {
+			continue
+		}
+		last = sum
+		log.Infof("Config file %s changed, reloading", configFile)
+		reloadConfig()
+	}
+}
+
+// reloadHandler reloads the configuration file, e.g.
+// `curl -X POST -H 'Authorization: Bearer <token>' http://localhost:9101/-/reload`.
+// The --web.admin-token bearer token is required if set.
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if adminToken != "" && !authorizedAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "the config file must be reloaded using POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := reloadConfig(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "Reloaded config file")
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the exporter server (default)",
+	Long: `Start the exporter server. Klipper metrics are served from the /probe
+endpoint for the specified target, and metrics for the exporter itself are
+served from the /metrics endpoint.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	addServeFlags(serveCmd.Flags())
+	rootCmd.AddCommand(serveCmd)
+}
+
+func addServeFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&listenAddress, "web.listen-address", ":9101", "Address on which to expose metrics and web interface.")
+	flags.StringVar(&eventsURL, "events.url", "", "Publish print state change events to this NATS, nats://host:4222 or tls://host:4222, or Redis, redis://host:6379 or rediss://host:6379, server.")
+	flags.StringVar(&eventsTopic, "events.topic", "klipper.events", "NATS subject or Redis stream the print events are published to.")
+	flags.StringVar(&maintenanceStateFile, "maintenance.state-file", "", "File the maintenance task usage is saved to so it is kept across restarts.")
+	flags.StringVar(&filamentStateFile, "filament.state-file", "", "File the filament used per material is saved to so it is kept across restarts.")
+	flags.BoolVar(&enableOpenMetrics, "web.enable-openmetrics", false, "Serve the OpenMetrics format to scrapers that request it, e.g. for created timestamps.")
+	flags.BoolVar(&disableCompression, "web.disable-compression", false, "Do not gzip compress the metrics, even if the scraper accepts it.")
+	flags.DurationVar(&scrapeTimeoutOffset, "web.timeout-offset", 500*time.Millisecond, "Offset subtracted from the Prometheus scrape timeout to leave time to return the metrics collected before the deadline.")
+	flags.BoolVar(&enableLifecycle, "web.enable-lifecycle", false, "Enable reloading the configuration file with a POST request to the /-/reload endpoint, without authentication unless --web.admin-token is set.")
+	flags.BoolVar(&enableInflux, "web.enable-influx", false, "Serve the metrics of a target in the InfluxDB line protocol from the /influx endpoint, with the same parameters as /probe.")
+	flags.StringVar(&adminToken, "web.admin-token", "", "Bearer token required to pause and resume targets with the /targets/<target>/pause and /targets/<target>/resume endpoints, to reset maintenance tasks with the /maintenance/reset endpoint, and to reload the configuration file with the /-/reload endpoint. The endpoints are disabled if not set, unless --web.enable-lifecycle is set for /-/reload.")
+	flags.DurationVar(&pauseTTL, "web.pause-ttl", time.Hour, "How long a paused target is not collected for if the pause request has no 'ttl' parameter.")
+	flags.StringVar(&configFile, "config.file", "", "Configuration file listing the targets to collect on the /metrics endpoint.")
+	flags.DurationVar(&configWatchInterval, "config.watch-interval", 0, "Interval to check the configuration file for changes and reload it, e.g. when a mounted Kubernetes ConfigMap is updated. Disabled if 0.")
+	flags.DurationVar(&alertsInterval, "alerts.interval", 30*time.Second, "Interval the alert rules of the configuration file are evaluated at. Set to 0 to disable the alert evaluation.")
+	flags.IntVar(&configConcurrency, "config.concurrency", 4, "Maximum number of targets from the configuration file collected in parallel.")
+	flags.DurationVar(&configTimeout, "config.timeout", 10*time.Second, "Maximum time to collect the targets from the configuration file. Targets that have not completed are left out of the response.")
+}
+
+// metricsHandlerOpts returns the options of the /metrics and /probe handlers.
+// The exposition format is negotiated with the scraper from the Accept header,
+// the protobuf and text formats are always offered, and OpenMetrics only when
+// enabled.
+func metricsHandlerOpts() promhttp.HandlerOpts {
+	return promhttp.HandlerOpts{
+		EnableOpenMetrics:  enableOpenMetrics,
+		DisableCompression: disableCompression,
+	}
+}
+
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout of the job.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeTimeout returns the time the scraper waits for the response from the
+// X-Prometheus-Scrape-Timeout-Seconds header, less the --web.timeout-offset,
+// or 0 if the header is not set or invalid.
+func scrapeTimeout(r *http.Request) time.Duration {
+	header := r.Header.Get(scrapeTimeoutHeader)
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		log.Warnf("Ignoring invalid %s header '%s'", scrapeTimeoutHeader, header)
+		return 0
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - scrapeTimeoutOffset
+	if timeout <= 0 {
+		// leave at least some time to collect if the offset is too large
+		timeout = time.Duration(seconds * float64(time.Second) / 2)
+	}
+	return timeout
+}
+
+// scraperHost returns the host the scrape request is sent from, so scrapers
+// such as the two Prometheus servers of a highly available pair are told
+// apart.
+func scraperHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// apiKey returns the API key to authenticate with Moonraker. The key from the
+// prometheus.yml authorization header takes precedence over the command line
+// argument and environment variable.
+func apiKey(auth string) string {
+	if auth != "" && strings.HasPrefix(auth, "APIKEY") {
+		log.Debug("Using API key from prometheus.yml authorization configuration")
+		return strings.Replace(auth, "APIKEY ", "", 1)
+	} else if klipperApiKey != "" {
+		log.Debugf("Using API key from --moonraker.apikey (%s)", flagSources["moonraker.apikey"])
+		return klipperApiKey
+	} else if apiKey := os.Getenv("MOONRAKER_APIKEY"); apiKey != "" {
+		log.Debug("Using API key from MOONRAKER_APIKEY environment variable")
+		return apiKey
+	}
+	log.Debug("API key not set")
+	return ""
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	// stop collecting before the scraper gives up so the metrics collected
+	// so far are returned instead of the scrape failing
+	ctx := r.Context()
+	if timeout := scrapeTimeout(r); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c, ok := probeCollector(ctx, w, r)
+	if !ok {
+		return
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	h := promhttp.HandlerFor(helpGatherer{registry}, metricsHandlerOpts())
+	h.ServeHTTP(w, r)
+}
+
+// probeCollector returns the collector for the target, modules, tag, and
+// printer parameters of a /probe request, or only the paused metrics while the
+// target is paused. An error is sent if the parameters are invalid, and ok is
+// false.
+func probeCollector(ctx context.Context, w http.ResponseWriter, r *http.Request) (c prometheus.Collector, ok bool) {
+	query := r.URL.Query()
+
+	target := query.Get("target")
+	if len(query["target"]) != 1 || target == "" {
+		http.Error(w, "'target' parameter must be specified once", 400)
+		return nil, false
+	}
+	if pushOnlyTarget(target) {
+		http.Error(w, fmt.Sprintf("target %s is configured as push_only and cannot be probed", target), http.StatusBadRequest)
+		return nil, false
+	}
+	if until, paused := targetPaused(target); paused {
+		log.Infof("Skipping collection of %s, paused until %s", target, until.Format(time.RFC3339))
+		return pausedCollector{until: until}, true
+	}
+
+	// Set default modules
+	modules := collector.DefaultModules()
+	// get `modules` configuration passed from the prometheus.yml
+	if len(query["modules"]) > 0 {
+		modules = query["modules"]
+	}
+	log.Infof("Starting metrics collection of %s for %s", modules, target)
+
+	opts := targetOptions(target)
+	// get the `tag` to identify requests for this target passed from the prometheus.yml
+	if tag := query.Get("tag"); tag != "" {
+		opts.RequestTag = tag
+	}
+	// get the `printer` label for this target passed from the prometheus.yml
+	opts.Printer = query.Get("printer")
+	opts.Scraper = scraperHost(r)
+
+	return collector.New(ctx, target, modules, apiKey(r.Header.Get("Authorization")), opts), true
+}
+
+// authorizedAdmin returns true if the request has the --web.admin-token bearer
+// token, and otherwise responds with 401 Unauthorized.
+func authorizedAdmin(w http.ResponseWriter, r *http.Request) bool {
+	auth := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(auth, []byte("Bearer "+adminToken)) != 1 {
+		http.Error(w, "a valid bearer token must be specified", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// maintenanceResetHandler marks a maintenance task as done for a target, e.g.
+// `curl -X POST -H 'Authorization: Bearer <token>' 'http://localhost:9101/maintenance/reset?target=klipper.local:7125&task=lube_z'`
+func maintenanceResetHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizedAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "maintenance tasks must be reset using POST", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	target := query.Get("target")
+	task := query.Get("task")
+	if target == "" || task == "" {
+		http.Error(w, "'target' and 'task' parameters must be specified", http.StatusBadRequest)
+		return
+	}
+	m := currentMaintenance()
+	if m == nil {
+		http.Error(w, "no maintenance tasks are configured", http.StatusNotFound)
+		return
+	}
+	if err := m.Reset(target, task); err != nil {
+		if errors.Is(err, collector.ErrUnknownMaintenanceTask) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Reset maintenance task %s for %s\n", task, target)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if eventsURL != "" {
+		var err error
+		eventPublisher, err = collector.NewEventPublisher(eventsURL, eventsTopic)
+		if err != nil {
+			return err
+		}
+		log.Infof("Publishing print events to %s", eventsTopic)
+	}
+	var err error
+	if filamentTotals, err = collector.NewFilamentTotals(filamentStateFile); err != nil {
+		return err
+	}
+	if configFile != "" {
+		if err := reloadConfig(); err != nil {
+			return err
+		}
+		go reloadOnSignal()
+		go flushOnShutdown()
+		if configWatchInterval > 0 {
+			go watchConfig(configWatchInterval)
+		}
+		if enableLifecycle || adminToken != "" {
+			http.HandleFunc("/-/reload", reloadHandler)
+		}
+		if adminToken != "" {
+			http.HandleFunc("/maintenance/reset", maintenanceResetHandler)
+		}
+		targets := &targetsGatherer{concurrency: configConcurrency, timeout: configTimeout}
+		if alertsInterval > 0 {
+			go newAlertEvaluator(targets).run(alertsInterval)
+		}
+		http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// collect within the scrape timeout if it is shorter than --config.timeout
+				gatherer := *targets
+				gatherer.ctx = r.Context()
+				gatherer.scraper = scraperHost(r)
+				if timeout := scrapeTimeout(r); timeout > 0 && timeout < gatherer.timeout {
+					gatherer.timeout = timeout
+				}
+				h := promhttp.HandlerFor(helpGatherer{prometheus.Gatherers{prometheus.DefaultGatherer, &gatherer}}, metricsHandlerOpts())
+				h.ServeHTTP(w, r)
+			}),
+		))
+	} else {
+		http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(helpGatherer{prometheus.DefaultGatherer}, metricsHandlerOpts()),
+		))
+	}
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+	})
+	if enableInflux {
+		http.HandleFunc("/influx", influxHandler)
+	}
+	if adminToken != "" {
+		http.HandleFunc("/targets/", targetsHandler)
+	}
+	http.HandleFunc("/", statusHandler)
+	log.Infof("Beginning to serve on port %s", listenAddress)
+	return http.ListenAndServe(listenAddress, nil)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Klipper Exporter</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.ok { color: green; }
+.failed { color: red; }
+</style>
+</head>
+<body>
+<h1>Klipper Exporter</h1>
+<p>Version {{.Version}}. Exporter metrics are served from <a href="metrics">/metrics</a>.</p>
+<h2>Targets</h2>
+{{if .Targets}}
+<table>
+<tr><th>Target</th><th>Status</th><th>Last scrape</th><th>Data age</th><th>Disabled modules</th><th>Probe</th></tr>
+{{range .Targets}}
+<tr>
+<td>{{.Target}}</td>
+<td class="{{.Class}}">{{.Status}}</td>
+<td>{{.LastScrape}}</td>
+<td>{{.DataAge}}</td>
+<td>{{.DisabledModules}}</td>
+<td>{{if .ProbeURL}}<a href="{{.ProbeURL}}">{{.ProbeURL}}</a>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No targets have been collected yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// statusTarget is a row of the targets table on the status page.
+type statusTarget struct {
+	Target          string
+	Status          string
+	Class           string
+	LastScrape      string
+	DataAge         string
+	DisabledModules string
+	ProbeURL        string
+}
+
+// statusHandler serves a status page listing the configured targets and the
+// targets that have been probed, with the status of the last scrape, the age
+// of the data, and a link to the /probe URL of each target.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	now := time.Now()
+	statuses := make(map[string]collector.TargetStatus)
+	for _, status := range collector.TargetStatuses() {
+		statuses[status.Target] = status
+	}
+
+	rows := make(map[string]*statusTarget)
+	for _, target := range currentConfig().Targets {
+		row := &statusTarget{Target: target.Target, Status: "not scraped", ProbeURL: probeURL(target.Target, target.Modules)}
+		if target.PushOnly {
+			row.Status = "push only"
+			row.ProbeURL = ""
+		}
+		rows[target.Target] = row
+	}
+	for target, status := range statuses {
+		row, ok := rows[target]
+		if !ok {
+			row = &statusTarget{Target: target, ProbeURL: probeURL(target, nil)}
+			rows[target] = row
+		}
+		switch {
+		case status.LastScrape.IsZero():
+			row.Status = "not scraped"
+		case status.RateLimited:
+			row.Status, row.Class = "rate limited", "failed"
+		case status.Succeeded():
+			row.Status, row.Class = "ok", "ok"
+		default:
+			row.Status, row.Class = "failed", "failed"
+		}
+		if !status.LastScrape.IsZero() {
+			row.LastScrape = now.Sub(status.LastScrape).Round(time.Second).String() + " ago"
+		}
+		if !status.LastSuccess.IsZero() {
+			row.DataAge = now.Sub(status.LastSuccess).Round(time.Second).String()
+		}
+		row.DisabledModules = strings.Join(status.DisabledModules, ", ")
+	}
+
+	targets := []*statusTarget{}
+	for _, row := range rows {
+		targets = append(targets, row)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Target < targets[j].Target })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := statusTemplate.Execute(w, struct {
+		Version string
+		Targets []*statusTarget
+	}{version, targets})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// probeURL returns the relative /probe URL for the target and modules.
+func probeURL(target string, modules []string) string {
+	query := url.Values{"target": {target}}
+	for _, module := range modules {
+		query.Add("modules", module)
+	}
+	return "probe?" + query.Encode()
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// Config is the configuration file used to collect metrics from a fixed set
+// of targets on the /metrics endpoint, as an alternative to configuring each
+// target as a /probe scrape job in prometheus.yml, and to configure the
+// maintenance tasks and alert rules.
+type Config struct {
+	// Groups of shared target settings, keyed by group name
+	Groups  map[string]GroupConfig `yaml:"groups"`
+	Targets []TargetConfig         `yaml:"targets"`
+	// Maintenance tasks tracked for every target
+	Maintenance []collector.MaintenanceTask `yaml:"maintenance"`
+	// Deny lists `label=value` rules for series dropped from all targets,
+	// in addition to the --metrics.deny option
+	Deny         []string                `yaml:"deny"`
+	deniedLabels []collector.DeniedLabel `yaml:"-"`
+	// Alerts are evaluated on the metrics of the targets, and notifications
+	// sent for firing and resolved alerts
+	Alerts        []AlertRule        `yaml:"alerts"`
+	Notifications NotificationConfig `yaml:"notifications"`
+}
+
+// GroupConfig is the shared settings for a group of targets, e.g. all of the
+// printers of the same model. Settings set on the target take precedence.
+type GroupConfig struct {
+	Modules []string          `yaml:"modules"`
+	APIKey  string            `yaml:"apikey"`
+	Labels  map[string]string `yaml:"labels"`
+	// ProxyURL is the HTTP proxy used to reach the targets of the group
+	ProxyURL string `yaml:"proxy_url"`
+	// HeaterWattage and PSUCapacity of the targets of the group
+	HeaterWattage map[string]float64 `yaml:"heater_wattage"`
+	PSUCapacity   float64            `yaml:"psu_capacity_watts"`
+}
+
+// TargetConfig is a Klipper host to collect metrics from.
+type TargetConfig struct {
+	// Target is the Moonraker host and port, e.g. `klipper.local:7125`
+	Target string `yaml:"target"`
+	// Group the target inherits the modules, apikey, and labels from.
+	Group string `yaml:"group"`
+	// Modules to collect, defaults to the group modules or the default modules
+	// if not set.
+	Modules []string `yaml:"modules"`
+	// APIKey to authenticate with Moonraker, defaults to the group apikey, the
+	// --moonraker.apikey option, or MOONRAKER_APIKEY environment variable if not set.
+	APIKey string `yaml:"apikey"`
+	// Labels added to all of the series of the target, merged with the group labels.
+	Labels map[string]string `yaml:"labels"`
+	// Printer is added as a `printer` label to every metric of the target
+	// when set.
+	Printer string `yaml:"printer"`
+	// PushOnly marks a target that is not reachable from the exporter, e.g. a
+	// printer that sends its metrics with a push agent. Push-only targets are
+	// not collected and are rejected by the /probe endpoint.
+	PushOnly bool `yaml:"push_only"`
+	// ProxyURL is the HTTP proxy the Moonraker requests of the target are sent
+	// through, e.g. for printers in another network, defaults to the group
+	// proxy_url or the --moonraker.proxy-url option if not set.
+	ProxyURL string   `yaml:"proxy_url"`
+	proxy    *url.URL `yaml:"-"`
+	// HeaterWattage is the rated power in watts of each heater, and
+	// PSUCapacity the power the PSU can supply, to estimate the PSU load.
+	// Default to the group settings, or the --heaters.wattage and
+	// --psu.capacity-watts options if not set.
+	HeaterWattage map[string]float64 `yaml:"heater_wattage"`
+	PSUCapacity   float64            `yaml:"psu_capacity_watts"`
+}
+
+// configuredTarget returns the target from the configuration file.
+func configuredTarget(target string) (TargetConfig, bool) {
+	for _, t := range currentConfig().Targets {
+		if t.Target == target {
+			return t, true
+		}
+	}
+	return TargetConfig{}, false
+}
+
+// pushOnlyTarget returns true if the target is marked as push-only in the
+// configuration file.
+func pushOnlyTarget(target string) bool {
+	t, _ := configuredTarget(target)
+	return t.PushOnly
+}
+
+// targetOptions returns the collector options for the target, using the
+// settings of the target from the configuration file if set.
+func targetOptions(target string) collector.Options {
+	opts := collectorOptions()
+	t, ok := configuredTarget(target)
+	if !ok {
+		return opts
+	}
+	if t.proxy != nil {
+		opts.ProxyURL = t.proxy
+	}
+	if len(t.HeaterWattage) > 0 {
+		opts.HeaterWattage = t.HeaterWattage
+	}
+	if t.PSUCapacity > 0 {
+		opts.PSUCapacity = t.PSUCapacity
+	}
+	return opts
+}
+
+// parseConfig parses and validates the contents of the configuration file.
+func parseConfig(path string, data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	denied, err := collector.ParseDeniedLabels(config.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	config.deniedLabels = denied
+	if err := validateAlertRules(config.Alerts); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	seen := make(map[string]bool)
+	for i, target := range config.Targets {
+		if target.Target == "" {
+			return nil, fmt.Errorf("invalid config file %s: target %d has no target address", path, i+1)
+		}
+		if seen[target.Target] {
+			return nil, fmt.Errorf("invalid config file %s: target %s is listed more than once", path, target.Target)
+		}
+		seen[target.Target] = true
+		if err := applyGroup(config, &config.Targets[i]); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+	return config, nil
+}
+
+// applyGroup sets the target settings that are not set on the target from its
+// group, and validates the labels.
+func applyGroup(config *Config, target *TargetConfig) error {
+	group := GroupConfig{}
+	if target.Group != "" {
+		var ok bool
+		if group, ok = config.Groups[target.Group]; !ok {
+			return fmt.Errorf("target %s group '%s' is not defined", target.Target, target.Group)
+		}
+	}
+	if len(target.Modules) == 0 {
+		target.Modules = group.Modules
+	}
+	if len(target.Modules) == 0 {
+		target.Modules = collector.DefaultModules()
+	}
+	if target.APIKey == "" {
+		target.APIKey = group.APIKey
+	}
+	if target.ProxyURL == "" {
+		target.ProxyURL = group.ProxyURL
+	}
+	proxy, err := parseProxyURL(target.ProxyURL)
+	if err != nil {
+		return fmt.Errorf("target %s: %v", target.Target, err)
+	}
+	target.proxy = proxy
+	if len(target.HeaterWattage) == 0 {
+		target.HeaterWattage = group.HeaterWattage
+	}
+	if target.PSUCapacity == 0 {
+		target.PSUCapacity = group.PSUCapacity
+	}
+	for heater, watts := range target.HeaterWattage {
+		if watts <= 0 {
+			return fmt.Errorf("target %s heater '%s' wattage must be greater than 0", target.Target, heater)
+		}
+	}
+	if target.PSUCapacity < 0 {
+		return fmt.Errorf("target %s psu_capacity_watts must not be negative", target.Target)
+	}
+	labels := make(map[string]string)
+	for name, value := range group.Labels {
+		labels[name] = value
+	}
+	for name, value := range target.Labels {
+		labels[name] = value
+	}
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || name == "target" {
+			return fmt.Errorf("target %s label '%s' is not a valid label name", target.Target, name)
+		}
+	}
+	target.Labels = labels
+	return nil
+}
+
+var targetTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "klipper_exporter_target_timeouts_total",
+	Help: "Number of times a target from the configuration file was left out of the response because it did not complete within the timeout.",
+}, []string{"target"})
+
+// targetsGatherer collects the metrics of all the configured targets, adding
+// a `target` label to each series. Targets are collected in parallel by at
+// most concurrency workers. Targets that have not completed within the timeout
+// are left out of the response so one slow printer cannot fail the scrape.
+type targetsGatherer struct {
+	concurrency int
+	timeout     time.Duration
+	// ctx of the scrape request, if set the collection of all the targets is
+	// cancelled when the scrape is cancelled, e.g. when Prometheus closes the
+	// connection after its scrape timeout
+	ctx context.Context
+	// scraper of the scrape request, see collector.Options.Scraper
+	scraper string
+}
+
+type targetResult struct {
+	index int
+	mfs   []*dto.MetricFamily
+}
+
+func (g *targetsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	targets := []TargetConfig{}
+	for _, target := range currentConfig().Targets {
+		if !target.PushOnly {
+			targets = append(targets, target)
+		}
+	}
+	parent := g.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, g.timeout)
+	defer cancel()
+
+	concurrency := g.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	workers := make(chan struct{}, concurrency)
+	// buffered so targets that complete after the timeout do not block
+	results := make(chan targetResult, len(targets))
+	for i, target := range targets {
+		go func(i int, target TargetConfig) {
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			if ctx.Err() != nil {
+				results <- targetResult{index: i}
+				return
+			}
+			results <- targetResult{index: i, mfs: g.gatherTarget(ctx, target)}
+		}(i, target)
+	}
+
+	collected := make([][]*dto.MetricFamily, len(targets))
+	done := make([]bool, len(targets))
+	for remaining := len(targets); remaining > 0; remaining-- {
+		select {
+		case result := <-results:
+			collected[result.index] = result.mfs
+			done[result.index] = true
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				log.Debugf("Collection of the configured targets was cancelled, %v", parent.Err())
+				remaining = 0
+				break
+			}
+			for i, target := range targets {
+				if !done[i] {
+					log.Warnf("Collection of %s did not complete within %s", target.Target, g.timeout)
+					targetTimeouts.WithLabelValues(target.Target).Inc()
+				}
+			}
+			remaining = 0
+		}
+	}
+
+	return mergeTargetFamilies(targets, collected), nil
+}
+
+// gatherTarget collects the metrics of a single target.
+func (g *targetsGatherer) gatherTarget(ctx context.Context, target TargetConfig) []*dto.MetricFamily {
+	key := target.APIKey
+	if key == "" {
+		key = apiKey("")
+	}
+	registry := prometheus.NewRegistry()
+	if until, paused := targetPaused(target.Target); paused {
+		log.Infof("Skipping collection of %s, paused until %s", target.Target, until.Format(time.RFC3339))
+		registry.MustRegister(pausedCollector{until: until})
+		mfs, _ := registry.Gather()
+		return mfs
+	}
+	log.Infof("Starting metrics collection of %s for %s", target.Modules, target.Target)
+	opts := targetOptions(target.Target)
+	opts.Printer = target.Printer
+	opts.Scraper = g.scraper
+	registry.MustRegister(collector.New(ctx, target.Target, target.Modules, key, opts))
+	mfs, err := registry.Gather()
+	if err != nil {
+		log.Errorf("Collection of %s failed: %v", target.Target, err)
+	}
+	return mfs
+}
+
+// mergeTargetFamilies merges the metric families collected from each target
+// into a single family per metric name, adding the `target` label and the
+// configured target labels. Labels reported by the metric itself take
+// precedence over the configured labels. Series are added in the configured
+// order of the targets so the output is deterministic.
+func mergeTargetFamilies(targets []TargetConfig, collected [][]*dto.MetricFamily) []*dto.MetricFamily {
+	families := make(map[string]*dto.MetricFamily)
+	for i, mfs := range collected {
+		for _, mf := range mfs {
+			family, ok := families[mf.GetName()]
+			if !ok {
+				family = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+				families[mf.GetName()] = family
+			}
+			for _, m := range mf.Metric {
+				m = proto.Clone(m).(*dto.Metric)
+				m.Label = append(m.Label, &dto.LabelPair{Name: proto.String("target"), Value: proto.String(targets[i].Target)})
+				for name, value := range targets[i].Labels {
+					if !hasLabel(m, name) {
+						m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+					}
+				}
+				sort.Slice(m.Label, func(a, b int) bool { return m.Label[a].GetName() < m.Label[b].GetName() })
+				family.Metric = append(family.Metric, m)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	merged := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		merged = append(merged, families[name])
+	}
+	return merged
+}
+
+func hasLabel(m *dto.Metric, name string) bool {
+	for _, label := range m.Label {
+		if label.GetName() == name {
+			return true
+		}
+	}
+	return false
+}